@@ -1,218 +1,141 @@
 package main
 
 import (
-	"encoding/json"
-	"fmt"
-	"io"
-	"log"
-	"net/http"
-	"net/url"
+	"context"
 	"os"
-	"strconv"
-	"time"
+	"strings"
+
+	"PMS/internal/config"
+	"PMS/internal/handlers"
+	"PMS/internal/logging"
+	"PMS/internal/netease"
+	"PMS/internal/server"
+	"PMS/internal/tracing"
 
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
 )
 
-type Config struct {
-	Port            string
-	Cookie          string
-	RealIP          string
-	Level           string
-	NeteaseMusicAPI string
-}
-
-type SongURLResponse struct {
-	Code int `json:"code"`
-	Data []struct {
-		ID            int         `json:"id"`
-		URL           string      `json:"url"`
-		Br            int         `json:"br"`
-		Size          int         `json:"size"`
-		MD5           string      `json:"md5"`
-		Code          int         `json:"code"`
-		Expi          int         `json:"expi"`
-		Type          string      `json:"type"`
-		Gain          float64     `json:"gain"`
-		Peak          float64     `json:"peak"`
-		Fee           int         `json:"fee"`
-		Uf            interface{} `json:"uf"`
-		Payed         int         `json:"payed"`
-		Flag          int         `json:"flag"`
-		CanExtend     bool        `json:"canExtend"`
-		FreeTrialInfo interface{} `json:"freeTrialInfo"`
-		Level         string      `json:"level"`
-	} `json:"data"`
-}
-
-type ErrorResponse struct {
-	Code    int    `json:"code"`
-	Message string `json:"message"`
-}
-
-var config Config
-
 func init() {
-	// 加载.env文件
-	if err := godotenv.Load(); err != nil {
-		log.Println("Warning: .env file not found, using environment variables")
+	// 加载.env文件与YAML配置文件，环境变量优先
+	envErr := godotenv.Load()
+	hasConfigFile, fileErr := config.LoadFile()
+	// LOG_LEVEL 为日志级别，与音质配置 LEVEL 无关
+	if err := logging.Init(config.Lookup("LOG_LEVEL"), config.Lookup("LOG_FORMAT")); err != nil {
+		logging.Logger.Warn("invalid logging config, using defaults", "error", err)
 	}
-
-	config = Config{
-		Port:            getEnvOrDefault("PORT", "8080"),
-		Cookie:          getEnvOrDefault("NETEASE_COOKIE", ""),
-		RealIP:          getEnvOrDefault("REAL_IP", "116.25.146.177"),
-		Level:           getEnvOrDefault("LEVEL", "exhigh"),
-		NeteaseMusicAPI: getEnvOrDefault("NETEASE_MUSIC_API", "https://example.com"),
+	if fileErr != nil {
+		logging.Fatal("failed to load config file", "error", fileErr)
+	}
+	if envErr != nil && !hasConfigFile {
+		logging.Logger.Warn(".env file and config.yaml not found, using environment variables")
 	}
 
-	// 检查必要的配置
-	if config.Cookie == "" {
-		log.Fatal("NETEASE_COOKIE is required in environment variables or .env file")
+	cookie, err := handlers.LoadCookie()
+	if err != nil {
+		logging.Fatal("failed to load cookie", "error", err)
+	}
+	cfg, err := config.Load(cookie == "")
+	if err != nil {
+		logging.Fatal("invalid config", "error", err)
+	}
+	config.Store(cfg)
+	handlers.SetCookie(cookie)
+	if cookie == "" {
+		if cfg.RequireCookie {
+			logging.Fatal("no cookie configured: set NETEASE_COOKIE or NETEASE_COOKIE_FILE in the environment or .env, or cookies in config.yaml (see PMS_CONFIG)")
+		}
+		logging.Logger.Warn("NETEASE_COOKIE is not set, running in anonymous mode: only standard level is available")
+		level := config.Lookup("LEVEL")
+		if level == "" {
+			level = config.DefaultLevel
+		}
+		if level != config.AnonymousLevel {
+			logging.Logger.Warn("default level downgraded in anonymous mode", "level", level, "served_level", config.AnonymousLevel)
+		}
 	}
-}
 
-func getEnvOrDefault(key, defaultValue string) string {
-	if value := os.Getenv(key); value != "" {
-		return value
+	if cfg.MockUpstream {
+		logging.Logger.Warn("MOCK_UPSTREAM is enabled, serving fake data instead of the music service")
 	}
-	return defaultValue
+	handlers.Setup(cfg)
+	handlers.LoadQuotaState(cfg.QuotaStateFile)
 }
 
 func main() {
+	// 监听、中间件等启动时创建的组件使用启动时的配置
+	cfg := config.Current()
+
 	// 设置Gin模式
 	if os.Getenv("GIN_MODE") == "" {
 		gin.SetMode(gin.ReleaseMode)
 	}
 
-	r := gin.Default()
-
-	// 中间件
-	r.Use(gin.Logger())
-	r.Use(gin.Recovery())
-	r.Use(corsMiddleware())
-
-	// 健康检查
-	r.GET("/health", func(c *gin.Context) {
-		c.JSON(http.StatusOK, gin.H{
-			"status":    "ok",
-			"service":   "PublicMusicService",
-			"version":   "1.0.0",
-			"timestamp": time.Now().Unix(),
-		})
-	})
-
-	// API路由 - 简化路径
-	r.GET("/song", getSongURL)
-
-	log.Printf("PublicMusicService (PMS) starting on port %s", config.Port)
-	log.Printf("Netease Music API: %s", config.NeteaseMusicAPI)
-	log.Printf("Default Level: %s", config.Level)
-
-	if err := r.Run(":" + config.Port); err != nil {
-		log.Fatal("Failed to start server:", err)
+	shutdownTracing, err := tracing.Init(context.Background())
+	if err != nil {
+		logging.Fatal("failed to initialize tracing", "error", err)
 	}
-}
-
-func getSongURL(c *gin.Context) {
-	// 获取歌曲ID
-	idStr := c.Query("id")
-	if idStr == "" {
-		c.JSON(http.StatusBadRequest, ErrorResponse{
-			Code:    400,
-			Message: "Missing required parameter: id",
-		})
-		return
+	defer shutdownTracing(context.Background())
+
+	transport := handlers.NewUpstreamTransport(cfg)
+	client := netease.NewHTTPClient(transport)
+	r := server.NewRouter(cfg, client, transport)
+	proxy, proxySource := transport.UpstreamProxy()
+
+	logging.Logger.Info("PublicMusicService (PMS) starting",
+		"port", cfg.Port,
+		"anonymous_mode", handlers.AnonymousMode(),
+		"netease_music_api", cfg.NeteaseMusicAPI,
+		"mock_upstream", cfg.MockUpstream,
+		"upstream_cookie_mode", cfg.UpstreamCookieMode,
+		"upstream_proxy", proxy,
+		"upstream_proxy_source", proxySource,
+		"level", cfg.Level,
+		"level_fallback", strings.Join(cfg.LevelFallback, ","),
+		"upstream_timeout", cfg.UpstreamTimeout.String(),
+		"upstream_retries", cfg.UpstreamRetries,
+		"api_keys", len(cfg.APIKeys),
+		"tracing_endpoint", os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"),
+		"stream_enabled", cfg.StreamEnabled,
+		"gzip_enabled", cfg.GzipEnabled,
+		"gzip_level", cfg.GzipLevel,
+		"rate_limit_rps", cfg.RateLimitRPS,
+		"rate_limit_burst", cfg.RateLimitBurst,
+		"cache_backend", handlers.CacheBackend(),
+		"cache_max_entries", cfg.CacheMaxEntries,
+		"cache_ttl_safety", cfg.CacheTTLSafety.String(),
+		"cache_max_ttl", cfg.CacheMaxTTL.String(),
+	)
+
+	go server.WatchConfigReload()
+	// 与HTTP服务同时开始预热缓存，WARMUP_BLOCK_READY=true 时预热完成前 /ready 返回503
+	handlers.NewSongURLService(client).StartCacheWarmup(cfg.WarmupSongIDs, cfg.WarmupTimeout, cfg.WarmupBlockReady)
+	go handlers.NewHealthService(transport).WaitForUpstream(context.Background())
+	accounts := handlers.NewAccountService(client)
+	if cfg.CookieCheckInterval > 0 {
+		go accounts.RunCookieChecks(context.Background(), cfg.CookieCheckInterval)
 	}
-
-	// 验证ID是否为有效数字
-	songID, err := strconv.Atoi(idStr)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, ErrorResponse{
-			Code:    400,
-			Message: "Invalid song id format",
-		})
-		return
+	if cfg.CookieHealInterval > 0 {
+		go handlers.HealCookiePools(context.Background(), cfg.CookieHealInterval)
 	}
-
-	// 获取可选参数
-	level := c.DefaultQuery("level", config.Level)
-	realIP := c.DefaultQuery("realip", config.RealIP)
-
-	// 构建请求URL
-	timestamp := time.Now().UnixNano() / 1e6 // 毫秒时间戳
-	apiURL := fmt.Sprintf("%s/song/url/v1", config.NeteaseMusicAPI)
-
-	// 构建查询参数
-	params := url.Values{}
-	params.Add("id", strconv.Itoa(songID))
-	params.Add("level", level)
-	params.Add("timestamp", strconv.FormatInt(timestamp, 10))
-	params.Add("cookie", config.Cookie)
-	params.Add("realIP", realIP)
-
-	fullURL := fmt.Sprintf("%s?%s", apiURL, params.Encode())
-
-	// 发起HTTP请求
-	resp, err := http.Get(fullURL)
-	if err != nil {
-		log.Printf("Error requesting Netease API: %v", err)
-		c.JSON(http.StatusInternalServerError, ErrorResponse{
-			Code:    500,
-			Message: "Failed to request music service",
-		})
-		return
-	}
-	defer resp.Body.Close()
-
-	// 读取响应
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		log.Printf("Error reading response body: %v", err)
-		c.JSON(http.StatusInternalServerError, ErrorResponse{
-			Code:    500,
-			Message: "Failed to read response from music service",
-		})
-		return
+	if cfg.CookieRefreshThreshold > 0 {
+		go accounts.RunCookieRefresh(context.Background(), cfg.CookieRefreshThreshold)
 	}
 
-	// 解析JSON响应
-	var songResp SongURLResponse
-	if err := json.Unmarshal(body, &songResp); err != nil {
-		log.Printf("Error parsing JSON response: %v", err)
-		c.JSON(http.StatusInternalServerError, ErrorResponse{
-			Code:    500,
-			Message: "Failed to parse response from music service",
-		})
-		return
+	if cfg.CacheReapInterval > 0 {
+		go handlers.RunCacheReaper(context.Background(), cfg.CacheReapInterval)
 	}
-
-	// 检查网易云音乐API返回的状态码
-	if songResp.Code != 200 {
-		c.JSON(http.StatusBadRequest, ErrorResponse{
-			Code:    songResp.Code,
-			Message: "Music service returned error",
-		})
-		return
+	if cfg.WebhooksEnabled {
+		go handlers.RunWebhookDelivery(context.Background())
+	}
+	if cfg.QuotaStateFile != "" {
+		go handlers.RunQuotaCheckpoints(context.Background(), cfg.QuotaStateFile, cfg.QuotaCheckpointInterval)
 	}
 
-	// 返回结果
-	c.JSON(http.StatusOK, songResp)
-}
-
-func corsMiddleware() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		c.Header("Access-Control-Allow-Origin", "*")
-		c.Header("Access-Control-Allow-Credentials", "true")
-		c.Header("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
-		c.Header("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE")
-
-		if c.Request.Method == "OPTIONS" {
-			c.AbortWithStatus(204)
-			return
-		}
-
-		c.Next()
+	if err := server.Run(cfg, r); err != nil {
+		logging.Fatal("failed to start server", "error", err)
+	}
+	if err := handlers.SaveQuotaState(cfg.QuotaStateFile); err != nil {
+		logging.Logger.Warn("failed to save quota state", "path", cfg.QuotaStateFile, "error", err)
 	}
 }
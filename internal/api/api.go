@@ -0,0 +1,29 @@
+// Package api 定义各接口共用的响应类型
+package api
+
+import (
+	"PMS/internal/logging"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ErrorResponse struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	// 机器可读的失败原因，如 /song 无法提供播放地址时的 not_found、vip_required
+	Reason string `json:"reason,omitempty"`
+	// 上游返回非200 code 时为上游的 code 与说明
+	UpstreamCode    int    `json:"upstream_code,omitempty"`
+	UpstreamMessage string `json:"upstream_message,omitempty"`
+	// 请求ID，用户反馈问题时可据此查找日志
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// NewErrorResponse 构造附带当前请求ID的错误响应
+func NewErrorResponse(c *gin.Context, code int, message string) ErrorResponse {
+	return ErrorResponse{
+		Code:      code,
+		Message:   message,
+		RequestID: logging.RequestID(c.Request.Context()),
+	}
+}
@@ -0,0 +1,579 @@
+// Package config 从环境变量、.env 与YAML配置文件读取并校验PMS的配置
+package config
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"PMS/internal/logging"
+
+	"github.com/joho/godotenv"
+)
+
+type Config struct {
+	Port                    string                  `yaml:"port" env:"PORT"`
+	SocketMode              os.FileMode             `yaml:"socket_mode" env:"SOCKET_MODE"`
+	TLSCertFile             string                  `yaml:"tls_cert_file" env:"TLS_CERT_FILE"`
+	TLSKeyFile              string                  `yaml:"tls_key_file" env:"TLS_KEY_FILE"`
+	TLSPort                 string                  `yaml:"tls_port" env:"TLS_PORT"`
+	TLSReloadInterval       time.Duration           `yaml:"tls_reload_interval" env:"TLS_RELOAD_INTERVAL"`
+	HTTPRedirectToHTTPS     bool                    `yaml:"http_redirect_to_https" env:"HTTP_REDIRECT_TO_HTTPS"`
+	ShutdownTimeout         time.Duration           `yaml:"shutdown_timeout" env:"SHUTDOWN_TIMEOUT"`
+	ServerReadHeaderTimeout time.Duration           `yaml:"server_read_header_timeout" env:"SERVER_READ_HEADER_TIMEOUT"`
+	ServerReadTimeout       time.Duration           `yaml:"server_read_timeout" env:"SERVER_READ_TIMEOUT"`
+	ServerWriteTimeout      time.Duration           `yaml:"server_write_timeout" env:"SERVER_WRITE_TIMEOUT"`
+	ServerIdleTimeout       time.Duration           `yaml:"server_idle_timeout" env:"SERVER_IDLE_TIMEOUT"`
+	ServerMaxHeaderBytes    int                     `yaml:"server_max_header_bytes" env:"SERVER_MAX_HEADER_BYTES"`
+	StreamMaxDuration       time.Duration           `yaml:"stream_max_duration" env:"STREAM_MAX_DURATION"`
+	RequireCookie           bool                    `yaml:"require_cookie" env:"REQUIRE_COOKIE"`
+	CookieCheckInterval     time.Duration           `yaml:"cookie_check_interval" env:"COOKIE_CHECK_INTERVAL"`
+	CookieStrategy          string                  `yaml:"cookie_pool_strategy" env:"COOKIE_POOL_STRATEGY"`
+	CookieFailureThreshold  int                     `yaml:"cookie_failure_threshold" env:"COOKIE_FAILURE_THRESHOLD"`
+	CookieHealInterval      time.Duration           `yaml:"cookie_heal_interval" env:"COOKIE_HEAL_INTERVAL"`
+	CookieRefreshThreshold  time.Duration           `yaml:"cookie_refresh_threshold" env:"COOKIE_REFRESH_THRESHOLD"`
+	CookiePersistRefresh    bool                    `yaml:"cookie_persist_refresh" env:"COOKIE_PERSIST_REFRESH"`
+	RealIP                  string                  `yaml:"real_ip" env:"REAL_IP"`
+	Level                   string                  `yaml:"level" env:"LEVEL"`
+	NeteaseMusicAPI         string                  `yaml:"upstreams" env:"NETEASE_MUSIC_API"`
+	UpstreamCookieMode      string                  `yaml:"upstream_cookie_mode" env:"UPSTREAM_COOKIE_MODE"`
+	UpstreamStrategy        string                  `yaml:"upstream_strategy" env:"UPSTREAM_STRATEGY"`
+	UpstreamCooldown        time.Duration           `yaml:"upstream_cooldown" env:"UPSTREAM_COOLDOWN"`
+	UpstreamAttemptTimeout  time.Duration           `yaml:"upstream_attempt_timeout" env:"UPSTREAM_ATTEMPT_TIMEOUT"`
+	UpstreamProxy           string                  `yaml:"upstream_proxy" env:"UPSTREAM_PROXY"`
+	UpstreamTimeout         time.Duration           `yaml:"upstream_timeout_seconds" env:"UPSTREAM_TIMEOUT_SECONDS"`
+	HTTPMaxIdleConns        int                     `yaml:"http_max_idle_conns" env:"HTTP_MAX_IDLE_CONNS"`
+	HTTPMaxIdleConnsPerHost int                     `yaml:"http_max_idle_conns_per_host" env:"HTTP_MAX_IDLE_CONNS_PER_HOST"`
+	HTTPIdleConnTimeout     time.Duration           `yaml:"http_idle_conn_timeout_seconds" env:"HTTP_IDLE_CONN_TIMEOUT_SECONDS"`
+	UpstreamRetries         int                     `yaml:"upstream_max_retries" env:"UPSTREAM_MAX_RETRIES"`
+	UpstreamRetryBase       time.Duration           `yaml:"upstream_retry_base_ms" env:"UPSTREAM_RETRY_BASE_MS"`
+	UpstreamRetryMax        time.Duration           `yaml:"upstream_retry_max_ms" env:"UPSTREAM_RETRY_MAX_MS"`
+	UpstreamMaxConcurrent   int                     `yaml:"upstream_max_concurrent" env:"UPSTREAM_MAX_CONCURRENT"`
+	UpstreamConcurrencyWait time.Duration           `yaml:"upstream_concurrency_timeout_ms" env:"UPSTREAM_CONCURRENCY_TIMEOUT_MS"`
+	CBFailureThreshold      int                     `yaml:"cb_failure_threshold" env:"CB_FAILURE_THRESHOLD"`
+	CBOpenDuration          time.Duration           `yaml:"cb_open_duration_seconds" env:"CB_OPEN_DURATION_SECONDS"`
+	CacheMaxEntries         int                     `yaml:"cache_max_entries" env:"CACHE_MAX_ENTRIES"`
+	CacheTTLSafety          time.Duration           `yaml:"cache_ttl_safety_seconds" env:"CACHE_TTL_SAFETY_SECONDS"`
+	CacheMaxTTL             time.Duration           `yaml:"cache_max_ttl" env:"CACHE_MAX_TTL"`
+	CacheReapInterval       time.Duration           `yaml:"cache_reap_interval" env:"CACHE_REAP_INTERVAL"`
+	NegativeCacheTTL        time.Duration           `yaml:"negative_cache_ttl" env:"NEGATIVE_CACHE_TTL"`
+	StaleMaxAge             time.Duration           `yaml:"stale_max_age" env:"STALE_MAX_AGE"`
+	StaleSoftTimeout        time.Duration           `yaml:"stale_soft_timeout" env:"STALE_SOFT_TIMEOUT"`
+	LyricCacheTTL           time.Duration           `yaml:"lyric_cache_ttl" env:"LYRIC_CACHE_TTL"`
+	DetailCacheTTL          time.Duration           `yaml:"detail_cache_ttl" env:"DETAIL_CACHE_TTL"`
+	PlaylistCacheTTL        time.Duration           `yaml:"playlist_cache_ttl" env:"PLAYLIST_CACHE_TTL"`
+	PlaylistResolveTimeout  time.Duration           `yaml:"playlist_resolve_timeout" env:"PLAYLIST_RESOLVE_TIMEOUT"`
+	AlbumCacheTTL           time.Duration           `yaml:"album_cache_ttl" env:"ALBUM_CACHE_TTL"`
+	ArtistCacheTTL          time.Duration           `yaml:"artist_cache_ttl" env:"ARTIST_CACHE_TTL"`
+	CoverCacheMaxEntries    int                     `yaml:"cover_cache_max_entries" env:"COVER_CACHE_MAX_ENTRIES"`
+	CoverCacheMaxBytes      int                     `yaml:"cover_cache_max_bytes" env:"COVER_CACHE_MAX_BYTES"`
+	CoverCacheTTL           time.Duration           `yaml:"cover_cache_ttl" env:"COVER_CACHE_TTL"`
+	CoverMaxAge             time.Duration           `yaml:"cover_max_age" env:"COVER_MAX_AGE"`
+	CacheBackend            string                  `yaml:"cache_backend" env:"CACHE_BACKEND"`
+	RedisURL                string                  `yaml:"redis_url" env:"REDIS_URL"`
+	RedisAddr               string                  `yaml:"redis_addr" env:"REDIS_ADDR"`
+	RedisPassword           string                  `yaml:"redis_password" env:"REDIS_PASSWORD"`
+	RedisDB                 int                     `yaml:"redis_db" env:"REDIS_DB"`
+	RedisTimeout            time.Duration           `yaml:"redis_timeout" env:"REDIS_TIMEOUT"`
+	BatchMaxIDs             int                     `yaml:"batch_max_ids" env:"BATCH_MAX_IDS"`
+	BatchConcurrency        int                     `yaml:"batch_concurrency" env:"BATCH_CONCURRENCY"`
+	RateLimitRPS            float64                 `yaml:"rate_limit" env:"RATE_LIMIT"`
+	RateLimitBurst          int                     `yaml:"rate_burst" env:"RATE_BURST"`
+	TrustedProxies          []string                `yaml:"trusted_proxies" env:"TRUSTED_PROXIES"`
+	APIKeys                 []string                `yaml:"api_keys" env:"API_KEYS"`
+	TenantsFile             string                  `yaml:"tenants_file" env:"TENANTS_FILE"`
+	Tenants                 map[string]TenantConfig `yaml:"-"`
+	QuotaStateFile          string                  `yaml:"quota_state_file" env:"QUOTA_STATE_FILE"`
+	QuotaCheckpointInterval time.Duration           `yaml:"quota_checkpoint_interval" env:"QUOTA_CHECKPOINT_INTERVAL"`
+	AllowedOrigins          []string                `yaml:"cors_origins" env:"CORS_ORIGINS"`
+	CORSMaxAge              time.Duration           `yaml:"cors_max_age" env:"CORS_MAX_AGE"`
+	SecurityHeaders         []SecurityHeader        `yaml:"-"`
+	HSTS                    string                  `yaml:"-"`
+	StreamEnabled           bool                    `yaml:"stream_enabled" env:"STREAM_ENABLED"`
+	GzipEnabled             bool                    `yaml:"gzip_enabled" env:"GZIP_ENABLED"`
+	GzipLevel               int                     `yaml:"gzip_level" env:"GZIP_LEVEL"`
+	GzipMinLength           int                     `yaml:"gzip_min_length" env:"GZIP_MIN_LENGTH"`
+	HealthProbeTimeout      time.Duration           `yaml:"health_probe_timeout" env:"HEALTH_PROBE_TIMEOUT"`
+	HealthProbeCacheTTL     time.Duration           `yaml:"health_probe_cache_ttl" env:"HEALTH_PROBE_CACHE_TTL"`
+	StartupUpstreamCheck    bool                    `yaml:"startup_upstream_check" env:"STARTUP_UPSTREAM_CHECK"`
+	WarmupSongIDs           []int64                 `yaml:"warmup_song_ids" env:"WARMUP_SONG_IDS"`
+	WarmupTimeout           time.Duration           `yaml:"warmup_timeout_seconds" env:"WARMUP_TIMEOUT_SECONDS"`
+	WarmupBlockReady        bool                    `yaml:"warmup_block_ready" env:"WARMUP_BLOCK_READY"`
+	MockUpstream            bool                    `yaml:"mock_upstream" env:"MOCK_UPSTREAM"`
+	MockBaseURL             string                  `yaml:"mock_base_url" env:"MOCK_BASE_URL"`
+	MetricsEnabled          bool                    `yaml:"metrics_enabled" env:"METRICS_ENABLED"`
+	MetricsAddr             string                  `yaml:"metrics_addr" env:"METRICS_ADDR"`
+	MetricsToken            string                  `yaml:"metrics_token" env:"METRICS_TOKEN"`
+	AdminToken              string                  `yaml:"admin_token" env:"ADMIN_TOKEN"`
+	PprofEnabled            bool                    `yaml:"pprof_enabled" env:"PPROF_ENABLED"`
+	WebhooksEnabled         bool                    `yaml:"webhooks_enabled" env:"WEBHOOKS_ENABLED"`
+	WebhooksMax             int                     `yaml:"webhooks_max" env:"WEBHOOKS_MAX"`
+	WebhooksAllowPrivate    bool                    `yaml:"webhooks_allow_private" env:"WEBHOOKS_ALLOW_PRIVATE"`
+	LevelFallback           []string                `yaml:"level_fallback" env:"LEVEL_FALLBACK"`
+	FeatureFlagsEnabled     []string                `yaml:"feature_flags_enabled" env:"FEATURE_FLAGS_ENABLED"`
+	MinBitrate              int                     `yaml:"min_bitrate" env:"MIN_BITRATE"`
+}
+
+// 当前配置，SIGHUP 重新加载或管理接口修改时整体替换，已取得的 *Config 不会被修改；处理请求时通过 Current 读取
+var configValue atomic.Pointer[Config]
+
+// 串行化配置替换，避免 Update 的读取-修改-替换与 SIGHUP 重新加载交错而丢失修改
+var storeMu sync.Mutex
+
+// Current 返回当前生效的配置
+func Current() *Config {
+	return configValue.Load()
+}
+
+// Store 替换当前生效的配置
+func Store(cfg *Config) {
+	storeMu.Lock()
+	defer storeMu.Unlock()
+	configValue.Store(cfg)
+}
+
+// Update 复制当前配置并通过 fn 修改副本，fn 返回错误时保留当前配置；返回修改前后的配置
+func Update(fn func(cfg *Config) error) (prev, next *Config, err error) {
+	storeMu.Lock()
+	defer storeMu.Unlock()
+
+	prev = configValue.Load()
+	copied := *prev
+	if err := fn(&copied); err != nil {
+		return prev, nil, err
+	}
+	configValue.Store(&copied)
+	return prev, &copied, nil
+}
+
+// 修改后需要重启才能生效的配置项，这些值在启动时已用于创建监听、连接池、缓存与中间件
+var restartOnlyConfigFields = map[string]bool{
+	"Port":                    true,
+	"SocketMode":              true,
+	"TLSCertFile":             true,
+	"TLSKeyFile":              true,
+	"TLSPort":                 true,
+	"TLSReloadInterval":       true,
+	"HTTPRedirectToHTTPS":     true,
+	"ServerReadHeaderTimeout": true,
+	"ServerReadTimeout":       true,
+	"ServerWriteTimeout":      true,
+	"ServerIdleTimeout":       true,
+	"ServerMaxHeaderBytes":    true,
+	"NeteaseMusicAPI":         true,
+	"UpstreamTimeout":         true,
+	"UpstreamProxy":           true,
+	"HTTPMaxIdleConns":        true,
+	"HTTPMaxIdleConnsPerHost": true,
+	"HTTPIdleConnTimeout":     true,
+	"CBFailureThreshold":      true,
+	"UpstreamMaxConcurrent":   true,
+	"CBOpenDuration":          true,
+	"CacheMaxEntries":         true,
+	"CacheReapInterval":       true,
+	"WarmupSongIDs":           true,
+	"WarmupTimeout":           true,
+	"WarmupBlockReady":        true,
+	"CoverCacheMaxEntries":    true,
+	"CoverCacheMaxBytes":      true,
+	"CacheBackend":            true,
+	"RedisURL":                true,
+	"RedisAddr":               true,
+	"RedisPassword":           true,
+	"RedisDB":                 true,
+	"RedisTimeout":            true,
+	"RateLimitRPS":            true,
+	"RateLimitBurst":          true,
+	"TrustedProxies":          true,
+	"APIKeys":                 true,
+	"AllowedOrigins":          true,
+	"FeatureFlagsEnabled":     true,
+	"CORSMaxAge":              true,
+	"SecurityHeaders":         true,
+	"HSTS":                    true,
+	"GzipEnabled":             true,
+	"GzipLevel":               true,
+	"GzipMinLength":           true,
+	"MockUpstream":            true,
+	"MetricsEnabled":          true,
+	"MetricsAddr":             true,
+	"MetricsToken":            true,
+	"AdminToken":              true,
+	"PprofEnabled":            true,
+	"WebhooksEnabled":         true,
+	"WebhooksMax":             true,
+	"WebhooksAllowPrivate":    true,
+	"CookieCheckInterval":     true,
+	"CookieHealInterval":      true,
+	"CookieRefreshThreshold":  true,
+	"QuotaStateFile":          true,
+	"QuotaCheckpointInterval": true,
+}
+
+// 重新加载时只记录是否变化、不记录取值的配置项
+var secretConfigFields = map[string]bool{
+	"RedisPassword": true,
+	// Redis URL 可能包含密码
+	"RedisURL": true,
+	"APIKeys":  true,
+	// 以API Key为键，包含各租户的Cookie
+	"Tenants":      true,
+	"MetricsToken": true,
+	"AdminToken":   true,
+	// 代理地址可能包含用户名与密码
+	"UpstreamProxy": true,
+}
+
+// Load 从环境变量与配置文件读取并校验配置；anonymous 为 true (未配置Cookie) 时默认音质降为 standard
+func Load(anonymous bool) (*Config, error) {
+	cfg := &Config{
+		Port:                    getEnvOrDefault("PORT", "8080"),
+		TLSCertFile:             getEnvOrDefault("TLS_CERT_FILE", ""),
+		TLSKeyFile:              getEnvOrDefault("TLS_KEY_FILE", ""),
+		TLSPort:                 getEnvOrDefault("TLS_PORT", "8443"),
+		TLSReloadInterval:       getEnvDurationOrDefault("TLS_RELOAD_INTERVAL", time.Minute),
+		HTTPRedirectToHTTPS:     getEnvBoolOrDefault("HTTP_REDIRECT_TO_HTTPS", false),
+		ShutdownTimeout:         getEnvDurationOrDefault("SHUTDOWN_TIMEOUT", getEnvDurationOrDefault("SHUTDOWN_TIMEOUT_SECONDS", 30*time.Second)),
+		ServerReadHeaderTimeout: getEnvDurationOrDefault("SERVER_READ_HEADER_TIMEOUT", 5*time.Second),
+		ServerReadTimeout:       getEnvDurationOrDefault("SERVER_READ_TIMEOUT", 15*time.Second),
+		ServerWriteTimeout:      getEnvDurationOrDefault("SERVER_WRITE_TIMEOUT", 30*time.Second),
+		ServerIdleTimeout:       getEnvDurationOrDefault("SERVER_IDLE_TIMEOUT", 60*time.Second),
+		ServerMaxHeaderBytes:    getEnvIntOrDefault("SERVER_MAX_HEADER_BYTES", 64<<10),
+		StreamMaxDuration:       getEnvDurationOrDefault("STREAM_MAX_DURATION", getEnvDurationOrDefault("STREAM_WRITE_TIMEOUT", time.Hour)),
+		RequireCookie:           getEnvBoolOrDefault("REQUIRE_COOKIE", false),
+		CookieCheckInterval:     getEnvDurationOrDefault("COOKIE_CHECK_INTERVAL", time.Duration(getEnvIntOrDefault("COOKIE_CHECK_INTERVAL_MINUTES", 60))*time.Minute),
+		CookieStrategy:          getEnvOrDefault("COOKIE_POOL_STRATEGY", CookieStrategyRoundRobin),
+		CookieFailureThreshold:  getEnvIntOrDefault("COOKIE_FAILURE_THRESHOLD", 3),
+		CookieHealInterval:      getEnvDurationOrDefault("COOKIE_HEAL_INTERVAL", 10*time.Minute),
+		CookieRefreshThreshold:  getEnvDurationOrDefault("COOKIE_REFRESH_THRESHOLD", time.Duration(getEnvIntOrDefault("COOKIE_REFRESH_THRESHOLD_HOURS", 24))*time.Hour),
+		CookiePersistRefresh:    getEnvBoolOrDefault("COOKIE_PERSIST_REFRESH", false),
+		RealIP:                  getEnvOrDefault("REAL_IP", "116.25.146.177"),
+		Level:                   getEnvOrDefault("LEVEL", DefaultLevel),
+		NeteaseMusicAPI:         getEnvOrDefault("NETEASE_MUSIC_API", "https://example.com"),
+		UpstreamCookieMode:      getEnvOrDefault("UPSTREAM_COOKIE_MODE", UpstreamCookieQuery),
+		UpstreamStrategy:        getEnvOrDefault("UPSTREAM_STRATEGY", UpstreamStrategyPriority),
+		UpstreamCooldown:        getEnvDurationOrDefault("UPSTREAM_COOLDOWN", 30*time.Second),
+		UpstreamAttemptTimeout:  getEnvDurationOrDefault("UPSTREAM_ATTEMPT_TIMEOUT", 0),
+		UpstreamProxy:           strings.TrimSpace(getEnvOrDefault("UPSTREAM_PROXY", "")),
+		UpstreamTimeout:         getEnvDurationOrDefault("UPSTREAM_TIMEOUT_SECONDS", getEnvDurationOrDefault("UPSTREAM_TIMEOUT", 10*time.Second)),
+		HTTPMaxIdleConns:        getEnvIntOrDefault("HTTP_MAX_IDLE_CONNS", 100),
+		HTTPMaxIdleConnsPerHost: getEnvIntOrDefault("HTTP_MAX_IDLE_CONNS_PER_HOST", 10),
+		HTTPIdleConnTimeout:     getEnvDurationOrDefault("HTTP_IDLE_CONN_TIMEOUT_SECONDS", 90*time.Second),
+		// 默认重试2次 (共3次尝试)；UPSTREAM_RETRIES 为兼容旧配置保留的别名
+		UpstreamRetries:         getEnvIntOrDefault("UPSTREAM_MAX_RETRIES", getEnvIntOrDefault("UPSTREAM_RETRIES", 2)),
+		UpstreamRetryBase:       time.Duration(getEnvIntOrDefault("UPSTREAM_RETRY_BASE_MS", 100)) * time.Millisecond,
+		UpstreamRetryMax:        time.Duration(getEnvIntOrDefault("UPSTREAM_RETRY_MAX_MS", 2000)) * time.Millisecond,
+		UpstreamMaxConcurrent:   getEnvIntOrDefault("UPSTREAM_MAX_CONCURRENT", 50),
+		UpstreamConcurrencyWait: time.Duration(getEnvIntOrDefault("UPSTREAM_CONCURRENCY_TIMEOUT_MS", 5000)) * time.Millisecond,
+		CBFailureThreshold:      getEnvIntOrDefault("CB_FAILURE_THRESHOLD", 5),
+		CBOpenDuration:          getEnvDurationOrDefault("CB_OPEN_DURATION_SECONDS", 30*time.Second),
+		CacheMaxEntries:         getEnvIntOrDefault("CACHE_MAX_ENTRIES", 1000),
+		CacheTTLSafety:          time.Duration(getEnvIntOrDefault("CACHE_TTL_SAFETY_SECONDS", 60)) * time.Second,
+		CacheMaxTTL:             getEnvDurationOrDefault("CACHE_MAX_TTL", 30*time.Minute),
+		CacheReapInterval:       getEnvDurationOrDefault("CACHE_REAP_INTERVAL", 30*time.Second),
+		NegativeCacheTTL:        getEnvDurationOrDefault("NEGATIVE_CACHE_TTL", 60*time.Second),
+		StaleMaxAge:             getEnvDurationOrDefault("STALE_MAX_AGE", 5*time.Minute),
+		StaleSoftTimeout:        getEnvDurationOrDefault("STALE_SOFT_TIMEOUT", 2*time.Second),
+		LyricCacheTTL:           getEnvDurationOrDefault("LYRIC_CACHE_TTL", time.Hour),
+		DetailCacheTTL:          getEnvDurationOrDefault("DETAIL_CACHE_TTL", 24*time.Hour),
+		PlaylistCacheTTL:        getEnvDurationOrDefault("PLAYLIST_CACHE_TTL", 5*time.Minute),
+		PlaylistResolveTimeout:  getEnvDurationOrDefault("PLAYLIST_RESOLVE_TIMEOUT", 30*time.Second),
+		AlbumCacheTTL:           getEnvDurationOrDefault("ALBUM_CACHE_TTL", time.Hour),
+		ArtistCacheTTL:          getEnvDurationOrDefault("ARTIST_CACHE_TTL", 30*time.Minute),
+		CoverCacheMaxEntries:    getEnvIntOrDefault("COVER_CACHE_MAX_ENTRIES", 256),
+		CoverCacheMaxBytes:      getEnvIntOrDefault("COVER_CACHE_MAX_BYTES", 64<<20),
+		CoverCacheTTL:           getEnvDurationOrDefault("COVER_CACHE_TTL", 24*time.Hour),
+		CoverMaxAge:             getEnvDurationOrDefault("COVER_MAX_AGE", 30*24*time.Hour),
+		TenantsFile:             getEnvOrDefault("TENANTS_FILE", ""),
+		QuotaStateFile:          getEnvOrDefault("QUOTA_STATE_FILE", ""),
+		QuotaCheckpointInterval: getEnvDurationOrDefault("QUOTA_CHECKPOINT_INTERVAL", time.Minute),
+		CacheBackend:            getEnvOrDefault("CACHE_BACKEND", ""),
+		RedisURL:                getEnvOrDefault("REDIS_URL", ""),
+		RedisAddr:               getEnvOrDefault("REDIS_ADDR", ""),
+		RedisPassword:           getEnvOrDefault("REDIS_PASSWORD", ""),
+		RedisDB:                 getEnvIntOrDefault("REDIS_DB", 0),
+		RedisTimeout:            getEnvDurationOrDefault("REDIS_TIMEOUT", 200*time.Millisecond),
+		BatchMaxIDs:             getEnvIntOrDefault("BATCH_MAX_IDS", 50),
+		BatchConcurrency:        getEnvIntOrDefault("BATCH_CONCURRENCY", 8),
+		RateLimitRPS:            getEnvFloatOrDefault("RATE_LIMIT", getEnvFloatOrDefault("RATE_LIMIT_RPS", 10)),
+		RateLimitBurst:          getEnvIntOrDefault("RATE_BURST", getEnvIntOrDefault("RATE_LIMIT_BURST", 20)),
+		AllowedOrigins:          parseAllowedOrigins(getEnvOrDefault("CORS_ORIGINS", getEnvOrDefault("ALLOWED_ORIGINS", "*"))),
+		CORSMaxAge:              getEnvDurationOrDefault("CORS_MAX_AGE", 10*time.Minute),
+		StreamEnabled:           getEnvBoolOrDefault("STREAM_ENABLED", true),
+		GzipEnabled:             getEnvBoolOrDefault("GZIP_ENABLED", true),
+		GzipLevel:               getEnvIntOrDefault("GZIP_LEVEL", gzip.DefaultCompression),
+		GzipMinLength:           getEnvIntOrDefault("GZIP_MIN_LENGTH", 1024),
+		HealthProbeTimeout:      getEnvDurationOrDefault("HEALTH_PROBE_TIMEOUT", 2*time.Second),
+		HealthProbeCacheTTL:     getEnvDurationOrDefault("HEALTH_PROBE_CACHE_TTL", 5*time.Second),
+		StartupUpstreamCheck:    getEnvBoolOrDefault("STARTUP_UPSTREAM_CHECK", true),
+		WarmupTimeout:           getEnvDurationOrDefault("WARMUP_TIMEOUT_SECONDS", 30*time.Second),
+		WarmupBlockReady:        getEnvBoolOrDefault("WARMUP_BLOCK_READY", false),
+		MockUpstream:            getEnvBoolOrDefault("MOCK_UPSTREAM", false),
+		MockBaseURL:             getEnvOrDefault("MOCK_BASE_URL", ""),
+		MetricsEnabled:          getEnvBoolOrDefault("METRICS_ENABLED", true),
+		MetricsAddr:             getEnvOrDefault("METRICS_ADDR", ""),
+		MetricsToken:            getEnvOrDefault("METRICS_TOKEN", ""),
+		AdminToken:              getEnvOrDefault("ADMIN_TOKEN", ""),
+		PprofEnabled:            getEnvBoolOrDefault("PPROF_ENABLED", false),
+		WebhooksEnabled:         getEnvBoolOrDefault("WEBHOOKS_ENABLED", false),
+		WebhooksMax:             getEnvIntOrDefault("WEBHOOKS_MAX", 100),
+		WebhooksAllowPrivate:    getEnvBoolOrDefault("WEBHOOKS_ALLOW_PRIVATE", false),
+		LevelFallback:           parseLevelList(getEnvOrDefault("LEVEL_FALLBACK", defaultLevelFallback)),
+		MinBitrate:              getEnvIntOrDefault("MIN_BITRATE", 0),
+	}
+	cfg.SecurityHeaders, cfg.HSTS = loadSecurityHeaders()
+	featureFlags, err := parseFeatureFlags(getEnvOrDefault("FEATURE_FLAGS_ENABLED", ""))
+	if err != nil {
+		return nil, err
+	}
+	cfg.FeatureFlagsEnabled = featureFlags
+	warmupSongIDs, err := parseWarmupSongIDs(getEnvOrDefault("WARMUP_SONG_IDS", ""))
+	if err != nil {
+		return nil, err
+	}
+	cfg.WarmupSongIDs = warmupSongIDs
+	apiKeys, err := loadAPIKeys(getEnvOrDefault("API_KEYS", ""), getEnvOrDefault("API_KEYS_FILE", ""))
+	if err != nil {
+		return nil, err
+	}
+	cfg.APIKeys = apiKeys
+	trustedProxies, err := parseTrustedProxies(getEnvOrDefault("TRUSTED_PROXIES", ""))
+	if err != nil {
+		return nil, err
+	}
+	cfg.TrustedProxies = trustedProxies
+	tenants, err := loadTenants(cfg.TenantsFile)
+	if err != nil {
+		return nil, err
+	}
+	cfg.Tenants = tenants
+	if anonymous {
+		cfg.Level = AnonymousLevel
+	}
+
+	// 检查必要的配置
+	if !IsValidLevel(cfg.Level) {
+		return nil, fmt.Errorf("invalid LEVEL: %s", InvalidLevelMessage(cfg.Level))
+	}
+	for _, level := range cfg.LevelFallback {
+		if !IsValidLevel(level) {
+			return nil, fmt.Errorf("invalid LEVEL_FALLBACK: %s", InvalidLevelMessage(level))
+		}
+	}
+	if cfg.CookieStrategy != CookieStrategyRoundRobin && cfg.CookieStrategy != CookieStrategyRandom {
+		return nil, fmt.Errorf("invalid COOKIE_POOL_STRATEGY %q, must be round-robin or random", cfg.CookieStrategy)
+	}
+	if (cfg.TLSCertFile == "") != (cfg.TLSKeyFile == "") {
+		return nil, errors.New("TLS_CERT_FILE and TLS_KEY_FILE must be set together")
+	}
+	if !validGzipLevel(cfg.GzipLevel) {
+		return nil, fmt.Errorf("invalid GZIP_LEVEL %d, must be between -2 and 9", cfg.GzipLevel)
+	}
+	switch cfg.UpstreamCookieMode {
+	case UpstreamCookieQuery, UpstreamCookieHeader, UpstreamCookiePost:
+	default:
+		return nil, fmt.Errorf("invalid UPSTREAM_COOKIE_MODE %q, must be query, header or post", cfg.UpstreamCookieMode)
+	}
+	if err := validCacheBackend(cfg.CacheBackend, cfg.RedisURL, cfg.RedisAddr); err != nil {
+		return nil, err
+	}
+	if cfg.QuotaStateFile != "" && cfg.QuotaCheckpointInterval <= 0 {
+		return nil, fmt.Errorf("invalid QUOTA_CHECKPOINT_INTERVAL %s, must be positive when QUOTA_STATE_FILE is set", cfg.QuotaCheckpointInterval)
+	}
+	if len(cfg.WarmupSongIDs) > 0 && cfg.WarmupTimeout <= 0 {
+		return nil, fmt.Errorf("invalid WARMUP_TIMEOUT_SECONDS %s, must be positive when WARMUP_SONG_IDS is set", cfg.WarmupTimeout)
+	}
+	if cfg.WebhooksEnabled && cfg.WebhooksMax <= 0 {
+		return nil, fmt.Errorf("invalid WEBHOOKS_MAX %d, must be positive when WEBHOOKS_ENABLED is set", cfg.WebhooksMax)
+	}
+	if cfg.UpstreamMaxConcurrent < 0 {
+		return nil, fmt.Errorf("invalid UPSTREAM_MAX_CONCURRENT %d, must not be negative", cfg.UpstreamMaxConcurrent)
+	}
+	if cfg.CoverCacheMaxBytes < 0 {
+		return nil, fmt.Errorf("invalid COVER_CACHE_MAX_BYTES %d, must not be negative", cfg.CoverCacheMaxBytes)
+	}
+	if cfg.MinBitrate < 0 {
+		return nil, fmt.Errorf("invalid MIN_BITRATE %d, must not be negative", cfg.MinBitrate)
+	}
+	if cfg.UpstreamStrategy != UpstreamStrategyPriority && cfg.UpstreamStrategy != UpstreamStrategyRoundRobin {
+		return nil, fmt.Errorf("invalid UPSTREAM_STRATEGY %q, must be priority or round-robin", cfg.UpstreamStrategy)
+	}
+	if err := validUpstreamProxy(cfg.UpstreamProxy); err != nil {
+		return nil, err
+	}
+	if len(ParseUpstreamBases(cfg.NeteaseMusicAPI)) == 0 {
+		return nil, errors.New("NETEASE_MUSIC_API is empty")
+	}
+	// SOCKET_MODE 为八进制权限，如 0660
+	socketMode, err := strconv.ParseUint(getEnvOrDefault("SOCKET_MODE", "0660"), 8, 32)
+	if err != nil || socketMode > 0o777 {
+		return nil, fmt.Errorf("invalid SOCKET_MODE %q, must be an octal permission such as 0660", getEnvOrDefault("SOCKET_MODE", "0660"))
+	}
+	cfg.SocketMode = os.FileMode(socketMode)
+	// 模拟模式下 /song 返回的音频地址指向PMS自身，监听Unix域套接字时为相对路径
+	if cfg.MockBaseURL == "" && !strings.HasPrefix(cfg.Port, "unix:") {
+		cfg.MockBaseURL = "http://localhost:" + cfg.Port
+	}
+	return cfg, nil
+}
+
+// Diff 列出新旧配置中取值不同的字段，敏感字段只标记为已修改
+func Diff(prev, next *Config) (changed []slog.Attr, restartRequired []string) {
+	pv, nv := reflect.ValueOf(prev).Elem(), reflect.ValueOf(next).Elem()
+	for i := 0; i < pv.NumField(); i++ {
+		name := pv.Type().Field(i).Name
+		oldValue, newValue := pv.Field(i).Interface(), nv.Field(i).Interface()
+		if reflect.DeepEqual(oldValue, newValue) {
+			continue
+		}
+		if restartOnlyConfigFields[name] {
+			restartRequired = append(restartRequired, name)
+			continue
+		}
+		if secretConfigFields[name] {
+			changed = append(changed, slog.String(name, "changed"))
+			continue
+		}
+		changed = append(changed, slog.Group(name, "old", fmt.Sprint(oldValue), "new", fmt.Sprint(newValue)))
+	}
+	return changed, restartRequired
+}
+
+// Export 以配置文件中的键名列出全部配置项，敏感字段已设置时替换为 REDACTED，时长以 time.Duration 的字符串形式给出
+func Export(cfg *Config) map[string]any {
+	v := reflect.ValueOf(cfg).Elem()
+	values := make(map[string]any, v.NumField())
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		value := v.Field(i)
+		var exported any = value.Interface()
+		switch {
+		case secretConfigFields[field.Name]:
+			exported = ""
+			if !value.IsZero() {
+				exported = "REDACTED"
+			}
+		case field.Type == reflect.TypeOf(time.Duration(0)):
+			exported = time.Duration(value.Int()).String()
+		}
+		values[exportKey(field)] = exported
+	}
+	return values
+}
+
+// exportKey 返回字段在配置文件中的键名，不能通过配置文件设置的字段使用蛇形命名的字段名
+func exportKey(field reflect.StructField) string {
+	if key := field.Tag.Get("yaml"); key != "" && key != "-" {
+		return key
+	}
+	var b strings.Builder
+	for i, r := range field.Name {
+		if i > 0 && 'A' <= r && r <= 'Z' && !('A' <= rune(field.Name[i-1]) && rune(field.Name[i-1]) <= 'Z') {
+			b.WriteByte('_')
+		}
+		b.WriteRune(r)
+	}
+	return strings.ToLower(b.String())
+}
+
+// Reload 重新读取 .env、配置文件与环境变量，并通过 loadCookie 读取Cookie后校验新配置；
+// 非匿名模式 (anonymous 为 false) 下不允许Cookie变为空。任一步骤失败时返回错误，
+// 配置文件中的取值恢复为重新读取前的状态，调用方应保留当前配置与Cookie
+func Reload(loadCookie func() (string, error), anonymous bool) (*Config, string, error) {
+	// Overload 覆盖已有环境变量，使 .env 中的修改生效
+	if err := godotenv.Overload(); err != nil && !errors.Is(err, os.ErrNotExist) {
+		logging.Logger.Warn("error reloading .env file", "error", err)
+	}
+
+	prevFileValues := fileConfigValues.Load()
+	_, err := LoadFile()
+	var cookie string
+	if err == nil {
+		cookie, err = loadCookie()
+	}
+	if err == nil && cookie == "" && !anonymous {
+		err = errors.New("NETEASE_COOKIE is empty")
+	}
+	var next *Config
+	if err == nil {
+		next, err = Load(cookie == "")
+	}
+	if err != nil {
+		fileConfigValues.Store(prevFileValues)
+		return nil, "", err
+	}
+	return next, cookie, nil
+}
+
+func getEnvOrDefault(key, defaultValue string) string {
+	if value := Lookup(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
+
+// getEnvIntOrDefault 读取整数配置，格式错误时使用默认值
+func getEnvIntOrDefault(key string, defaultValue int) int {
+	value := Lookup(key)
+	if value == "" {
+		return defaultValue
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		logging.Logger.Warn("invalid integer config, using default", "key", key, "value", value, "default", defaultValue)
+		return defaultValue
+	}
+	return n
+}
+
+// getEnvBoolOrDefault 读取布尔配置，支持 true/false/1/0 等写法
+func getEnvBoolOrDefault(key string, defaultValue bool) bool {
+	value := Lookup(key)
+	if value == "" {
+		return defaultValue
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		logging.Logger.Warn("invalid boolean config, using default", "key", key, "value", value, "default", defaultValue)
+		return defaultValue
+	}
+	return b
+}
+
+// getEnvFloatOrDefault 读取浮点数配置，格式错误时使用默认值
+func getEnvFloatOrDefault(key string, defaultValue float64) float64 {
+	value := Lookup(key)
+	if value == "" {
+		return defaultValue
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		logging.Logger.Warn("invalid number config, using default", "key", key, "value", value, "default", defaultValue)
+		return defaultValue
+	}
+	return f
+}
+
+// getEnvDurationOrDefault 读取时长配置，支持 "10s" 格式或纯数字秒数
+func getEnvDurationOrDefault(key string, defaultValue time.Duration) time.Duration {
+	value := Lookup(key)
+	if value == "" {
+		return defaultValue
+	}
+	if d, err := time.ParseDuration(value); err == nil {
+		return d
+	}
+	if secs, err := strconv.Atoi(value); err == nil {
+		return time.Duration(secs) * time.Second
+	}
+	logging.Logger.Warn("invalid duration config, using default", "key", key, "value", value, "default", defaultValue.String())
+	return defaultValue
+}
@@ -0,0 +1,75 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLoadUpstreamRetries(t *testing.T) {
+	tests := []struct {
+		name       string
+		maxRetries string
+		retries    string
+		want       int
+	}{
+		{name: "default", want: 2},
+		{name: "legacy alias", retries: "5", want: 5},
+		{name: "UPSTREAM_MAX_RETRIES wins over alias", maxRetries: "1", retries: "5", want: 1},
+		{name: "disabled", maxRetries: "0", want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("UPSTREAM_MAX_RETRIES", tt.maxRetries)
+			t.Setenv("UPSTREAM_RETRIES", tt.retries)
+			cfg, err := Load(false)
+			if err != nil {
+				t.Fatalf("Load: %v", err)
+			}
+			if cfg.UpstreamRetries != tt.want {
+				t.Errorf("UpstreamRetries = %d, want %d", cfg.UpstreamRetries, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadRejectsInvalidConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		wantErr string
+	}{
+		{name: "level", env: map[string]string{"LEVEL": "ultra"}, wantErr: "invalid LEVEL"},
+		{name: "level fallback", env: map[string]string{"LEVEL_FALLBACK": "exhigh,ultra"}, wantErr: "invalid LEVEL_FALLBACK"},
+		{name: "cookie pool strategy", env: map[string]string{"COOKIE_POOL_STRATEGY": "lru"}, wantErr: "COOKIE_POOL_STRATEGY"},
+		{name: "TLS key without certificate", env: map[string]string{"TLS_KEY_FILE": "key.pem"}, wantErr: "TLS_CERT_FILE and TLS_KEY_FILE"},
+		{name: "gzip level", env: map[string]string{"GZIP_LEVEL": "10"}, wantErr: "GZIP_LEVEL"},
+		{name: "cookie mode", env: map[string]string{"UPSTREAM_COOKIE_MODE": "body"}, wantErr: "UPSTREAM_COOKIE_MODE"},
+		{name: "upstream strategy", env: map[string]string{"UPSTREAM_STRATEGY": "random"}, wantErr: "UPSTREAM_STRATEGY"},
+		{name: "negative concurrency", env: map[string]string{"UPSTREAM_MAX_CONCURRENT": "-1"}, wantErr: "UPSTREAM_MAX_CONCURRENT"},
+		{name: "negative bitrate", env: map[string]string{"MIN_BITRATE": "-1"}, wantErr: "MIN_BITRATE"},
+		{name: "negative cover cache bytes", env: map[string]string{"COVER_CACHE_MAX_BYTES": "-1"}, wantErr: "COVER_CACHE_MAX_BYTES"},
+		{name: "socket mode", env: map[string]string{"SOCKET_MODE": "0999"}, wantErr: "SOCKET_MODE"},
+		{name: "trusted proxy", env: map[string]string{"TRUSTED_PROXIES": "10.0.0.0/8,proxy.local"}, wantErr: "TRUSTED_PROXIES"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+			if _, err := Load(false); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Load error = %v, want it to mention %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoadAnonymousUsesStandardLevel(t *testing.T) {
+	t.Setenv("LEVEL", "lossless")
+	cfg, err := Load(true)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.Level != AnonymousLevel {
+		t.Errorf("Level = %q in anonymous mode, want %q", cfg.Level, AnonymousLevel)
+	}
+}
@@ -0,0 +1,59 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"strings"
+)
+
+// 启动与 SIGHUP 重新加载时读取的 .env 文件
+const envFile = ".env"
+
+// WriteEnvValue 将 .env 中 key 所在的行替换为新值，没有该行时追加到文件末尾，其余行与注释保持不变；
+// 值以引号包裹，可包含空格与 ;
+func WriteEnvValue(key, value string) error {
+	mode := os.FileMode(0o600)
+	data, err := os.ReadFile(envFile)
+	switch {
+	case err == nil:
+		if info, err := os.Stat(envFile); err == nil {
+			mode = info.Mode().Perm()
+		}
+	case !errors.Is(err, os.ErrNotExist):
+		return err
+	}
+
+	line := key + "=" + quoteEnvValue(value)
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	if len(data) == 0 {
+		lines = nil
+	}
+	replaced := false
+	for i, l := range lines {
+		name, _, ok := strings.Cut(strings.TrimPrefix(strings.TrimSpace(l), "export "), "=")
+		if ok && strings.TrimSpace(name) == key {
+			lines[i] = line
+			replaced = true
+		}
+	}
+	if !replaced {
+		lines = append(lines, line)
+	}
+
+	// 先写入临时文件再重命名，避免写入中途失败留下不完整的 .env
+	tmp := envFile + ".tmp"
+	if err := os.WriteFile(tmp, []byte(strings.Join(lines, "\n")+"\n"), mode); err != nil {
+		return err
+	}
+	return os.Rename(tmp, envFile)
+}
+
+// quoteEnvValue 优先使用 godotenv 中不做转义与变量展开的单引号；值本身含单引号时改用双引号并转义
+func quoteEnvValue(value string) string {
+	if !strings.Contains(value, "'") {
+		return "'" + value + "'"
+	}
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, `"`, `\"`)
+	return `"` + value + `"`
+}
@@ -0,0 +1,132 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"sync/atomic"
+
+	"gopkg.in/yaml.v3"
+)
+
+// 未设置 PMS_CONFIG 时读取的配置文件，不存在时忽略
+const defaultConfigFile = "config.yaml"
+
+// 不属于 Config 字段、但可以写在配置文件中的项及其对应的环境变量
+var configFileExtraKeys = map[string]string{
+	"cookies":                       "NETEASE_COOKIE",
+	"cookie_file":                   "NETEASE_COOKIE_FILE",
+	"api_keys_file":                 "API_KEYS_FILE",
+	"log_level":                     "LOG_LEVEL",
+	"log_format":                    "LOG_FORMAT",
+	"security_hsts":                 "SECURITY_HSTS",
+	"security_content_type_options": "SECURITY_CONTENT_TYPE_OPTIONS",
+	"security_frame_options":        "SECURITY_FRAME_OPTIONS",
+	"security_csp":                  "SECURITY_CSP",
+	"security_referrer_policy":      "SECURITY_REFERRER_POLICY",
+	"security_permissions_policy":   "SECURITY_PERMISSIONS_POLICY",
+}
+
+// 配置文件中的列表以该分隔符拼接为环境变量的写法
+var configFileListSeparators = map[string]string{
+	"NETEASE_COOKIE": ";",
+}
+
+// 配置文件中读取到的值，键为对应的环境变量名；环境变量与 .env 优先于配置文件
+var fileConfigValues atomic.Pointer[map[string]string]
+
+// configFileKeys 返回配置文件中允许的键到环境变量名的映射，由 Config 字段的 yaml 与 env 标签生成
+func configFileKeys() map[string]string {
+	keys := make(map[string]string, len(configFileExtraKeys))
+	for k, v := range configFileExtraKeys {
+		keys[k] = v
+	}
+	t := reflect.TypeOf(Config{})
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if name, env := field.Tag.Get("yaml"), field.Tag.Get("env"); name != "" && name != "-" && env != "" {
+			keys[name] = env
+		}
+	}
+	return keys
+}
+
+// LoadFile 读取 PMS_CONFIG 指定的YAML配置文件 (默认 config.yaml)；
+// 返回是否读取到了文件，默认文件不存在时不视为错误
+func LoadFile() (bool, error) {
+	path := os.Getenv("PMS_CONFIG")
+	explicit := path != ""
+	if !explicit {
+		path = defaultConfigFile
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !explicit && errors.Is(err, os.ErrNotExist) {
+			fileConfigValues.Store(&map[string]string{})
+			return false, nil
+		}
+		return false, fmt.Errorf("read config file: %w", err)
+	}
+
+	values, err := parseConfigFile(data)
+	if err != nil {
+		return false, fmt.Errorf("parse config file %s: %w", path, err)
+	}
+	fileConfigValues.Store(&values)
+	return true, nil
+}
+
+// parseConfigFile 将YAML中的值转换为与环境变量相同的字符串写法，列表以逗号拼接
+func parseConfigFile(data []byte) (map[string]string, error) {
+	var raw map[string]any
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+
+	keys := configFileKeys()
+	values := make(map[string]string, len(raw))
+	var unknown []string
+	for key, value := range raw {
+		env, ok := keys[key]
+		if !ok {
+			unknown = append(unknown, key)
+			continue
+		}
+		if value == nil {
+			continue
+		}
+		if list, ok := value.([]any); ok {
+			separator := ","
+			if sep, ok := configFileListSeparators[env]; ok {
+				separator = sep
+			}
+			items := make([]string, len(list))
+			for i, item := range list {
+				items[i] = fmt.Sprint(item)
+			}
+			values[env] = strings.Join(items, separator)
+			continue
+		}
+		values[env] = fmt.Sprint(value)
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return nil, fmt.Errorf("unknown keys: %s", strings.Join(unknown, ", "))
+	}
+	return values, nil
+}
+
+// Lookup 依次读取环境变量 (含 .env) 与配置文件中的值
+func Lookup(key string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	if values := fileConfigValues.Load(); values != nil {
+		return (*values)[key]
+	}
+	return ""
+}
@@ -0,0 +1,54 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// 未配置 LEVEL 时的默认音质
+const DefaultLevel = "exhigh"
+
+// ValidLevels 网易云音乐支持的音质等级，新增音质只需在此追加
+var ValidLevels = []string{
+	"standard",
+	"higher",
+	"exhigh",
+	"lossless",
+	"hires",
+	"jyeffect",
+	"sky",
+	"dolby",
+	"jymaster",
+}
+
+// IsValidLevel 判断音质等级是否受支持
+func IsValidLevel(level string) bool {
+	for _, l := range ValidLevels {
+		if l == level {
+			return true
+		}
+	}
+	return false
+}
+
+// InvalidLevelMessage 返回包含所有可选值的错误提示
+func InvalidLevelMessage(level string) string {
+	return fmt.Sprintf("Invalid level %q, allowed values: %s", level, strings.Join(ValidLevels, ", "))
+}
+
+// 未配置cookie时唯一可用的音质
+const AnonymousLevel = "standard"
+
+// 默认的音质降级顺序，从高到低
+const defaultLevelFallback = "jymaster,hires,lossless,exhigh,higher,standard"
+
+// parseLevelList 解析逗号分隔的音质列表
+func parseLevelList(value string) []string {
+	var levels []string
+	for _, level := range strings.Split(value, ",") {
+		if level = strings.TrimSpace(level); level != "" {
+			levels = append(levels, level)
+		}
+	}
+	return levels
+}
@@ -0,0 +1,198 @@
+package config
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Cookie池的选择策略
+const (
+	CookieStrategyRoundRobin = "round-robin"
+	CookieStrategyRandom     = "random"
+)
+
+// 上游请求中 cookie 的发送方式 (UPSTREAM_COOKIE_MODE)
+const (
+	UpstreamCookieQuery  = "query"
+	UpstreamCookieHeader = "header"
+	UpstreamCookiePost   = "post"
+)
+
+// 多个上游实例的选择策略 (UPSTREAM_STRATEGY)
+const (
+	UpstreamStrategyPriority   = "priority"
+	UpstreamStrategyRoundRobin = "round-robin"
+)
+
+// 响应缓存后端 (CACHE_BACKEND)，为空时配置了Redis则使用Redis，否则使用内存
+const (
+	CacheBackendMemory = "memory"
+	CacheBackendRedis  = "redis"
+)
+
+// validCacheBackend 校验 CACHE_BACKEND 与 REDIS_URL，错误信息中不包含可能带有密码的 REDIS_URL
+func validCacheBackend(backend, redisURL, redisAddr string) error {
+	switch backend {
+	case "", CacheBackendMemory:
+	case CacheBackendRedis:
+		if redisURL == "" && redisAddr == "" {
+			return errors.New("CACHE_BACKEND=redis requires REDIS_URL or REDIS_ADDR")
+		}
+	default:
+		return fmt.Errorf("invalid CACHE_BACKEND %q, must be memory or redis", backend)
+	}
+	if redisURL != "" {
+		if _, err := redis.ParseURL(redisURL); err != nil {
+			return errors.New("invalid REDIS_URL, must be redis://[user:password@]host[:port][/db], rediss:// or unix://")
+		}
+	}
+	return nil
+}
+
+// 可通过 X-PMS-Flags 请求头覆盖默认值的参数 (FEATURE_FLAGS_ENABLED)
+const (
+	FeatureFlagLevel  = "level"
+	FeatureFlagRealIP = "realip"
+)
+
+// parseFeatureFlags 解析逗号分隔的 FEATURE_FLAGS_ENABLED，包含不支持的参数时返回错误
+func parseFeatureFlags(value string) ([]string, error) {
+	var flags []string
+	for _, flag := range strings.Split(value, ",") {
+		flag = strings.ToLower(strings.TrimSpace(flag))
+		switch flag {
+		case "":
+			continue
+		case FeatureFlagLevel, FeatureFlagRealIP:
+			flags = append(flags, flag)
+		default:
+			return nil, fmt.Errorf("invalid FEATURE_FLAGS_ENABLED entry %q, must be one of: level, realip", flag)
+		}
+	}
+	return flags, nil
+}
+
+// parseWarmupSongIDs 解析逗号分隔的 WARMUP_SONG_IDS，重复的ID只保留一个，包含非正整数时返回错误
+func parseWarmupSongIDs(value string) ([]int64, error) {
+	var ids []int64
+	seen := make(map[int64]bool)
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		id, err := strconv.ParseInt(entry, 10, 64)
+		if err != nil || id <= 0 || entry[0] == '+' {
+			return nil, fmt.Errorf("invalid WARMUP_SONG_IDS entry %q, must be a positive song id", entry)
+		}
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+// validUpstreamProxy 校验 UPSTREAM_PROXY，为空表示按 HTTP_PROXY/HTTPS_PROXY/NO_PROXY 选择代理
+func validUpstreamProxy(proxy string) error {
+	if proxy == "" {
+		return nil
+	}
+	u, err := url.Parse(proxy)
+	if err != nil || u.Host == "" {
+		// 代理地址可能包含密码，错误信息中不回显
+		return errors.New("invalid UPSTREAM_PROXY, must be a URL such as socks5://127.0.0.1:1080")
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5", "socks5h":
+		return nil
+	default:
+		return fmt.Errorf("invalid UPSTREAM_PROXY scheme %q, must be http, https or socks5", u.Scheme)
+	}
+}
+
+// ParseUpstreamBases 解析逗号分隔的上游地址列表，去掉末尾的 /
+func ParseUpstreamBases(value string) []string {
+	var bases []string
+	for _, base := range strings.Split(value, ",") {
+		if base = strings.TrimRight(strings.TrimSpace(base), "/"); base != "" {
+			bases = append(bases, base)
+		}
+	}
+	return bases
+}
+
+// parseAllowedOrigins 解析逗号分隔的来源列表，为空或包含 * 时返回 nil 表示允许任意来源
+func parseAllowedOrigins(value string) []string {
+	var origins []string
+	for _, origin := range strings.Split(value, ",") {
+		origin = strings.ToLower(strings.TrimRight(strings.TrimSpace(origin), "/"))
+		if origin == "*" {
+			return nil
+		}
+		if origin != "" {
+			origins = append(origins, origin)
+		}
+	}
+	return origins
+}
+
+// parseTrustedProxies 解析逗号分隔的可信反向代理IP或CIDR，为空时不信任任何代理，
+// 此时 X-Forwarded-For 与 X-Real-IP 被忽略，客户端IP取连接的对端地址
+func parseTrustedProxies(value string) ([]string, error) {
+	var proxies []string
+	for _, proxy := range strings.Split(value, ",") {
+		if proxy = strings.TrimSpace(proxy); proxy == "" {
+			continue
+		}
+		if net.ParseIP(proxy) == nil {
+			if _, _, err := net.ParseCIDR(proxy); err != nil {
+				return nil, fmt.Errorf("invalid TRUSTED_PROXIES entry %q, must be an IP address or CIDR", proxy)
+			}
+		}
+		proxies = append(proxies, proxy)
+	}
+	return proxies, nil
+}
+
+// parseAPIKeys 解析逗号分隔的API Key列表
+func parseAPIKeys(value string) []string {
+	var keys []string
+	for _, key := range strings.Split(value, ",") {
+		if key = strings.TrimSpace(key); key != "" {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// loadAPIKeys 合并 API_KEYS 与 API_KEYS_FILE 中的Key；文件中每行一个或逗号分隔，# 开头的行为注释
+func loadAPIKeys(value, path string) ([]string, error) {
+	keys := parseAPIKeys(value)
+	if path == "" {
+		return keys, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read API_KEYS_FILE: %w", err)
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "#") {
+			keys = append(keys, parseAPIKeys(line)...)
+		}
+	}
+	return keys, nil
+}
+
+// validGzipLevel 判断压缩级别是否受 compress/gzip 支持
+func validGzipLevel(level int) bool {
+	return level == gzip.HuffmanOnly || (level >= gzip.DefaultCompression && level <= gzip.BestCompression)
+}
@@ -0,0 +1,22 @@
+package config
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseAllowedOrigins(t *testing.T) {
+	tests := []struct {
+		value string
+		want  []string
+	}{
+		{value: "*", want: nil},
+		{value: "https://a.example.com, *", want: nil},
+		{value: " https://A.example.com/ ,https://*.example.org,,", want: []string{"https://a.example.com", "https://*.example.org"}},
+	}
+	for _, tt := range tests {
+		if got := parseAllowedOrigins(tt.value); !slices.Equal(got, tt.want) {
+			t.Errorf("parseAllowedOrigins(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// TenantConfig TENANTS_FILE 中一个API Key对应的租户配置
+type TenantConfig struct {
+	// Name 用于日志与缓存键区分租户，不能包含API Key
+	Name string `json:"name"`
+	// Cookie 该租户的请求使用的网易云Cookie，替代全局的 NETEASE_COOKIE
+	Cookie string `json:"cookie"`
+	// Level 该租户未指定音质时的默认音质，为空时使用 LEVEL
+	Level string `json:"level"`
+	// DailyQuota 每天 (UTC) 允许的上游请求数，0 表示不限制
+	DailyQuota int64 `json:"daily_quota"`
+}
+
+// loadTenants 读取 TENANTS_FILE：以API Key为键、TenantConfig为值的JSON对象，path 为空时返回 nil；
+// 错误信息中只包含租户名称，不包含API Key与Cookie
+func loadTenants(path string) (map[string]TenantConfig, error) {
+	if path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read TENANTS_FILE: %w", err)
+	}
+	var tenants map[string]TenantConfig
+	if err := json.Unmarshal(data, &tenants); err != nil {
+		return nil, errors.New("invalid TENANTS_FILE, must be a JSON object mapping API keys to {\"name\", \"cookie\", \"level\", \"daily_quota\"}")
+	}
+
+	names := make(map[string]bool, len(tenants))
+	for key, tenant := range tenants {
+		tenant.Name = strings.TrimSpace(tenant.Name)
+		tenant.Cookie = strings.TrimSpace(tenant.Cookie)
+		switch {
+		case strings.TrimSpace(key) == "":
+			return nil, errors.New("invalid TENANTS_FILE: empty API key")
+		case tenant.Name == "":
+			return nil, errors.New("invalid TENANTS_FILE: every tenant needs a name")
+		case names[tenant.Name]:
+			return nil, fmt.Errorf("invalid TENANTS_FILE: duplicate tenant name %q", tenant.Name)
+		case tenant.Cookie == "":
+			return nil, fmt.Errorf("invalid TENANTS_FILE: tenant %q has no cookie", tenant.Name)
+		case tenant.Level != "" && !IsValidLevel(tenant.Level):
+			return nil, fmt.Errorf("invalid TENANTS_FILE: tenant %q: %s", tenant.Name, InvalidLevelMessage(tenant.Level))
+		case tenant.DailyQuota < 0:
+			return nil, fmt.Errorf("invalid TENANTS_FILE: tenant %q: daily_quota must not be negative", tenant.Name)
+		}
+		names[tenant.Name] = true
+		tenants[key] = tenant
+	}
+	return tenants, nil
+}
+
+type tenantContextKey struct{}
+
+// WithTenant 返回附带租户配置的上下文，上游请求与缓存据此区分租户
+func WithTenant(ctx context.Context, tenant TenantConfig) context.Context {
+	return context.WithValue(ctx, tenantContextKey{}, tenant)
+}
+
+// TenantFrom 返回请求所属的租户，未使用租户API Key时第二个返回值为 false
+func TenantFrom(ctx context.Context) (TenantConfig, bool) {
+	tenant, ok := ctx.Value(tenantContextKey{}).(TenantConfig)
+	return tenant, ok
+}
@@ -0,0 +1,329 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"PMS/internal/api"
+	"PMS/internal/config"
+	"PMS/internal/logging"
+	"PMS/internal/metrics"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AdminCookieRequest POST /admin/cookie 的请求体
+type AdminCookieRequest struct {
+	Cookie   string `json:"cookie"`
+	Validate bool   `json:"validate"`
+}
+
+// AdminCookieStatus Cookie元数据，不包含Cookie本身
+type AdminCookieStatus struct {
+	Code      int    `json:"code"`
+	Set       bool   `json:"set"`
+	Length    int    `json:"length"`
+	Cookies   int    `json:"cookies"`
+	UpdatedAt string `json:"updatedAt,omitempty"`
+	Validated bool   `json:"validated,omitempty"`
+}
+
+// adminCookieStatus 返回当前Cookie的元数据
+func adminCookieStatus(validated bool) AdminCookieStatus {
+	status := AdminCookieStatus{Code: 200, Validated: validated}
+	if state := cookieValue.Load(); state != nil {
+		status.Set = state.value != ""
+		status.Length = len(state.value)
+		status.Cookies = len(state.pool.slots)
+		status.UpdatedAt = state.updatedAt.UTC().Format(time.RFC3339)
+	}
+	return status
+}
+
+// GetAdminCookie 处理 GET /admin/cookie，仅返回是否已设置、长度与更新时间
+func GetAdminCookie(c *gin.Context) {
+	c.JSON(http.StatusOK, adminCookieStatus(false))
+}
+
+// UpdateAdminCookie 处理 POST /admin/cookie，运行时替换Cookie；
+// validate 为 true 时先用新Cookie请求上游登录状态，未登录则拒绝
+func (s *AccountService) UpdateAdminCookie(c *gin.Context) {
+	var req AdminCookieRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, api.NewErrorResponse(c, 400, "Invalid JSON body"))
+		return
+	}
+	cookie := strings.TrimSpace(req.Cookie)
+	if cookie == "" {
+		c.JSON(http.StatusBadRequest, api.NewErrorResponse(c, 400, "Missing required field: cookie"))
+		return
+	}
+
+	ctx := c.Request.Context()
+	if req.Validate {
+		if !s.validateCookie(c, cookie) {
+			return
+		}
+	}
+
+	SetCookie(cookie)
+	logging.From(ctx).Info("cookie updated via admin API", "cookie", RedactCookie(cookie), "validated", req.Validate)
+	c.JSON(http.StatusOK, adminCookieStatus(req.Validate))
+}
+
+// validateCookie 校验新Cookie（多个时逐个校验）是否处于登录状态，失败时直接写入错误响应
+func (s *AccountService) validateCookie(c *gin.Context, cookie string) bool {
+	for i, entry := range parseCookiePool(cookie) {
+		statusResp, err := s.client.LoginStatus(c.Request.Context(), entry, config.Current().RealIP)
+		if err != nil {
+			respondUpstreamError(c, err)
+			return false
+		}
+		if !statusResp.LoggedIn() {
+			c.JSON(http.StatusUnprocessableEntity, api.NewErrorResponse(c, 422, fmt.Sprintf("Cookie #%d is not logged in according to music service", i+1)))
+			return false
+		}
+	}
+	return true
+}
+
+// AdminCachePurgeResponse POST /admin/cache/purge 的响应
+type AdminCachePurgeResponse struct {
+	Code   int `json:"code"`
+	Purged int `json:"purged"`
+}
+
+// PurgeAdminCache 处理 POST /admin/cache/purge，清空响应缓存（包括无法获取结果的缓存）与封面缓存
+func PurgeAdminCache(c *gin.Context) {
+	ctx := c.Request.Context()
+	purged := 0
+	if coverCache != nil {
+		n, _ := coverCache.Purge(ctx)
+		purged += n
+	}
+	if responseCache != nil {
+		n, err := responseCache.Purge(ctx)
+		purged += n
+		if err != nil {
+			logging.From(ctx).Error("error purging response cache", "purged", purged, "error", err)
+			c.JSON(http.StatusInternalServerError, api.NewErrorResponse(c, 500, "Failed to purge cache"))
+			return
+		}
+	}
+
+	logging.From(ctx).Info("cache purged via admin API", "purged", purged)
+	c.JSON(http.StatusOK, AdminCachePurgeResponse{Code: 200, Purged: purged})
+}
+
+// 可通过 PATCH /admin/config 在运行时修改的配置项
+var adminConfigFields = map[string]bool{
+	"level":     true,
+	"real_ip":   true,
+	"log_level": true,
+}
+
+// AdminConfigPatch PATCH /admin/config 的请求体，未提供的字段保持不变
+type AdminConfigPatch struct {
+	Level    *string `json:"level"`
+	RealIP   *string `json:"real_ip"`
+	LogLevel *string `json:"log_level"`
+}
+
+// AdminConfigResponse GET 与 PATCH /admin/config 的响应
+type AdminConfigResponse struct {
+	Code   int            `json:"code"`
+	Config map[string]any `json:"config"`
+}
+
+// adminConfig 返回当前配置，Cookie只保留首尾4个字符，密码与Token等只标记是否已设置
+func adminConfig() AdminConfigResponse {
+	values := config.Export(config.Current())
+	values["log_level"] = logging.Level()
+	values["cookie"] = ""
+	if cookie := currentCookie(); cookie != "" {
+		values["cookie"] = RedactCookie(cookie)
+	}
+	return AdminConfigResponse{Code: 200, Config: values}
+}
+
+// GetAdminConfig 处理 GET /admin/config，返回当前生效的配置
+func GetAdminConfig(c *gin.Context) {
+	c.JSON(http.StatusOK, adminConfig())
+}
+
+// PatchAdminConfig 处理 PATCH /admin/config，运行时修改 level、real_ip 与 log_level；
+// Cookie等其他配置不能通过该接口修改。修改不会写回配置文件，SIGHUP 重新加载后 level 与 real_ip 恢复为配置中的取值
+func PatchAdminConfig(c *gin.Context) {
+	var fields map[string]json.RawMessage
+	if err := c.ShouldBindJSON(&fields); err != nil {
+		c.JSON(http.StatusBadRequest, api.NewErrorResponse(c, 400, "Invalid JSON body"))
+		return
+	}
+	for name := range fields {
+		if name == "cookie" {
+			c.JSON(http.StatusBadRequest, api.NewErrorResponse(c, 400, "Cookie cannot be changed via /admin/config, use POST /admin/cookie or SIGHUP"))
+			return
+		}
+		if !adminConfigFields[name] {
+			c.JSON(http.StatusBadRequest, api.NewErrorResponse(c, 400, fmt.Sprintf("Field %s cannot be changed at runtime, allowed fields: level, real_ip, log_level", name)))
+			return
+		}
+	}
+	var patch AdminConfigPatch
+	data, _ := json.Marshal(fields)
+	if err := json.Unmarshal(data, &patch); err != nil {
+		c.JSON(http.StatusBadRequest, api.NewErrorResponse(c, 400, "Invalid JSON body"))
+		return
+	}
+
+	if patch.Level != nil {
+		if !config.IsValidLevel(*patch.Level) {
+			c.JSON(http.StatusBadRequest, api.NewErrorResponse(c, 400, config.InvalidLevelMessage(*patch.Level)))
+			return
+		}
+		if AnonymousMode() && *patch.Level != config.AnonymousLevel {
+			c.JSON(http.StatusUnprocessableEntity, api.NewErrorResponse(c, 422, "Only standard level is available in anonymous mode"))
+			return
+		}
+	}
+	if patch.RealIP != nil && net.ParseIP(*patch.RealIP) == nil {
+		c.JSON(http.StatusBadRequest, api.NewErrorResponse(c, 400, "Invalid real_ip, must be an IP address"))
+		return
+	}
+	if patch.LogLevel != nil && (*patch.LogLevel == "" || logging.ValidateLevel(*patch.LogLevel) != nil) {
+		c.JSON(http.StatusBadRequest, api.NewErrorResponse(c, 400, "Invalid log_level, must be one of: debug, info, warn, error"))
+		return
+	}
+
+	prev, next, _ := config.Update(func(cfg *config.Config) error {
+		if patch.Level != nil {
+			cfg.Level = *patch.Level
+		}
+		if patch.RealIP != nil {
+			cfg.RealIP = *patch.RealIP
+		}
+		return nil
+	})
+	changed, _ := config.Diff(prev, next)
+	if patch.LogLevel != nil && strings.ToLower(*patch.LogLevel) != logging.Level() {
+		changed = append(changed, slog.Group("LogLevel", "old", logging.Level(), "new", strings.ToLower(*patch.LogLevel)))
+	}
+	// 先记录再修改日志级别，调高到 error 时这条日志也不会被丢弃；
+	// remote_ip 仅在经过可信代理时取自转发头，remote_addr 始终为连接的对端地址，不受请求头影响
+	ctx := c.Request.Context()
+	logging.From(ctx).LogAttrs(ctx, slog.LevelInfo, "config updated via admin API",
+		slog.String("remote_ip", c.ClientIP()),
+		slog.String("remote_addr", c.Request.RemoteAddr),
+		slog.Attr{Key: "changed", Value: slog.GroupValue(changed...)},
+	)
+	if patch.LogLevel != nil {
+		logging.SetLevel(*patch.LogLevel)
+	}
+	c.JSON(http.StatusOK, adminConfig())
+}
+
+// 歌曲地址、降级后仍无法获取与过期缓存的键模板，%d 为歌曲ID，* 匹配音质、realIP 与租户
+var songCacheKeyPatterns = []string{"pms:songurl:%d:*", "pms:neg:songurl:%d:*", "pms:stale:songurl:%d:*"}
+
+// 列出缓存键时 limit 的默认值与上限
+const (
+	defaultAdminCacheKeys = 20
+	maxAdminCacheKeys     = 1000
+)
+
+// AdminCacheStatsResponse GET /admin/cache 的响应，命中统计自进程启动起累计
+type AdminCacheStatsResponse struct {
+	Code    int    `json:"code"`
+	Backend string `json:"backend"`
+	CacheStats
+	Hits   uint64 `json:"hits"`
+	Misses uint64 `json:"misses"`
+	Stale  uint64 `json:"stale"`
+}
+
+// AdminCacheKeysResponse GET /admin/cache/keys 的响应
+type AdminCacheKeysResponse struct {
+	Code int        `json:"code"`
+	Keys []CacheKey `json:"keys"`
+}
+
+// GetAdminCache 处理 GET /admin/cache，返回响应缓存的条目数、估算内存与命中统计；
+// Redis后端时条目数与内存为共用同一Redis的全部PMS实例写入的缓存
+func GetAdminCache(c *gin.Context) {
+	resp := AdminCacheStatsResponse{
+		Code:    200,
+		Backend: CacheBackend(),
+		Hits:    metrics.CacheHits.Load(),
+		Misses:  metrics.CacheMisses.Load(),
+		Stale:   metrics.CacheStaleServes.Load(),
+	}
+	if responseCache != nil {
+		ctx := c.Request.Context()
+		stats, err := responseCache.Stats(ctx)
+		if err != nil {
+			logging.From(ctx).Error("error reading cache stats", "error", err)
+			c.JSON(http.StatusInternalServerError, api.NewErrorResponse(c, 500, "Failed to read cache stats"))
+			return
+		}
+		resp.CacheStats = stats
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// DeleteAdminCache 处理 DELETE /admin/cache：未指定 id 时与 POST /admin/cache/purge 相同，
+// 指定 id 时删除该歌曲全部音质的播放地址缓存
+func DeleteAdminCache(c *gin.Context) {
+	idStr, ok := c.GetQuery("id")
+	if !ok {
+		PurgeAdminCache(c)
+		return
+	}
+	songID, ok := parseSongID(c, idStr)
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+	purged := 0
+	if responseCache != nil {
+		for _, pattern := range songCacheKeyPatterns {
+			n, err := responseCache.Delete(ctx, fmt.Sprintf(pattern, songID))
+			purged += n
+			if err != nil {
+				logging.From(ctx).Error("error evicting song from cache", "song_id", songID, "purged", purged, "error", err)
+				c.JSON(http.StatusInternalServerError, api.NewErrorResponse(c, 500, "Failed to evict song from cache"))
+				return
+			}
+		}
+	}
+
+	logging.From(ctx).Info("song evicted from cache via admin API", "song_id", songID, "purged", purged)
+	c.JSON(http.StatusOK, AdminCachePurgeResponse{Code: 200, Purged: purged})
+}
+
+// GetAdminCacheKeys 处理 GET /admin/cache/keys，返回最近访问的缓存键及剩余有效期，不返回缓存内容
+func GetAdminCacheKeys(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultAdminCacheKeys)))
+	if err != nil || limit < 1 {
+		c.JSON(http.StatusBadRequest, api.NewErrorResponse(c, 400, "Invalid limit"))
+		return
+	}
+	limit = min(limit, maxAdminCacheKeys)
+
+	keys := []CacheKey{}
+	if responseCache != nil {
+		ctx := c.Request.Context()
+		if keys, err = responseCache.Keys(ctx, limit); err != nil {
+			logging.From(ctx).Error("error listing cache keys", "error", err)
+			c.JSON(http.StatusInternalServerError, api.NewErrorResponse(c, 500, "Failed to list cache keys"))
+			return
+		}
+	}
+	c.JSON(http.StatusOK, AdminCacheKeysResponse{Code: 200, Keys: keys})
+}
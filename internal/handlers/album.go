@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"PMS/internal/api"
+	"PMS/internal/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AlbumResponse struct {
+	Code int `json:"code"`
+	// 上游返回的错误说明，只用于错误响应
+	Message     string      `json:"-"`
+	ID          int64       `json:"id"`
+	Name        string      `json:"name"`
+	Artists     []Artist    `json:"artists"`
+	CoverURL    string      `json:"coverUrl"`
+	PublishTime int64       `json:"publishTime"`
+	Description string      `json:"description"`
+	TrackCount  int         `json:"trackCount"`
+	Tracks      []TrackItem `json:"tracks"`
+}
+
+// albumCacheKey 生成专辑的缓存键，租户账号可见的曲目可能不同，附加租户名称
+func albumCacheKey(ctx context.Context, albumID int64) string {
+	return fmt.Sprintf("pms:album:%d", albumID) + tenantKeySuffix(ctx)
+}
+
+// getAlbumCached 优先从缓存读取专辑，未命中时请求上游并写入缓存
+func (s *CatalogService) getAlbumCached(ctx context.Context, albumID int64, realIP string, nocache bool) (*AlbumResponse, error) {
+	key := albumCacheKey(ctx, albumID)
+
+	if responseCache != nil && !nocache {
+		var album AlbumResponse
+		if cacheGetJSON(ctx, key, &album) {
+			return &album, nil
+		}
+	}
+
+	albumResp, err := s.client.Album(ctx, albumID, realIP)
+	if err != nil {
+		return nil, err
+	}
+	if albumResp.Code != 200 {
+		return &AlbumResponse{Code: albumResp.Code, Message: albumResp.Message}, nil
+	}
+
+	a := albumResp.Album
+	album := &AlbumResponse{
+		Code:        200,
+		ID:          a.ID,
+		Name:        a.Name,
+		Artists:     toArtists(a.Artists),
+		CoverURL:    a.PicURL,
+		PublishTime: a.PublishTime,
+		Description: a.Description,
+		TrackCount:  len(albumResp.Songs),
+		Tracks:      make([]TrackItem, 0, len(albumResp.Songs)),
+	}
+	for _, song := range albumResp.Songs {
+		track := toTrackItem(song)
+		// 专辑曲目附带曲序与碟号，便于客户端展示多碟专辑
+		track.Position = song.No
+		track.Disc = parseDiscNumber(song.Cd)
+		album.Tracks = append(album.Tracks, track)
+	}
+
+	if responseCache != nil {
+		cacheSetJSON(ctx, key, album, config.Current().AlbumCacheTTL)
+	}
+	return album, nil
+}
+
+// parseDiscNumber 解析上游以字符串表示的碟号（如 "01"、"1"），无法解析时视为第1碟
+func parseDiscNumber(cd string) int {
+	disc, err := strconv.Atoi(strings.TrimSpace(cd))
+	if err != nil || disc < 1 {
+		return 1
+	}
+	return disc
+}
+
+// GetAlbum 处理 GET /album?id=，返回专辑信息与完整曲目列表
+func (s *CatalogService) GetAlbum(c *gin.Context) {
+	albumID, ok := parseNumericID(c, c.Query("id"), "album")
+	if !ok {
+		return
+	}
+
+	realIP := c.DefaultQuery("realip", defaultRealIP(c))
+	nocache := c.Query("nocache") == "1"
+
+	album, err := s.getAlbumCached(c.Request.Context(), albumID, realIP, nocache)
+	if err != nil {
+		respondUpstreamError(c, err)
+		return
+	}
+
+	// 检查网易云音乐API返回的状态码
+	switch album.Code {
+	case 200:
+	case 404:
+		c.JSON(http.StatusNotFound, api.NewErrorResponse(c, 404, "Album not found"))
+		return
+	default:
+		respondUpstreamCode(c, album.Code, album.Message)
+		return
+	}
+
+	c.JSON(http.StatusOK, album)
+}
@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"PMS/internal/config"
+	"PMS/internal/logging"
+	"PMS/internal/netease"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 歌手页返回的热门歌曲与专辑数量
+const (
+	artistHotSongsLimit = 50
+	artistAlbumsLimit   = 30
+)
+
+type AlbumSummary struct {
+	ID          int64  `json:"id"`
+	Name        string `json:"name"`
+	CoverURL    string `json:"coverUrl"`
+	PublishTime int64  `json:"publishTime"`
+	TrackCount  int    `json:"trackCount"`
+}
+
+// toAlbumSummary 将上游歌手专辑列表与搜索结果中的专辑转换为对外的 AlbumSummary
+func toAlbumSummary(al netease.AlbumSummary) AlbumSummary {
+	return AlbumSummary{
+		ID:          al.ID,
+		Name:        al.Name,
+		CoverURL:    al.PicURL,
+		PublishTime: al.PublishTime,
+		TrackCount:  al.Size,
+	}
+}
+
+type ArtistAlbums struct {
+	Total int            `json:"total"`
+	Items []AlbumSummary `json:"items"`
+}
+
+type ArtistResponse struct {
+	Code int `json:"code"`
+	// 上游返回的错误说明，只用于错误响应
+	Message      string       `json:"-"`
+	ID           int64        `json:"id"`
+	Name         string       `json:"name"`
+	CoverURL     string       `json:"coverUrl"`
+	Introduction string       `json:"introduction"`
+	HotSongs     []TrackItem  `json:"hotSongs"`
+	Albums       ArtistAlbums `json:"albums"`
+}
+
+// artistCacheKey 生成歌手的缓存键，与 songCacheKey 一样按租户区分
+func artistCacheKey(ctx context.Context, artistID int64) string {
+	return fmt.Sprintf("pms:artist:%d", artistID) + tenantKeySuffix(ctx)
+}
+
+// getArtistCached 优先从缓存读取歌手信息，未命中时请求上游并写入缓存；
+// 专辑列表获取失败时仍返回歌手信息
+func (s *CatalogService) getArtistCached(ctx context.Context, artistID int64, realIP string, nocache bool) (*ArtistResponse, error) {
+	key := artistCacheKey(ctx, artistID)
+
+	if responseCache != nil && !nocache {
+		var artist ArtistResponse
+		if cacheGetJSON(ctx, key, &artist) {
+			return &artist, nil
+		}
+	}
+
+	artistResp, err := s.client.Artist(ctx, artistID, realIP)
+	if err != nil {
+		return nil, err
+	}
+	if artistResp.Code != 200 {
+		return &ArtistResponse{Code: artistResp.Code, Message: artistResp.Message}, nil
+	}
+
+	a := artistResp.Artist
+	artist := &ArtistResponse{
+		Code:         200,
+		ID:           a.ID,
+		Name:         a.Name,
+		CoverURL:     a.PicURL,
+		Introduction: a.BriefDesc,
+		HotSongs:     make([]TrackItem, 0, len(artistResp.HotSongs)),
+		Albums: ArtistAlbums{
+			Total: a.AlbumSize,
+			Items: []AlbumSummary{},
+		},
+	}
+	for _, song := range paginate(artistResp.HotSongs, 0, artistHotSongsLimit) {
+		artist.HotSongs = append(artist.HotSongs, toTrackItem(song))
+	}
+
+	albumsResp, err := s.client.ArtistAlbums(ctx, artistID, artistAlbumsLimit, realIP)
+	switch {
+	case err != nil:
+		logging.From(ctx).Warn("error fetching artist albums", "artist_id", artistID, "error", err)
+	case albumsResp.Code != 200:
+		logging.From(ctx).Warn("music service returned error for artist albums", "artist_id", artistID, "upstream_code", albumsResp.Code)
+	default:
+		for _, al := range albumsResp.HotAlbums {
+			artist.Albums.Items = append(artist.Albums.Items, toAlbumSummary(al))
+		}
+	}
+
+	if responseCache != nil {
+		cacheSetJSON(ctx, key, artist, config.Current().ArtistCacheTTL)
+	}
+	return artist, nil
+}
+
+// GetArtist 处理 GET /artist?id=，返回歌手信息、热门歌曲与专辑概要
+func (s *CatalogService) GetArtist(c *gin.Context) {
+	artistID, ok := parseNumericID(c, c.Query("id"), "artist")
+	if !ok {
+		return
+	}
+
+	realIP := c.DefaultQuery("realip", defaultRealIP(c))
+	nocache := c.Query("nocache") == "1"
+
+	artist, err := s.getArtistCached(c.Request.Context(), artistID, realIP, nocache)
+	if err != nil {
+		respondUpstreamError(c, err)
+		return
+	}
+
+	// 检查网易云音乐API返回的状态码
+	if artist.Code != 200 {
+		respondUpstreamCode(c, artist.Code, artist.Message)
+		return
+	}
+
+	c.JSON(http.StatusOK, artist)
+}
@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"PMS/internal/api"
+
+	"github.com/gin-gonic/gin"
+)
+
+// type 参数允许的音频格式
+var audioTypes = []string{"mp3", "flac", "aac", "m4a", "ogg"}
+
+// checkAudioType 校验请求中的 type 参数，为空表示不限格式，无效时写入400响应
+func checkAudioType(c *gin.Context, audioType string) bool {
+	if audioType == "" {
+		return true
+	}
+	for _, t := range audioTypes {
+		if strings.EqualFold(audioType, t) {
+			return true
+		}
+	}
+	c.JSON(http.StatusBadRequest, api.NewErrorResponse(c, 400, fmt.Sprintf("Invalid type %q, allowed values: %s", audioType, strings.Join(audioTypes, ", "))))
+	return false
+}
+
+// audioTypeMismatch 判断上游返回的音频格式是否与请求的 type 不符；
+// 未指定 type 或上游没有返回播放地址时不做过滤
+func audioTypeMismatch(songResp *SongURLResponse, audioType string) bool {
+	if audioType == "" || !hasPlayableURL(songResp) {
+		return false
+	}
+	return !strings.EqualFold(songResp.Data[0].Type, audioType)
+}
+
+// audioTypeMismatchMessage 批量结果中格式不符的歌曲返回的错误信息
+func audioTypeMismatchMessage(songResp *SongURLResponse, audioType string) string {
+	return fmt.Sprintf("Audio type %q does not match requested type %q", songResp.Data[0].Type, strings.ToLower(audioType))
+}
@@ -0,0 +1,226 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"PMS/internal/api"
+	"PMS/internal/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+type BatchSongURLRequest struct {
+	IDs      []int64 `json:"ids"`
+	Level    string  `json:"level"`
+	Type     string  `json:"type"`
+	RealIP   string  `json:"realip"`
+	NoCache  bool    `json:"nocache"`
+	Fallback *bool   `json:"fallback"`
+}
+
+// BatchSongURLItem 批量结果中的单项，失败时仅包含 error 字段
+type BatchSongURLItem struct {
+	*SongURLResponse
+	Error string `json:"error,omitempty"`
+}
+
+type BatchSongURLResponse struct {
+	Code int                            `json:"code"`
+	Data batchResults[BatchSongURLItem] `json:"data"`
+}
+
+// batchResults 以歌曲ID为键的结果集合，序列化时保持请求中的ID顺序
+type batchResults[T any] struct {
+	keys  []string
+	items map[string]T
+}
+
+func (r batchResults[T]) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, key := range r.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		k, err := json.Marshal(key)
+		if err != nil {
+			return nil, err
+		}
+		v, err := json.Marshal(r.items[key])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(k)
+		buf.WriteByte(':')
+		buf.Write(v)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// BatchGetSongURLs 处理 POST /songs，请求体为JSON
+func (s *SongURLService) BatchGetSongURLs(c *gin.Context) {
+	var req BatchSongURLRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, api.NewErrorResponse(c, 400, "Invalid request body"))
+		return
+	}
+
+	ids := make([]string, len(req.IDs))
+	for i, id := range req.IDs {
+		ids[i] = strconv.FormatInt(id, 10)
+	}
+
+	level := req.Level
+	if level == "" {
+		level = defaultLevel(c)
+	}
+	realIP := req.RealIP
+	if realIP == "" {
+		realIP = defaultRealIP(c)
+	}
+
+	fallback := req.Fallback == nil || *req.Fallback
+
+	s.respondBatch(c, ids, level, req.Type, realIP, req.NoCache, fallback)
+}
+
+// BatchGetSongURLsByQuery 处理 GET /songs?id=1,2,3
+func (s *SongURLService) BatchGetSongURLsByQuery(c *gin.Context) {
+	var ids []string
+	for _, id := range strings.Split(c.Query("id"), ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			ids = append(ids, id)
+		}
+	}
+
+	level := c.DefaultQuery("level", defaultLevel(c))
+	realIP := c.DefaultQuery("realip", defaultRealIP(c))
+	nocache := c.Query("nocache") == "1"
+	fallback := c.Query("fallback") != "false"
+
+	s.respondBatch(c, ids, level, c.Query("type"), realIP, nocache, fallback)
+}
+
+// respondBatch 解析多首歌曲的播放地址，指定了 audioType 时格式不符的歌曲以错误信息返回
+func (s *SongURLService) respondBatch(c *gin.Context, ids []string, level, audioType, realIP string, nocache, fallback bool) {
+	if !checkLevel(c, level) || !checkAudioType(c, audioType) {
+		return
+	}
+
+	ids, ok := batchIDs(c, ids)
+	if !ok {
+		return
+	}
+
+	results := s.resolveMany(c.Request.Context(), ids, level, realIP, nocache, fallback)
+	if clientGone(c) {
+		return
+	}
+	for key, item := range results.items {
+		if item.SongURLResponse != nil && audioTypeMismatch(item.SongURLResponse, audioType) {
+			results.items[key] = BatchSongURLItem{Error: audioTypeMismatchMessage(item.SongURLResponse, audioType)}
+		}
+	}
+	c.JSON(http.StatusOK, BatchSongURLResponse{Code: 200, Data: results})
+}
+
+// batchIDs 去除重复ID并校验数量与租户的剩余额度，不合法时直接写入错误响应
+func batchIDs(c *gin.Context, ids []string) ([]string, bool) {
+	if len(ids) == 0 {
+		c.JSON(http.StatusBadRequest, api.NewErrorResponse(c, 400, "Missing required parameter: ids"))
+		return nil, false
+	}
+
+	ids = dedupeIDs(ids)
+	if len(ids) > config.Current().BatchMaxIDs {
+		c.JSON(http.StatusBadRequest, api.NewErrorResponse(c, 400, fmt.Sprintf("Too many ids, at most %d are allowed", config.Current().BatchMaxIDs)))
+		return nil, false
+	}
+	if !checkBatchQuota(c, len(ids)) {
+		return nil, false
+	}
+	return ids, true
+}
+
+// resolveMany 以有限的并发数请求上游，单个ID失败不影响整体结果
+func (s *SongURLService) resolveMany(ctx context.Context, ids []string, level, realIP string, nocache, fallback bool) batchResults[BatchSongURLItem] {
+	return fanOutSongIDs(ctx, ids, func(songID int64) BatchSongURLItem {
+		songResp, _, err := s.resolve(ctx, songID, level, realIP, nocache, fallback)
+		switch {
+		case err != nil:
+			return BatchSongURLItem{Error: upstreamErrorMessage(err)}
+		case songResp.Code != 200:
+			return BatchSongURLItem{Error: upstreamCodeMessage(songResp.Code)}
+		default:
+			return BatchSongURLItem{SongURLResponse: songResp}
+		}
+	}, func(message string) BatchSongURLItem {
+		return BatchSongURLItem{Error: message}
+	})
+}
+
+// fanOutSongIDs 以 BATCH_CONCURRENCY 限制的并发数对每个ID调用 do；
+// ID格式错误、整体时限已到或客户端断开时仍在排队的ID，结果由 fail 根据错误信息生成
+func fanOutSongIDs[T any](ctx context.Context, ids []string, do func(songID int64) T, fail func(message string) T) batchResults[T] {
+	results := batchResults[T]{
+		keys:  ids,
+		items: make(map[string]T, len(ids)),
+	}
+
+	var (
+		mu  sync.Mutex
+		wg  sync.WaitGroup
+		sem = make(chan struct{}, max(config.Current().BatchConcurrency, 1))
+	)
+	for _, id := range ids {
+		songID, ok := parseID(id)
+		if !ok {
+			results.items[id] = fail(invalidIDMessage(id, "song"))
+			continue
+		}
+
+		wg.Add(1)
+		go func(key string, songID int64) {
+			defer wg.Done()
+
+			var item T
+			select {
+			case sem <- struct{}{}:
+				item = do(songID)
+				<-sem
+			case <-ctx.Done():
+				// 整体时限已到或客户端已断开，排队中的ID不再请求上游
+				item = fail(upstreamErrorMessage(upstreamContextError(ctx)))
+			}
+
+			mu.Lock()
+			results.items[key] = item
+			mu.Unlock()
+		}(id, songID)
+	}
+	wg.Wait()
+
+	return results
+}
+
+// dedupeIDs 去除重复ID并保持首次出现的顺序
+func dedupeIDs(ids []string) []string {
+	seen := make(map[string]struct{}, len(ids))
+	unique := ids[:0:0]
+	for _, id := range ids {
+		if _, ok := seen[id]; ok {
+			continue
+		}
+		seen[id] = struct{}{}
+		unique = append(unique, id)
+	}
+	return unique
+}
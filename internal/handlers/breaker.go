@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"PMS/internal/logging"
+)
+
+// 熔断器状态，/health 中的 circuit_state 字段取其字符串值
+type circuitState string
+
+const (
+	circuitClosed   circuitState = "closed"
+	circuitOpen     circuitState = "open"
+	circuitHalfOpen circuitState = "half-open"
+)
+
+var errCircuitOpen = errors.New("upstream unavailable, circuit open")
+
+// circuitBreaker 连续失败达到阈值后熔断，熔断期间直接拒绝请求；
+// 熔断时间结束后进入半开状态，仅放行一个探测请求，成功则恢复，失败则重新熔断
+type circuitBreaker struct {
+	name         string
+	mu           sync.Mutex
+	threshold    int
+	openDuration time.Duration
+	state        circuitState
+	failures     int
+	openedAt     time.Time
+	probing      bool
+}
+
+func newCircuitBreaker(name string, threshold int, openDuration time.Duration) *circuitBreaker {
+	return &circuitBreaker{
+		name:         name,
+		threshold:    threshold,
+		openDuration: openDuration,
+		state:        circuitClosed,
+	}
+}
+
+// State 返回当前状态，熔断时间已过时报告为半开
+func (b *circuitBreaker) State() circuitState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == circuitOpen && time.Since(b.openedAt) >= b.openDuration {
+		return circuitHalfOpen
+	}
+	return b.state
+}
+
+// Allow 判断是否可以向上游发起请求
+func (b *circuitBreaker) Allow() error {
+	if b.threshold <= 0 {
+		return nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case circuitOpen:
+		if time.Since(b.openedAt) < b.openDuration {
+			return errCircuitOpen
+		}
+		b.state = circuitHalfOpen
+		b.probing = true
+		logging.Logger.Info("circuit breaker half-open, probing upstream", "upstream_url", b.name)
+		return nil
+	case circuitHalfOpen:
+		if b.probing {
+			return errCircuitOpen
+		}
+		b.probing = true
+	}
+	return nil
+}
+
+// Cancel 请求被客户端取消时调用，不计入成功或失败；半开状态下放弃本次探测，由后续请求重新探测
+func (b *circuitBreaker) Cancel() {
+	if b.threshold <= 0 {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+}
+
+// Record 记录一次上游请求结果，仅网络错误、超时与5xx计为失败
+func (b *circuitBreaker) Record(failed bool) {
+	if b.threshold <= 0 {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !failed {
+		if b.state != circuitClosed {
+			logging.Logger.Info("circuit breaker closed, upstream recovered", "upstream_url", b.name)
+		}
+		b.state = circuitClosed
+		b.failures = 0
+		b.probing = false
+		return
+	}
+
+	b.failures++
+	if b.state == circuitHalfOpen || b.failures >= b.threshold {
+		if b.state != circuitOpen {
+			logging.Logger.Error("circuit breaker opened, rejecting upstream requests",
+				"upstream_url", b.name,
+				"consecutive_failures", b.failures,
+				"open_duration", b.openDuration.String(),
+			)
+		}
+		b.state = circuitOpen
+		b.openedAt = time.Now()
+		b.probing = false
+	}
+}
@@ -0,0 +1,484 @@
+package handlers
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"sync"
+	"time"
+
+	"PMS/internal/config"
+	"PMS/internal/logging"
+	"PMS/internal/metrics"
+	"PMS/internal/netease"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// ResponseCache 上游响应缓存后端，内存与Redis两种实现均满足该接口，
+// 值为JSON序列化后的响应，歌曲地址、歌词等接口共用
+type ResponseCache interface {
+	Get(ctx context.Context, key string) ([]byte, bool)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration)
+	// Purge 清空PMS写入的全部缓存项，返回删除的数量
+	Purge(ctx context.Context) (int, error)
+	// Delete 删除键匹配 pattern (path.Match 语法，* 可匹配 :) 的缓存项，返回删除的数量
+	Delete(ctx context.Context, pattern string) (int, error)
+	// Stats 返回PMS写入的缓存项数量与估算占用的内存
+	Stats(ctx context.Context) (CacheStats, error)
+	// Keys 返回最近访问的至多 limit 个缓存键及剩余有效期，最近访问的在前
+	Keys(ctx context.Context, limit int) ([]CacheKey, error)
+}
+
+// CacheStats 缓存项数量与估算占用的内存字节数
+type CacheStats struct {
+	Entries     int   `json:"entries"`
+	MemoryBytes int64 `json:"memoryBytes"`
+}
+
+// CacheKey 缓存键及其剩余有效期，不包含缓存的值
+type CacheKey struct {
+	Key        string `json:"key"`
+	TTLSeconds int    `json:"ttlSeconds"`
+}
+
+// 全局响应缓存，为 nil 表示禁用
+var responseCache ResponseCache
+
+// cacheStatus 通过 X-PMS-Cache 响应头告知客户端缓存命中情况
+type cacheStatus string
+
+const (
+	cacheHit      cacheStatus = "HIT"
+	cacheMiss     cacheStatus = "MISS"
+	cacheBypass   cacheStatus = "BYPASS"
+	cacheNegative cacheStatus = "NEGATIVE"
+	cacheStale    cacheStatus = "STALE"
+	cacheDisabled cacheStatus = ""
+)
+
+// cacheGetJSON 读取缓存并反序列化到 dst，同时记录命中统计
+func cacheGetJSON(ctx context.Context, key string, dst any) bool {
+	data, ok := responseCache.Get(ctx, key)
+	if ok && json.Unmarshal(data, dst) == nil {
+		metrics.CacheHits.Add(1)
+		return true
+	}
+	metrics.CacheMisses.Add(1)
+	return false
+}
+
+// cacheSetJSON 序列化 value 后写入缓存
+func cacheSetJSON(ctx context.Context, key string, value any, ttl time.Duration) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		logging.From(ctx).Error("error encoding cache entry", "cache_key", key, "error", err)
+		return
+	}
+	responseCache.Set(ctx, key, data, ttl)
+}
+
+// songCacheKey 生成歌曲地址的缓存键；上游按 realIP 所在地区返回结果，不同 realIP 分别缓存；
+// 不同租户的账号可获取的地址不同，租户的请求附加租户名称
+func songCacheKey(ctx context.Context, songID int64, level, realIP string) string {
+	return fmt.Sprintf("pms:songurl:%d:%s", songID, level) + realIPKeySuffix(realIP) + tenantKeySuffix(ctx)
+}
+
+// negativeCacheKey 生成无法获取结果的缓存键，是否降级会影响结果，因此计入键中
+func negativeCacheKey(ctx context.Context, songID int64, level, realIP string, fallback bool) string {
+	return fmt.Sprintf("pms:neg:songurl:%d:%s:%t", songID, level, fallback) + realIPKeySuffix(realIP) + tenantKeySuffix(ctx)
+}
+
+// staleSongCacheKey 生成歌曲地址过期缓存的键，该项比 songCacheKey 多保留 STALE_MAX_AGE
+func staleSongCacheKey(ctx context.Context, songID int64, level, realIP string) string {
+	return fmt.Sprintf("pms:stale:songurl:%d:%s", songID, level) + realIPKeySuffix(realIP) + tenantKeySuffix(ctx)
+}
+
+// realIPKeySuffix 指定了 realIP 时返回 :ip:<realIP>，否则返回空字符串
+func realIPKeySuffix(realIP string) string {
+	if realIP == "" {
+		return ""
+	}
+	return ":ip:" + realIP
+}
+
+// tenantKeySuffix 租户的请求返回 :t:<租户名称>，用于区分缓存与合并的上游请求，其他请求返回空字符串
+func tenantKeySuffix(ctx context.Context) string {
+	if tenant, ok := config.TenantFrom(ctx); ok {
+		return ":t:" + tenant.Name
+	}
+	return ""
+}
+
+// cached 优先从缓存读取歌曲地址，未命中时请求上游并写入缓存；
+// 存在过期缓存时由 fetchOrStale 决定返回上游结果还是过期缓存
+func (s *SongURLService) cached(ctx context.Context, songID int64, level, realIP string, nocache bool) (*SongURLResponse, cacheStatus, error) {
+	status := cacheDisabled
+	switch {
+	case responseCache == nil:
+	case nocache:
+		status = cacheBypass
+	default:
+		var resp SongURLResponse
+		if cacheGetJSON(ctx, songCacheKey(ctx, songID, level, realIP), &resp) {
+			return &resp, cacheHit, nil
+		}
+		status = cacheMiss
+		if stale := loadStaleSongURL(ctx, songID, level, realIP); stale != nil {
+			return s.fetchOrStale(ctx, songID, level, realIP, stale)
+		}
+	}
+
+	resp, err := s.fetchAndStore(ctx, songID, level, realIP)
+	if err != nil {
+		return nil, status, err
+	}
+	return resp, status, nil
+}
+
+// fetchAndStore 请求上游并写入缓存，有播放地址时同时写入保留更久的过期缓存
+func (s *SongURLService) fetchAndStore(ctx context.Context, songID int64, level, realIP string) (*SongURLResponse, error) {
+	fetchTime := time.Now()
+	resp, err := s.fetchShared(ctx, songID, level, realIP)
+	if err != nil {
+		return nil, err
+	}
+	if hasPlayableURL(resp) && resp.Data[0].Expi > 0 {
+		resp.ExpiresAt = fetchTime.Add(time.Duration(resp.Data[0].Expi) * time.Second).UTC().Format(time.RFC3339)
+	}
+
+	if responseCache != nil && resp.Code == 200 && len(resp.Data) > 0 {
+		// 地址在 fetchTime + expi 后失效，预留安全余量避免返回即将过期的地址
+		expiresAt := fetchTime.Add(time.Duration(resp.Data[0].Expi)*time.Second - config.Current().CacheTTLSafety)
+		ttl := time.Until(expiresAt)
+		if config.Current().CacheMaxTTL > 0 && ttl > config.Current().CacheMaxTTL {
+			ttl = config.Current().CacheMaxTTL
+		}
+		if ttl > 0 {
+			cacheSetJSON(ctx, songCacheKey(ctx, songID, level, realIP), resp, ttl)
+			if staleMaxAge := config.Current().StaleMaxAge; staleMaxAge > 0 && hasPlayableURL(resp) {
+				cacheSetJSON(ctx, staleSongCacheKey(ctx, songID, level, realIP), resp, ttl+staleMaxAge)
+			}
+		}
+	}
+	return resp, nil
+}
+
+// loadStaleSongURL 读取过期缓存，播放地址已超过 expi 失效时视为不存在
+func loadStaleSongURL(ctx context.Context, songID int64, level, realIP string) *SongURLResponse {
+	if config.Current().StaleMaxAge <= 0 {
+		return nil
+	}
+	data, ok := responseCache.Get(ctx, staleSongCacheKey(ctx, songID, level, realIP))
+	if !ok {
+		return nil
+	}
+	var resp SongURLResponse
+	if json.Unmarshal(data, &resp) != nil {
+		return nil
+	}
+	if remaining, ok := urlValidFor(&resp); !ok || remaining <= 0 {
+		return nil
+	}
+	return &resp
+}
+
+// fetchOrStale 存在过期缓存时请求上游：上游失败、Cookie失效或超过 STALE_SOFT_TIMEOUT 时返回过期缓存，
+// 超过软时限的请求在后台继续完成并刷新缓存
+func (s *SongURLService) fetchOrStale(ctx context.Context, songID int64, level, realIP string, stale *SongURLResponse) (*SongURLResponse, cacheStatus, error) {
+	type result struct {
+		resp *SongURLResponse
+		err  error
+	}
+	done := make(chan result, 1)
+	// 返回过期缓存后刷新仍需完成，不随请求结束而取消
+	refreshCtx := context.WithoutCancel(ctx)
+	go func() {
+		resp, err := s.fetchAndStore(refreshCtx, songID, level, realIP)
+		done <- result{resp, err}
+	}()
+
+	var softTimeout <-chan time.Time
+	if d := config.Current().StaleSoftTimeout; d > 0 {
+		timer := time.NewTimer(d)
+		defer timer.Stop()
+		softTimeout = timer.C
+	}
+
+	log := logging.From(ctx).With("song_id", songID, "level", level)
+	select {
+	case r := <-done:
+		switch {
+		case r.err != nil:
+			log.Warn("upstream request failed, serving stale cache entry", "error", r.err)
+		case cookieFailureCodes[r.resp.Code]:
+			log.Warn("upstream rejected the cookie, serving stale cache entry", "upstream_code", r.resp.Code)
+		default:
+			return r.resp, cacheMiss, nil
+		}
+		metrics.ObserveStaleServe("error")
+	case <-softTimeout:
+		log.Warn("upstream request exceeded soft timeout, serving stale cache entry and refreshing in background",
+			"soft_timeout", config.Current().StaleSoftTimeout.String())
+		metrics.ObserveStaleServe("timeout")
+	case <-ctx.Done():
+		return nil, cacheMiss, errUpstreamCanceled
+	}
+	return stale, cacheStale, nil
+}
+
+// urlValidFor 返回播放地址距 ExpiresAt 失效的剩余时间，响应中没有 ExpiresAt 时第二个返回值为 false
+func urlValidFor(resp *SongURLResponse) (time.Duration, bool) {
+	expiresAt, err := time.Parse(time.RFC3339, resp.ExpiresAt)
+	if err != nil {
+		return 0, false
+	}
+	return time.Until(expiresAt), true
+}
+
+// 合并同一歌曲、音质与 realIP 的并发上游请求
+var songURLGroup singleflight.Group
+
+// fetchShared 同一 (songID, level, realIP) 的并发请求只向上游请求一次，等待者共享成功的结果；
+// realIP 会影响上游按地区返回的结果，不同 realIP 或租户的请求不合并。
+// 失败结果不共享，等待者各自重新请求，避免一次瞬时故障或发起者断开影响所有并发请求
+func (s *SongURLService) fetchShared(ctx context.Context, songID int64, level, realIP string) (*SongURLResponse, error) {
+	leader := false
+	v, err, _ := songURLGroup.Do(fmt.Sprintf("%d:%s:%s", songID, level, realIP)+tenantKeySuffix(ctx), func() (any, error) {
+		leader = true
+		return s.fetch(ctx, songID, level, realIP)
+	})
+	if err != nil && !leader {
+		v, err = s.fetch(ctx, songID, level, realIP)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !leader {
+		logging.From(ctx).Debug("deduplicated concurrent upstream request", "song_id", songID, "level", level)
+	}
+	// 调用方会修改 RequestedLevel 等字段，每个请求返回独立的副本
+	return &SongURLResponse{SongURLResponse: *v.(*netease.SongURLResponse)}, nil
+}
+
+// fetch 向上游请求单首歌曲的播放地址
+func (s *SongURLService) fetch(ctx context.Context, songID int64, level, realIP string) (*netease.SongURLResponse, error) {
+	resp, err := s.client.SongURL(ctx, songID, level, realIP)
+	// 非JSON响应在请求上游时已记录
+	var nonJSON *netease.NonJSONError
+	if errors.Is(err, netease.ErrParse) && !errors.As(err, &nonJSON) {
+		logging.From(ctx).Error("error parsing upstream JSON response", "error", err)
+	}
+	return resp, err
+}
+
+type memoryCacheEntry struct {
+	key       string
+	value     []byte
+	expiresAt time.Time
+}
+
+// size 返回缓存项的估算内存：键与值的长度加上每项的固定开销
+func (e *memoryCacheEntry) size() int {
+	return len(e.key) + len(e.value) + memoryCacheEntryOverhead
+}
+
+// memoryCache 进程内LRU缓存，条目数超过 maxEntries 或估算内存超过 maxBytes 时淘汰最久未访问的项
+type memoryCache struct {
+	mu         sync.Mutex
+	maxEntries int
+	// maxBytes 为0表示只限制条目数；单项超过 maxBytes 时不缓存
+	maxBytes int
+	bytes    int
+	ll       *list.List
+	items    map[string]*list.Element
+	// onExpire 已过期的项被移除 (访问、覆盖、淘汰或定期清理) 时以其键调用，在锁外调用；为 nil 时不通知
+	onExpire func(key string)
+}
+
+func newMemoryCache(maxEntries int) *memoryCache {
+	return &memoryCache{
+		maxEntries: maxEntries,
+		ll:         list.New(),
+		items:      make(map[string]*list.Element),
+	}
+}
+
+// Get 返回未过期的缓存项，过期项会被顺带移除并通知 onExpire
+func (c *memoryCache) Get(_ context.Context, key string) ([]byte, bool) {
+	c.mu.Lock()
+	elem, ok := c.items[key]
+	if !ok {
+		c.mu.Unlock()
+		return nil, false
+	}
+
+	entry := elem.Value.(*memoryCacheEntry)
+	if !time.Now().Before(entry.expiresAt) {
+		c.removeElement(elem)
+		c.mu.Unlock()
+		c.notifyExpired([]string{key})
+		return nil, false
+	}
+
+	c.ll.MoveToFront(elem)
+	value := entry.value
+	c.mu.Unlock()
+	return value, true
+}
+
+func (c *memoryCache) Set(_ context.Context, key string, value []byte, ttl time.Duration) {
+	now := time.Now()
+	var expired []string
+	defer func() { c.notifyExpired(expired) }()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.items[key]
+	if ok {
+		entry := elem.Value.(*memoryCacheEntry)
+		if !now.Before(entry.expiresAt) {
+			expired = append(expired, key)
+		}
+		if c.tooLarge(key, value) {
+			// 新值无法缓存，移除旧值以免继续返回
+			c.removeElement(elem)
+			return
+		}
+		c.bytes += len(value) - len(entry.value)
+		entry.value = value
+		entry.expiresAt = now.Add(ttl)
+		c.ll.MoveToFront(elem)
+	} else {
+		if c.tooLarge(key, value) {
+			return
+		}
+		entry := &memoryCacheEntry{key: key, value: value, expiresAt: now.Add(ttl)}
+		c.items[key] = c.ll.PushFront(entry)
+		c.bytes += entry.size()
+	}
+
+	for c.ll.Len() > c.maxEntries || c.maxBytes > 0 && c.bytes > c.maxBytes {
+		oldest := c.ll.Back().Value.(*memoryCacheEntry)
+		if !now.Before(oldest.expiresAt) {
+			expired = append(expired, oldest.key)
+		}
+		c.removeElement(c.ll.Back())
+	}
+}
+
+// tooLarge 判断单项是否超过 maxBytes
+func (c *memoryCache) tooLarge(key string, value []byte) bool {
+	return c.maxBytes > 0 && len(key)+len(value)+memoryCacheEntryOverhead > c.maxBytes
+}
+
+// notifyExpired 以已移除的过期项的键调用 onExpire，调用方不能持有锁
+func (c *memoryCache) notifyExpired(keys []string) {
+	if c.onExpire == nil {
+		return
+	}
+	for _, key := range keys {
+		c.onExpire(key)
+	}
+}
+
+func (c *memoryCache) Purge(_ context.Context) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	n := c.ll.Len()
+	c.ll.Init()
+	c.items = make(map[string]*list.Element)
+	c.bytes = 0
+	return n, nil
+}
+
+func (c *memoryCache) Delete(_ context.Context, pattern string) (int, error) {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return 0, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	deleted := 0
+	for key, elem := range c.items {
+		if ok, _ := path.Match(pattern, key); ok {
+			c.removeElement(elem)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+// 每个缓存项在键与值之外的估算开销：链表节点、map 项与 memoryCacheEntry
+const memoryCacheEntryOverhead = 128
+
+// Stats 的内存为键与值的长度加上每项的估算开销，不含已过期但尚未移除的项
+func (c *memoryCache) Stats(_ context.Context) (CacheStats, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var stats CacheStats
+	now := time.Now()
+	for elem := c.ll.Front(); elem != nil; elem = elem.Next() {
+		entry := elem.Value.(*memoryCacheEntry)
+		if !now.Before(entry.expiresAt) {
+			continue
+		}
+		stats.Entries++
+		stats.MemoryBytes += int64(entry.size())
+	}
+	return stats, nil
+}
+
+// Keys 按LRU链表顺序返回，链表头为最近访问的项
+func (c *memoryCache) Keys(_ context.Context, limit int) ([]CacheKey, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	keys := make([]CacheKey, 0, min(limit, c.ll.Len()))
+	now := time.Now()
+	for elem := c.ll.Front(); elem != nil && len(keys) < limit; elem = elem.Next() {
+		entry := elem.Value.(*memoryCacheEntry)
+		if !now.Before(entry.expiresAt) {
+			continue
+		}
+		keys = append(keys, CacheKey{Key: entry.key, TTLSeconds: int(entry.expiresAt.Sub(now).Seconds())})
+	}
+	return keys, nil
+}
+
+// reapExpired 移除已过期的项并返回其键，Get 只在访问时移除过期项
+func (c *memoryCache) reapExpired(now time.Time) []string {
+	c.mu.Lock()
+	var keys []string
+	for key, elem := range c.items {
+		if !now.Before(elem.Value.(*memoryCacheEntry).expiresAt) {
+			c.removeElement(elem)
+			keys = append(keys, key)
+		}
+	}
+	c.mu.Unlock()
+
+	c.notifyExpired(keys)
+	return keys
+}
+
+// Len 返回当前缓存项数量
+func (c *memoryCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ll.Len()
+}
+
+func (c *memoryCache) removeElement(elem *list.Element) {
+	entry := elem.Value.(*memoryCacheEntry)
+	c.ll.Remove(elem)
+	delete(c.items, entry.key)
+	c.bytes -= entry.size()
+}
@@ -0,0 +1,264 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"PMS/internal/config"
+	"PMS/internal/logging"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Redis不可用期间检查其是否恢复的间隔
+const redisRecheckInterval = 10 * time.Second
+
+// redisCache 基于Redis的共享缓存，多个PMS实例可共用；Redis不可用期间读写改用进程内的 fallback，
+// 恢复后切回Redis并清空 fallback。fallback 为 nil 时不可用期间不缓存
+type redisCache struct {
+	client   *redis.Client
+	timeout  time.Duration
+	fallback *memoryCache
+	down     atomic.Bool
+}
+
+func newRedisCache(opts *redis.Options, timeout time.Duration, fallback *memoryCache) *redisCache {
+	opts.DialTimeout = timeout
+	opts.ReadTimeout = timeout
+	opts.WriteTimeout = timeout
+	return &redisCache{
+		client:   redis.NewClient(opts),
+		timeout:  timeout,
+		fallback: fallback,
+	}
+}
+
+// redisOptions 按 REDIS_URL 或 REDIS_ADDR、REDIS_PASSWORD、REDIS_DB 生成连接配置，REDIS_URL 优先
+func redisOptions(cfg *config.Config) *redis.Options {
+	if cfg.RedisURL != "" {
+		// REDIS_URL 已在加载配置时校验
+		if opts, err := redis.ParseURL(cfg.RedisURL); err == nil {
+			return opts
+		}
+	}
+	return &redis.Options{Addr: cfg.RedisAddr, Password: cfg.RedisPassword, DB: cfg.RedisDB}
+}
+
+// checkStartup 启动时检查Redis是否可用，不可用时先改用内存缓存
+func (c *redisCache) checkStartup(ctx context.Context) {
+	pingCtx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	if err := c.client.Ping(pingCtx).Err(); err != nil {
+		c.markDown(ctx, err)
+	}
+}
+
+// markDown 读写Redis失败时改用内存缓存，并在后台等待Redis恢复；ctx 为请求的上下文，请求已取消或超时导致的失败不计入
+func (c *redisCache) markDown(ctx context.Context, err error) {
+	if errors.Is(err, redis.Nil) || ctx.Err() != nil {
+		return
+	}
+	if !c.down.CompareAndSwap(false, true) {
+		return
+	}
+	logging.Logger.Warn("redis cache unavailable, falling back to in-memory cache", "error", err)
+	go c.waitForRecovery()
+}
+
+// waitForRecovery 定期检查Redis，恢复后切回Redis；不可用期间写入内存的缓存项随即清空，
+// 避免Redis中被其他实例更新或清除的项继续从内存返回
+func (c *redisCache) waitForRecovery() {
+	ticker := time.NewTicker(redisRecheckInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+		err := c.client.Ping(ctx).Err()
+		cancel()
+		if err != nil {
+			continue
+		}
+		c.down.Store(false)
+		if c.fallback != nil {
+			c.fallback.Purge(context.Background())
+		}
+		logging.Logger.Info("redis cache reachable again, switching back from in-memory cache")
+		return
+	}
+}
+
+// usingFallback 返回Redis不可用时是否改用内存缓存
+func (c *redisCache) usingFallback() bool {
+	return c.down.Load()
+}
+
+// Get 读取失败或超时均视为未命中，不阻塞歌曲请求
+func (c *redisCache) Get(ctx context.Context, key string) ([]byte, bool) {
+	if c.usingFallback() {
+		if c.fallback == nil {
+			return nil, false
+		}
+		return c.fallback.Get(ctx, key)
+	}
+
+	redisCtx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	data, err := c.client.Get(redisCtx, key).Bytes()
+	if err != nil {
+		if !errors.Is(err, redis.Nil) {
+			logging.From(ctx).Warn("error reading from redis cache", "cache_key", key, "error", err)
+			c.markDown(ctx, err)
+		}
+		return nil, false
+	}
+	return data, true
+}
+
+func (c *redisCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) {
+	if c.usingFallback() {
+		if c.fallback != nil {
+			c.fallback.Set(ctx, key, value, ttl)
+		}
+		return
+	}
+
+	redisCtx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	if err := c.client.Set(redisCtx, key, value, ttl).Err(); err != nil {
+		logging.From(ctx).Warn("error writing to redis cache", "cache_key", key, "error", err)
+		c.markDown(ctx, err)
+	}
+}
+
+// 每轮 SCAN 返回的键数量
+const redisScanBatch = 500
+
+// Purge 以 SCAN 逐批删除 pms: 前缀的键，共用同一Redis的其他数据不受影响；
+// 多个PMS实例共用Redis时会一并清空它们的缓存。内存中的缓存项同时清空
+func (c *redisCache) Purge(ctx context.Context) (int, error) {
+	return c.Delete(ctx, "pms:*")
+}
+
+// Delete 以 SCAN 逐批删除匹配 pattern 的键，同时删除内存中匹配的缓存项；Redis不可用时只删除内存中的项
+func (c *redisCache) Delete(ctx context.Context, pattern string) (int, error) {
+	deleted := 0
+	if c.fallback != nil {
+		n, err := c.fallback.Delete(ctx, pattern)
+		if err != nil {
+			return 0, err
+		}
+		deleted += n
+	}
+	if c.usingFallback() {
+		return deleted, nil
+	}
+
+	err := c.scan(ctx, pattern, func(scanCtx context.Context, keys []string) error {
+		n, err := c.client.Del(scanCtx, keys...).Result()
+		deleted += int(n)
+		return err
+	})
+	return deleted, err
+}
+
+// Stats 的内存为各键 MEMORY USAGE 之和，需要遍历全部 pms: 键；Redis不可用时返回内存缓存的统计
+func (c *redisCache) Stats(ctx context.Context) (CacheStats, error) {
+	if c.usingFallback() {
+		if c.fallback == nil {
+			return CacheStats{}, nil
+		}
+		return c.fallback.Stats(ctx)
+	}
+
+	var stats CacheStats
+	err := c.scan(ctx, "pms:*", func(scanCtx context.Context, keys []string) error {
+		pipe := c.client.Pipeline()
+		usage := make([]*redis.IntCmd, len(keys))
+		for i, key := range keys {
+			usage[i] = pipe.MemoryUsage(scanCtx, key)
+		}
+		if _, err := pipe.Exec(scanCtx); err != nil && !errors.Is(err, redis.Nil) {
+			return err
+		}
+		for _, cmd := range usage {
+			// 遍历期间过期的键返回 redis.Nil，不计入
+			if n, err := cmd.Result(); err == nil {
+				stats.Entries++
+				stats.MemoryBytes += n
+			}
+		}
+		return nil
+	})
+	return stats, err
+}
+
+// Keys 遍历全部 pms: 键，按 OBJECT IDLETIME 从小到大排序，即最近访问的在前；Redis不可用时返回内存缓存中的键
+func (c *redisCache) Keys(ctx context.Context, limit int) ([]CacheKey, error) {
+	if c.usingFallback() {
+		if c.fallback == nil {
+			return []CacheKey{}, nil
+		}
+		return c.fallback.Keys(ctx, limit)
+	}
+
+	type idleKey struct {
+		CacheKey
+		idle time.Duration
+	}
+	var all []idleKey
+	err := c.scan(ctx, "pms:*", func(scanCtx context.Context, keys []string) error {
+		pipe := c.client.Pipeline()
+		idle := make([]*redis.DurationCmd, len(keys))
+		ttl := make([]*redis.DurationCmd, len(keys))
+		for i, key := range keys {
+			idle[i] = pipe.ObjectIdleTime(scanCtx, key)
+			ttl[i] = pipe.PTTL(scanCtx, key)
+		}
+		if _, err := pipe.Exec(scanCtx); err != nil && !errors.Is(err, redis.Nil) {
+			return err
+		}
+		for i, key := range keys {
+			idleTime, err := idle[i].Result()
+			remaining := ttl[i].Val()
+			// 遍历期间过期的键没有空闲时间，PTTL 为负
+			if err != nil || remaining <= 0 {
+				continue
+			}
+			all = append(all, idleKey{CacheKey{Key: key, TTLSeconds: int(remaining.Seconds())}, idleTime})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(all, func(i, j int) bool { return all[i].idle < all[j].idle })
+	keys := make([]CacheKey, 0, min(limit, len(all)))
+	for _, k := range all[:min(limit, len(all))] {
+		keys = append(keys, k.CacheKey)
+	}
+	return keys, nil
+}
+
+// scan 以 SCAN 逐批遍历匹配 pattern 的键，每批键交给 fn 处理，每批请求单独计算超时
+func (c *redisCache) scan(ctx context.Context, pattern string, fn func(ctx context.Context, keys []string) error) error {
+	var cursor uint64
+	for {
+		scanCtx, cancel := context.WithTimeout(ctx, c.timeout)
+		keys, next, err := c.client.Scan(scanCtx, cursor, pattern, redisScanBatch).Result()
+		if err == nil && len(keys) > 0 {
+			err = fn(scanCtx, keys)
+		}
+		cancel()
+		if err != nil {
+			return err
+		}
+		if cursor = next; cursor == 0 {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,249 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"PMS/internal/netease"
+)
+
+func TestMemoryCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	ctx := context.Background()
+	cache := newMemoryCache(2)
+	cache.Set(ctx, "a", []byte("1"), time.Minute)
+	cache.Set(ctx, "b", []byte("2"), time.Minute)
+
+	// 访问 a 后 b 成为最久未使用的项
+	if _, ok := cache.Get(ctx, "a"); !ok {
+		t.Fatal("Get(a) missed before eviction")
+	}
+	cache.Set(ctx, "c", []byte("3"), time.Minute)
+
+	if n := cache.Len(); n != 2 {
+		t.Errorf("Len = %d, want 2", n)
+	}
+	if _, ok := cache.Get(ctx, "b"); ok {
+		t.Error("Get(b) hit, want it evicted as least recently used")
+	}
+	for _, key := range []string{"a", "c"} {
+		if _, ok := cache.Get(ctx, key); !ok {
+			t.Errorf("Get(%s) missed, want it kept", key)
+		}
+	}
+}
+
+func TestMemoryCacheSetRefreshesExistingKey(t *testing.T) {
+	ctx := context.Background()
+	cache := newMemoryCache(2)
+	cache.Set(ctx, "a", []byte("1"), time.Minute)
+	cache.Set(ctx, "b", []byte("2"), time.Minute)
+	cache.Set(ctx, "a", []byte("updated"), time.Minute)
+	cache.Set(ctx, "c", []byte("3"), time.Minute)
+
+	if value, ok := cache.Get(ctx, "a"); !ok || string(value) != "updated" {
+		t.Errorf("Get(a) = %q, %t; want updated value kept", value, ok)
+	}
+	if _, ok := cache.Get(ctx, "b"); ok {
+		t.Error("Get(b) hit, want it evicted")
+	}
+}
+
+func TestMemoryCacheExpiresEntries(t *testing.T) {
+	ctx := context.Background()
+	cache := newMemoryCache(10)
+	cache.Set(ctx, "short", []byte("1"), 20*time.Millisecond)
+	cache.Set(ctx, "long", []byte("2"), time.Minute)
+
+	if _, ok := cache.Get(ctx, "short"); !ok {
+		t.Fatal("Get(short) missed before its TTL")
+	}
+	time.Sleep(30 * time.Millisecond)
+
+	if _, ok := cache.Get(ctx, "short"); ok {
+		t.Error("Get(short) hit after its TTL")
+	}
+	if n := cache.Len(); n != 1 {
+		t.Errorf("Len = %d, want expired entry removed on Get", n)
+	}
+	if stats, _ := cache.Stats(ctx); stats.Entries != 1 {
+		t.Errorf("Stats.Entries = %d, want 1", stats.Entries)
+	}
+
+	cache.Set(ctx, "reaped", []byte("3"), time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+	if keys := cache.reapExpired(time.Now()); len(keys) != 1 || keys[0] != "reaped" {
+		t.Errorf("reapExpired = %v, want [reaped]", keys)
+	}
+}
+
+func TestMemoryCacheBoundedByBytes(t *testing.T) {
+	ctx := context.Background()
+	cache := newMemoryCache(10)
+	// 每项估算为 1+100+开销，上限只够容纳两项
+	cache.maxBytes = 2 * (1 + 100 + memoryCacheEntryOverhead)
+	value := make([]byte, 100)
+	cache.Set(ctx, "a", value, time.Minute)
+	cache.Set(ctx, "b", value, time.Minute)
+	cache.Set(ctx, "c", value, time.Minute)
+
+	if _, ok := cache.Get(ctx, "a"); ok {
+		t.Error("Get(a) hit, want it evicted once total bytes exceed maxBytes")
+	}
+	if n := cache.Len(); n != 2 {
+		t.Errorf("Len = %d, want 2", n)
+	}
+	if cache.bytes > cache.maxBytes {
+		t.Errorf("bytes = %d, want at most %d", cache.bytes, cache.maxBytes)
+	}
+
+	// 超过上限的单项不缓存，且不会挤掉已有的项
+	cache.Set(ctx, "huge", make([]byte, cache.maxBytes), time.Minute)
+	if _, ok := cache.Get(ctx, "huge"); ok {
+		t.Error("Get(huge) hit, want oversized value not cached")
+	}
+	if n := cache.Len(); n != 2 {
+		t.Errorf("Len = %d after oversized Set, want 2", n)
+	}
+
+	// 以过大的值覆盖已有的键会移除旧值
+	cache.Set(ctx, "b", make([]byte, cache.maxBytes), time.Minute)
+	if _, ok := cache.Get(ctx, "b"); ok {
+		t.Error("Get(b) hit, want stale value removed when overwritten by an oversized one")
+	}
+	if want := 1 + 100 + memoryCacheEntryOverhead; cache.bytes != want {
+		t.Errorf("bytes = %d, want %d", cache.bytes, want)
+	}
+
+	cache.Purge(ctx)
+	if cache.bytes != 0 {
+		t.Errorf("bytes = %d after Purge, want 0", cache.bytes)
+	}
+}
+
+func TestGetSongURLCache(t *testing.T) {
+	useTestConfig(t, nil)
+	useResponseCache(t, newMemoryCache(10))
+	fake := netease.NewFake()
+	fake.SetSongURL(1, "standard", playableSong(1))
+	s := NewSongURLService(fake)
+
+	steps := []struct {
+		target    string
+		wantCache string
+		wantCalls int
+	}{
+		{target: "/song?id=1", wantCache: "MISS", wantCalls: 1},
+		{target: "/song?id=1", wantCache: "HIT", wantCalls: 1},
+		{target: "/song?id=1&nocache=1", wantCache: "BYPASS", wantCalls: 2},
+		{target: "/song?id=1", wantCache: "HIT", wantCalls: 2},
+	}
+	for _, step := range steps {
+		w := serve(s.GetSongURL, http.MethodGet, step.target)
+		if w.Code != http.StatusOK {
+			t.Fatalf("GET %s: status = %d; body %s", step.target, w.Code, w.Body)
+		}
+		if got := w.Header().Get("X-PMS-Cache"); got != step.wantCache {
+			t.Errorf("GET %s: X-PMS-Cache = %q, want %q", step.target, got, step.wantCache)
+		}
+		if got := fake.Calls(1, "standard"); got != step.wantCalls {
+			t.Errorf("GET %s: upstream calls = %d, want %d", step.target, got, step.wantCalls)
+		}
+	}
+}
+
+func TestGetSongURLCacheTTLCappedByMaxTTL(t *testing.T) {
+	useTestConfig(t, map[string]string{"CACHE_MAX_TTL": "50ms", "STALE_MAX_AGE": "0"})
+	useResponseCache(t, newMemoryCache(10))
+	fake := netease.NewFake()
+	fake.SetSongURL(1, "standard", playableSong(1))
+	s := NewSongURLService(fake)
+
+	serve(s.GetSongURL, http.MethodGet, "/song?id=1")
+	if w := serve(s.GetSongURL, http.MethodGet, "/song?id=1"); w.Header().Get("X-PMS-Cache") != "HIT" {
+		t.Fatalf("X-PMS-Cache = %q before CACHE_MAX_TTL, want HIT", w.Header().Get("X-PMS-Cache"))
+	}
+	time.Sleep(80 * time.Millisecond)
+
+	if w := serve(s.GetSongURL, http.MethodGet, "/song?id=1"); w.Header().Get("X-PMS-Cache") != "MISS" {
+		t.Errorf("X-PMS-Cache = %q after CACHE_MAX_TTL, want MISS", w.Header().Get("X-PMS-Cache"))
+	}
+	if got := fake.Calls(1, "standard"); got != 2 {
+		t.Errorf("upstream calls = %d, want 2", got)
+	}
+}
+
+func TestGetSongURLCacheDisabled(t *testing.T) {
+	useTestConfig(t, nil)
+	useResponseCache(t, nil)
+	fake := netease.NewFake()
+	fake.SetSongURL(1, "standard", playableSong(1))
+	s := NewSongURLService(fake)
+
+	for range 2 {
+		if w := serve(s.GetSongURL, http.MethodGet, "/song?id=1"); w.Header().Get("X-PMS-Cache") != "" {
+			t.Errorf("X-PMS-Cache = %q with cache disabled, want no header", w.Header().Get("X-PMS-Cache"))
+		}
+	}
+	if got := fake.Calls(1, "standard"); got != 2 {
+		t.Errorf("upstream calls = %d, want every request to reach upstream", got)
+	}
+}
+
+// regionClient 对 blockedIP 的请求答复歌曲没有播放地址，模拟上游按地区返回不同结果
+type regionClient struct {
+	netease.Client
+	blockedIP string
+}
+
+func (c regionClient) SongURL(ctx context.Context, id int64, level, realIP string) (*netease.SongURLResponse, error) {
+	resp, err := c.Client.SongURL(ctx, id, level, realIP)
+	if err == nil && realIP == c.blockedIP {
+		return unavailableSong(id), nil
+	}
+	return resp, err
+}
+
+func TestGetSongURLCacheKeyedByRealIP(t *testing.T) {
+	useTestConfig(t, map[string]string{"NEGATIVE_CACHE_TTL": "1m"})
+	useResponseCache(t, newMemoryCache(10))
+	fake := netease.NewFake()
+	fake.SetSongURL(1, "standard", playableSong(1))
+	s := NewSongURLService(regionClient{Client: fake, blockedIP: "1.1.1.1"})
+
+	steps := []struct {
+		target     string
+		wantStatus int
+		wantCache  string
+		wantCalls  int
+	}{
+		{target: "/song?id=1&realip=1.1.1.1", wantStatus: http.StatusNotFound, wantCache: "MISS", wantCalls: 1},
+		{target: "/song?id=1&realip=1.1.1.1", wantStatus: http.StatusNotFound, wantCache: "NEGATIVE", wantCalls: 1},
+		// 其他地区的结果不受 1.1.1.1 的无结果缓存影响，各自缓存
+		{target: "/song?id=1&realip=2.2.2.2", wantStatus: http.StatusOK, wantCache: "MISS", wantCalls: 2},
+		{target: "/song?id=1&realip=2.2.2.2", wantStatus: http.StatusOK, wantCache: "HIT", wantCalls: 2},
+		{target: "/song?id=1&realip=3.3.3.3", wantStatus: http.StatusOK, wantCache: "MISS", wantCalls: 3},
+	}
+	for _, step := range steps {
+		w := serve(s.GetSongURL, http.MethodGet, step.target)
+		if w.Code != step.wantStatus {
+			t.Fatalf("GET %s: status = %d, want %d; body %s", step.target, w.Code, step.wantStatus, w.Body)
+		}
+		if got := w.Header().Get("X-PMS-Cache"); got != step.wantCache {
+			t.Errorf("GET %s: X-PMS-Cache = %q, want %q", step.target, got, step.wantCache)
+		}
+		if got := fake.Calls(1, "standard"); got != step.wantCalls {
+			t.Errorf("GET %s: upstream calls = %d, want %d", step.target, got, step.wantCalls)
+		}
+	}
+
+	// 按歌曲删除缓存时删除所有 realIP 的缓存项
+	w := serve(DeleteAdminCache, http.MethodDelete, "/admin/cache?id=1")
+	if resp := decodeBody[AdminCachePurgeResponse](t, w); resp.Purged == 0 {
+		t.Fatalf("purged = %d, want the cached entries of every realIP", resp.Purged)
+	}
+	if n := responseCache.(*memoryCache).Len(); n != 0 {
+		t.Errorf("%d cache entries left after evicting the song", n)
+	}
+}
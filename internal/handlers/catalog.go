@@ -0,0 +1,13 @@
+package handlers
+
+import "PMS/internal/netease"
+
+// CatalogService 获取歌词、歌曲详情、歌单、专辑、歌手、搜索结果与封面，负责结果的缓存与整理；
+// 上游通过构造时传入的 netease.Client 访问
+type CatalogService struct {
+	client netease.Client
+}
+
+func NewCatalogService(client netease.Client) *CatalogService {
+	return &CatalogService{client: client}
+}
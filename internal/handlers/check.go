@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"PMS/internal/api"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CheckSongsRequest POST /check 的请求体
+type CheckSongsRequest struct {
+	IDs []int64 `json:"ids"`
+}
+
+// SongAvailability 单首歌曲在指定音质下的可用性，失败时仅包含 available 与 error 字段
+type SongAvailability struct {
+	// 上游返回了播放地址，试听片段同样视为可用，由 trial 区分
+	Available bool   `json:"available"`
+	Code      int    `json:"code,omitempty"`
+	Fee       int    `json:"fee"`
+	Trial     bool   `json:"trial"`
+	Error     string `json:"error,omitempty"`
+}
+
+type CheckSongsResponse struct {
+	Code    int                            `json:"code"`
+	Level   string                         `json:"level"`
+	Results batchResults[SongAvailability] `json:"results"`
+}
+
+// CheckSongs 处理 POST /check?level=，并发查询多首歌曲在指定音质下是否可播放；
+// 只返回可用性，不返回也不缓存播放地址
+func (s *SongURLService) CheckSongs(c *gin.Context) {
+	var req CheckSongsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, api.NewErrorResponse(c, 400, "Invalid request body"))
+		return
+	}
+
+	level := c.DefaultQuery("level", defaultLevel(c))
+	if !checkLevel(c, level) {
+		return
+	}
+	realIP := c.DefaultQuery("realip", defaultRealIP(c))
+
+	ids := make([]string, len(req.IDs))
+	for i, id := range req.IDs {
+		ids[i] = strconv.FormatInt(id, 10)
+	}
+	ids, ok := batchIDs(c, ids)
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+	results := fanOutSongIDs(ctx, ids, func(songID int64) SongAvailability {
+		resp, err := s.fetchShared(ctx, songID, level, realIP)
+		switch {
+		case err != nil:
+			return SongAvailability{Error: upstreamErrorMessage(err)}
+		case resp.Code != 200:
+			return SongAvailability{Code: resp.Code, Error: upstreamCodeMessage(resp.Code)}
+		case len(resp.Data) == 0:
+			// 上游正常响应但没有该歌曲，与 /song 一样视为 not_found
+			return SongAvailability{Code: 404, Error: songUnavailableMessages[reasonNotFound]}
+		}
+		data := resp.Data[0]
+		return SongAvailability{
+			Available: data.Code == 200 && data.URL != "",
+			Code:      data.Code,
+			Fee:       data.Fee,
+			Trial:     data.FreeTrialInfo != nil,
+		}
+	}, func(message string) SongAvailability {
+		return SongAvailability{Error: message}
+	})
+	if clientGone(c) {
+		return
+	}
+
+	c.JSON(http.StatusOK, CheckSongsResponse{Code: 200, Level: level, Results: results})
+}
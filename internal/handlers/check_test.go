@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"PMS/internal/netease"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestCheckSongs(t *testing.T) {
+	useTestConfig(t, nil)
+	useResponseCache(t, nil)
+	fake := netease.NewFake()
+	fake.SetSongURL(1, "standard", playableSong(1))
+	fake.SetSongURL(2, "standard", &netease.SongURLResponse{Code: 200})
+	fake.SetSongURL(3, "standard", &netease.SongURLResponse{Code: 301})
+
+	r := gin.New()
+	r.POST("/check", NewSongURLService(fake).CheckSongs)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/check", strings.NewReader(`{"ids":[1,2,3]}`)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200; body %s", w.Code, w.Body)
+	}
+
+	resp := decodeBody[struct {
+		Results map[string]SongAvailability `json:"results"`
+	}](t, w)
+	want := map[string]SongAvailability{
+		"1": {Available: true, Code: 200},
+		"2": {Code: 404, Error: songUnavailableMessages[reasonNotFound]},
+		"3": {Code: 301, Error: upstreamCodeMessage(301)},
+	}
+	for id, wantResult := range want {
+		if got := resp.Results[id]; got != wantResult {
+			t.Errorf("results[%s] = %+v, want %+v", id, got, wantResult)
+		}
+	}
+}
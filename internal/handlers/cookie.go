@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"PMS/internal/config"
+	"PMS/internal/logging"
+	"PMS/internal/netease"
+)
+
+// cookieState 当前Cookie配置、由其解析出的Cookie池、更新时间及池中最早的 Expires（均未携带时为零值）
+type cookieState struct {
+	value     string
+	pool      *cookiePool
+	updatedAt time.Time
+	expiresAt time.Time
+}
+
+// 当前使用的网易云Cookie，SIGHUP 或管理接口更新时原子替换，进行中的请求不受影响
+var cookieValue atomic.Pointer[cookieState]
+
+func init() {
+	logging.SetSecretValues(cookieSecrets)
+}
+
+// cookieSecrets 返回当前Cookie池中每个Cookie与各租户Cookie的原文，日志中这些值会被整体隐藏
+func cookieSecrets() []string {
+	var values []string
+	if state := cookieValue.Load(); state != nil {
+		for _, slot := range state.pool.slots {
+			values = append(values, slot.value)
+		}
+	}
+	if cfg := config.Current(); cfg != nil {
+		for _, tenant := range cfg.Tenants {
+			values = append(values, tenant.Cookie)
+		}
+	}
+	return values
+}
+
+// currentCookie 返回当前Cookie配置原文（可能包含多个Cookie），为空表示匿名模式
+func currentCookie() string {
+	if state := cookieValue.Load(); state != nil {
+		return state.value
+	}
+	return ""
+}
+
+// SetCookie 替换当前Cookie并记录更新时间，同时触发一次登录状态检查
+func SetCookie(cookie string) {
+	cookieValue.Store(newCookieState(cookie))
+	cookieRefreshFailed.Store(false)
+	requestCookieCheck()
+}
+
+// replaceCookie 仅当当前Cookie仍为 prev 时替换为 cookie，返回是否替换
+func replaceCookie(prev *cookieState, cookie string) bool {
+	if !cookieValue.CompareAndSwap(prev, newCookieState(cookie)) {
+		return false
+	}
+	requestCookieCheck()
+	return true
+}
+
+func newCookieState(cookie string) *cookieState {
+	state := &cookieState{
+		value:     cookie,
+		pool:      newCookiePool(cookie, config.Current().CookieStrategy, config.Current().CookieFailureThreshold),
+		updatedAt: time.Now(),
+	}
+	for _, slot := range state.pool.slots {
+		if expires, ok := cookieExpiry(slot.value); ok && (state.expiresAt.IsZero() || expires.Before(state.expiresAt)) {
+			state.expiresAt = expires
+		}
+	}
+	return state
+}
+
+// cookieExpiresAt 返回Cookie池中最早的过期时间，Cookie均未携带 Expires 时为空
+func cookieExpiresAt() string {
+	if state := cookieValue.Load(); state != nil && !state.expiresAt.IsZero() {
+		return state.expiresAt.UTC().Format(time.RFC3339)
+	}
+	return ""
+}
+
+// LoadCookie 配置了 NETEASE_COOKIE_FILE 时从文件读取Cookie，否则读取 NETEASE_COOKIE；
+// MOCK_UPSTREAM=true 且未配置Cookie时返回模拟Cookie
+func LoadCookie() (string, error) {
+	path := config.Lookup("NETEASE_COOKIE_FILE")
+	if path == "" {
+		cookie := strings.TrimSpace(config.Lookup("NETEASE_COOKIE"))
+		// 模拟模式无需真实Cookie，使用固定Cookie以便请求全部音质
+		if mock, _ := strconv.ParseBool(config.Lookup("MOCK_UPSTREAM")); cookie == "" && mock {
+			cookie = mockCookie
+		}
+		return cookie, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("reading NETEASE_COOKIE_FILE: %w", err)
+	}
+	cookie := strings.TrimSpace(string(data))
+	if cookie == "" {
+		return "", errors.New("NETEASE_COOKIE_FILE is empty")
+	}
+	return cookie, nil
+}
+
+// RedactCookie 仅保留长度与首尾4个字符用于确认
+func RedactCookie(cookie string) string {
+	if len(cookie) <= 8 {
+		return fmt.Sprintf("len=%d", len(cookie))
+	}
+	return fmt.Sprintf("len=%d %s...%s", len(cookie), cookie[:4], cookie[len(cookie)-4:])
+}
+
+// AccountService 检查、校验与续期Cookie，负责 /cookie/status、/admin/cookie 与 /admin/check-cookie；
+// 上游通过构造时传入的 netease.Client 访问
+type AccountService struct {
+	client netease.Client
+}
+
+func NewAccountService(client netease.Client) *AccountService {
+	return &AccountService{client: client}
+}
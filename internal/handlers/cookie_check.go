@@ -0,0 +1,205 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"PMS/internal/config"
+	"PMS/internal/logging"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Cookie检查结果，/health 中的 cookie 字段取 State
+const (
+	cookieValid   = "valid"
+	cookieExpired = "expired"
+	cookieUnknown = "unknown"
+)
+
+// CookieStatusResponse 最近一次Cookie登录状态检查的结果
+type CookieStatusResponse struct {
+	Code     int    `json:"code"`
+	State    string `json:"state"`
+	LoggedIn bool   `json:"loggedIn"`
+	VIP      bool   `json:"vip"`
+	VipType  int    `json:"vipType"`
+	Cookies  int    `json:"cookies"`
+	Expired  int    `json:"expiredCookies"`
+	User     string `json:"user,omitempty"`
+	// 第一个有效Cookie的 Expires 属性，Cookie中未携带时为空
+	ExpiresAt string `json:"expiresAt,omitempty"`
+	CheckedAt string `json:"checkedAt,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// CookieCheckResponse POST /admin/check-cookie 的响应
+type CookieCheckResponse struct {
+	Valid bool `json:"valid"`
+	// Cookie中未携带 Expires 属性时省略
+	ExpiresInSeconds *int64 `json:"expires_in_seconds,omitempty"`
+	User             string `json:"user,omitempty"`
+}
+
+var (
+	// 最近一次检查结果，尚未检查时为 nil
+	cookieCheckResult atomic.Pointer[CookieStatusResponse]
+	// Cookie被替换后通知后台立即重新检查
+	cookieRecheck = make(chan struct{}, 1)
+)
+
+// currentCookieState 返回最近一次检查得到的Cookie状态
+func currentCookieState() string {
+	if result := cookieCheckResult.Load(); result != nil {
+		return result.State
+	}
+	return cookieUnknown
+}
+
+// cookieCheckedAt 返回最近一次检查的时间，尚未检查时为空
+func cookieCheckedAt() string {
+	if result := cookieCheckResult.Load(); result != nil {
+		return result.CheckedAt
+	}
+	return ""
+}
+
+// requestCookieCheck 请求后台尽快重新检查，不阻塞调用方
+func requestCookieCheck() {
+	select {
+	case cookieRecheck <- struct{}{}:
+	default:
+	}
+}
+
+// RunCookieChecks 启动时及之后每隔 interval 检查一次Cookie登录状态；
+// 过期只在首次发现时记录一条错误日志，恢复有效后才会再次提醒
+func (s *AccountService) RunCookieChecks(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	// 启动时设置Cookie产生的通知无需处理，首轮检查即会覆盖
+	select {
+	case <-cookieRecheck:
+	default:
+	}
+
+	expiredLogged := false
+	for {
+		result := s.checkCookie(ctx)
+		cookieCheckResult.Store(result)
+
+		switch result.State {
+		case cookieExpired:
+			if !expiredLogged {
+				logging.Logger.Error("NETEASE_COOKIE has expired, tracks will fall back to trial clips until it is replaced")
+				expiredLogged = true
+			}
+		case cookieValid:
+			if expiredLogged {
+				logging.Logger.Info("NETEASE_COOKIE is valid again", "vip", result.VIP)
+			}
+			expiredLogged = false
+		default:
+			if result.Error != "" {
+				logging.Logger.Warn("cookie status check failed", "error", result.Error)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-cookieRecheck:
+		}
+	}
+}
+
+// checkCookie 调用上游 /login/status 逐个检查Cookie池中的Cookie，过期的Cookie会被暂时跳过；
+// 至少一个Cookie有效即视为 valid，VIP信息取第一个有效的Cookie
+func (s *AccountService) checkCookie(ctx context.Context) *CookieStatusResponse {
+	result := &CookieStatusResponse{
+		Code:      200,
+		State:     cookieUnknown,
+		CheckedAt: time.Now().UTC().Format(time.RFC3339),
+	}
+
+	pool := currentCookiePool()
+	if len(pool.slots) == 0 {
+		result.Error = "NETEASE_COOKIE is not configured"
+		return result
+	}
+	result.Cookies = len(pool.slots)
+
+	for _, slot := range pool.slots {
+		statusResp, err := s.client.LoginStatus(ctx, slot.value, config.Current().RealIP)
+		if err != nil {
+			result.Error = upstreamErrorMessage(err)
+			continue
+		}
+		if !statusResp.LoggedIn() {
+			result.Expired++
+			pool.MarkFailed(slot)
+			continue
+		}
+		if !result.LoggedIn {
+			result.LoggedIn = true
+			result.User = statusResp.Data.Profile.Nickname
+			if expires, ok := cookieExpiry(slot.value); ok {
+				result.ExpiresAt = expires.UTC().Format(time.RFC3339)
+			}
+			result.VipType = statusResp.Data.Profile.VipType
+			if result.VipType == 0 && statusResp.Data.Account != nil {
+				result.VipType = statusResp.Data.Account.VipType
+			}
+			result.VIP = result.VipType > 0
+		}
+	}
+
+	switch {
+	case result.LoggedIn:
+		result.State = cookieValid
+	case result.Expired == result.Cookies:
+		result.State = cookieExpired
+	}
+	return result
+}
+
+// GetCookieStatus 处理 GET /cookie/status，返回最近一次检查结果
+func GetCookieStatus(c *gin.Context) {
+	result := cookieCheckResult.Load()
+	if result == nil {
+		result = &CookieStatusResponse{Code: 200, State: cookieUnknown}
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// cookieExpiry 读取从浏览器复制的Cookie中携带的 Expires 属性
+func cookieExpiry(cookie string) (time.Time, bool) {
+	for _, part := range strings.Split(cookie, ";") {
+		name, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok || !strings.EqualFold(name, "Expires") {
+			continue
+		}
+		if t, err := http.ParseTime(strings.TrimSpace(value)); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// CheckAdminCookie 处理 POST /admin/check-cookie，立即检查当前Cookie并更新 /health 与 /cookie/status 中的结果
+func (s *AccountService) CheckAdminCookie(c *gin.Context) {
+	result := s.checkCookie(c.Request.Context())
+	cookieCheckResult.Store(result)
+
+	resp := CookieCheckResponse{Valid: result.State == cookieValid, User: result.User}
+	if expires, err := time.Parse(time.RFC3339, result.ExpiresAt); err == nil {
+		seconds := int64(time.Until(expires).Seconds())
+		resp.ExpiresInSeconds = &seconds
+	}
+	c.JSON(http.StatusOK, resp)
+}
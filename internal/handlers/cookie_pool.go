@@ -0,0 +1,172 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"math/rand"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"PMS/internal/config"
+	"PMS/internal/logging"
+)
+
+// 上游返回这些code时说明账号本身出了问题（未登录、操作频繁、需要验证），计入该Cookie的失败次数；
+// 其他非200的code（如歌单不存在）与Cookie无关
+var cookieFailureCodes = map[int]bool{
+	301:  true,
+	405:  true,
+	-460: true,
+	-462: true,
+}
+
+// cookieSlot Cookie池中的一个Cookie及其失败计数
+type cookieSlot struct {
+	value    string
+	failures atomic.Int32
+}
+
+// cookiePool 多个Cookie轮流使用以分摊单账号的请求频率限制；
+// 失败次数达到阈值的Cookie暂时跳过，由 HealCookiePools 定期清零
+type cookiePool struct {
+	slots     []*cookieSlot
+	next      atomic.Uint64
+	random    bool
+	threshold int32
+}
+
+// parseCookiePool 解析以 ; 分隔的多个Cookie。
+// 单个Cookie本身也常写成 "MUSIC_U=…; __csrf=…"，因此含 MUSIC_U= 的片段才开始一个新Cookie，
+// 其余片段归入前一个Cookie；所有片段都不含 MUSIC_U= 时每个片段各为一个Cookie
+func parseCookiePool(value string) []string {
+	var fragments []string
+	for _, fragment := range strings.Split(value, ";") {
+		if fragment = strings.TrimSpace(fragment); fragment != "" {
+			fragments = append(fragments, fragment)
+		}
+	}
+
+	if !strings.Contains(value, "MUSIC_U=") {
+		return fragments
+	}
+
+	var cookies []string
+	for _, fragment := range fragments {
+		if strings.HasPrefix(fragment, "MUSIC_U=") || len(cookies) == 0 {
+			cookies = append(cookies, fragment)
+			continue
+		}
+		cookies[len(cookies)-1] += "; " + fragment
+	}
+	return cookies
+}
+
+func newCookiePool(value, strategy string, threshold int) *cookiePool {
+	pool := &cookiePool{
+		random:    strategy == config.CookieStrategyRandom,
+		threshold: int32(threshold),
+	}
+	for _, cookie := range parseCookiePool(value) {
+		pool.slots = append(pool.slots, &cookieSlot{value: cookie})
+	}
+	return pool
+}
+
+// Pick 选出下一个可用的Cookie，全部失败时仍按顺序返回一个，避免所有请求都降级为匿名；
+// 池为空时返回 nil
+func (p *cookiePool) Pick() *cookieSlot {
+	n := len(p.slots)
+	if n == 0 {
+		return nil
+	}
+
+	var start int
+	if p.random {
+		start = rand.Intn(n)
+	} else {
+		start = int(p.next.Add(1)-1) % n
+	}
+	for i := 0; i < n; i++ {
+		slot := p.slots[(start+i)%n]
+		if !p.failed(slot) {
+			return slot
+		}
+	}
+	return p.slots[start]
+}
+
+// Record 根据上游响应更新Cookie的失败计数，成功时清零
+func (p *cookiePool) Record(slot *cookieSlot, body []byte) {
+	var resp struct {
+		Code int `json:"code"`
+	}
+	if json.Unmarshal(body, &resp) != nil {
+		return
+	}
+	if !cookieFailureCodes[resp.Code] {
+		slot.failures.Store(0)
+		return
+	}
+	if slot.failures.Add(1) == p.threshold {
+		logging.Logger.Warn("cookie temporarily skipped after repeated failures",
+			"cookie", RedactCookie(slot.value),
+			"upstream_code", resp.Code,
+			"failures", p.threshold,
+		)
+	}
+}
+
+// MarkFailed 直接将Cookie标记为失败，用于登录状态检查发现其已过期
+func (p *cookiePool) MarkFailed(slot *cookieSlot) {
+	slot.failures.Store(p.threshold)
+}
+
+func (p *cookiePool) failed(slot *cookieSlot) bool {
+	return p.threshold > 0 && slot.failures.Load() >= p.threshold
+}
+
+// Counts 返回可用与已跳过的Cookie数量
+func (p *cookiePool) Counts() (active, failed int) {
+	for _, slot := range p.slots {
+		if p.failed(slot) {
+			failed++
+		} else {
+			active++
+		}
+	}
+	return active, failed
+}
+
+// heal 清零所有Cookie的失败计数
+func (p *cookiePool) heal() {
+	for _, slot := range p.slots {
+		slot.failures.Store(0)
+	}
+}
+
+// currentCookiePool 返回当前Cookie池，匿名模式下池为空
+func currentCookiePool() *cookiePool {
+	if state := cookieValue.Load(); state != nil {
+		return state.pool
+	}
+	return &cookiePool{}
+}
+
+// HealCookiePools 每隔 interval 清零失败计数，让被跳过的Cookie重新参与轮换
+func HealCookiePools(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			pool := currentCookiePool()
+			if _, failed := pool.Counts(); failed > 0 {
+				logging.Logger.Info("resetting cookie failure counts", "failed", failed)
+			}
+			pool.heal()
+		}
+	}
+}
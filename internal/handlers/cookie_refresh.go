@@ -0,0 +1,192 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"PMS/internal/config"
+	"PMS/internal/logging"
+)
+
+// 检查Cookie是否临近过期的间隔
+const cookieRefreshPollInterval = 10 * time.Minute
+
+// 最近一轮续期有失败时为 true，此时 /health 的 cookie_valid 为 false；续期全部成功或Cookie被替换后清除
+var cookieRefreshFailed atomic.Bool
+
+// Cookie中描述 Set-Cookie 属性而非Cookie本身的字段
+var cookieAttributes = map[string]bool{
+	"expires":  true,
+	"max-age":  true,
+	"path":     true,
+	"domain":   true,
+	"secure":   true,
+	"httponly": true,
+	"samesite": true,
+}
+
+// RunCookieRefresh 启动时及之后定期检查Cookie池中各Cookie的 Expires 属性，
+// 剩余有效期不足 threshold 时调用上游 /login/refresh 续期；未携带 Expires 的Cookie不处理
+func (s *AccountService) RunCookieRefresh(ctx context.Context, threshold time.Duration) {
+	ticker := time.NewTicker(cookieRefreshPollInterval)
+	defer ticker.Stop()
+
+	for {
+		s.refreshExpiringCookies(ctx, threshold)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// refreshExpiringCookies 续期临近过期的Cookie，并以续期结果替换当前Cookie
+func (s *AccountService) refreshExpiringCookies(ctx context.Context, threshold time.Duration) {
+	state := cookieValue.Load()
+	if state == nil || state.value == "" {
+		return
+	}
+
+	entries := parseCookiePool(state.value)
+	refreshed, failed := 0, 0
+	for i, entry := range entries {
+		expires, ok := cookieExpiry(entry)
+		if !ok || time.Until(expires) >= threshold {
+			continue
+		}
+		next, err := s.refreshCookie(ctx, entry)
+		if err != nil {
+			failed++
+			logging.Logger.Warn("cookie refresh failed", "cookie", RedactCookie(entry), "expires_at", expires.UTC().Format(time.RFC3339), "error", err)
+			continue
+		}
+		entries[i] = next
+		refreshed++
+		if expires, ok := cookieExpiry(next); ok {
+			logging.Logger.Info("cookie refreshed", "cookie", RedactCookie(next), "expires_at", expires.UTC().Format(time.RFC3339))
+		} else {
+			logging.Logger.Info("cookie refreshed", "cookie", RedactCookie(next))
+		}
+	}
+
+	if refreshed > 0 {
+		cookie := strings.Join(entries, "; ")
+		// 续期期间Cookie已被管理接口或 SIGHUP 替换时放弃本次结果
+		if !replaceCookie(state, cookie) {
+			logging.Logger.Info("cookie replaced during refresh, discarding refreshed cookie")
+			return
+		}
+		storeRefreshedCookie(cookie)
+	}
+	cookieRefreshFailed.Store(failed > 0)
+}
+
+// refreshCookie 使用指定Cookie调用上游 /login/refresh，返回替换了 MUSIC_U 与 Expires 的新Cookie，
+// 其余字段保持不变
+func (s *AccountService) refreshCookie(ctx context.Context, cookie string) (string, error) {
+	resp, err := s.client.LoginRefresh(ctx, cookie, config.Current().RealIP)
+	if err != nil {
+		return "", errors.New(upstreamErrorMessage(err))
+	}
+	if resp.Code != 200 {
+		return "", fmt.Errorf("music service returned code %d", resp.Code)
+	}
+
+	musicU, expires := parseRefreshedCookie(resp.Cookie)
+	if musicU == "" {
+		return "", errors.New("refresh response contains no MUSIC_U")
+	}
+	cookie = setCookieField(cookie, "MUSIC_U", musicU)
+	if expires.IsZero() {
+		// 没有新的过期时间时去掉旧的 Expires，避免每轮都重复续期
+		return setCookieField(cookie, "Expires", ""), nil
+	}
+	return setCookieField(cookie, "Expires", expires.UTC().Format(http.TimeFormat)), nil
+}
+
+// parseRefreshedCookie 从续期响应拼接的 Set-Cookie 中取出新的 MUSIC_U 及其过期时间，
+// 过期时间取 MUSIC_U 的 Expires，没有时按 Max-Age 计算
+func parseRefreshedCookie(setCookie string) (musicU string, expires time.Time) {
+	current := ""
+	for _, part := range strings.Split(setCookie, ";") {
+		name, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		name, value = strings.TrimSpace(name), strings.TrimSpace(value)
+		if !cookieAttributes[strings.ToLower(name)] {
+			current = name
+			if name == "MUSIC_U" {
+				musicU = value
+			}
+			continue
+		}
+		if current != "MUSIC_U" {
+			continue
+		}
+		switch strings.ToLower(name) {
+		case "expires":
+			if t, err := http.ParseTime(value); err == nil {
+				expires = t
+			}
+		case "max-age":
+			if seconds, err := strconv.Atoi(value); err == nil && expires.IsZero() {
+				expires = time.Now().Add(time.Duration(seconds) * time.Second)
+			}
+		}
+	}
+	return musicU, expires
+}
+
+// setCookieField 替换Cookie中名为 name 的字段（Expires 等属性不区分大小写），不存在时追加；value 为空时删除该字段
+func setCookieField(cookie, name, value string) string {
+	var parts []string
+	found := false
+	for _, part := range strings.Split(cookie, ";") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		key, _, _ := strings.Cut(part, "=")
+		if key = strings.TrimSpace(key); key == name || (cookieAttributes[strings.ToLower(name)] && strings.EqualFold(key, name)) {
+			found = true
+			if value != "" {
+				parts = append(parts, name+"="+value)
+			}
+			continue
+		}
+		parts = append(parts, part)
+	}
+	if !found && value != "" {
+		parts = append(parts, name+"="+value)
+	}
+	return strings.Join(parts, "; ")
+}
+
+// storeRefreshedCookie 将续期后的Cookie写回配置：始终更新进程内的 NETEASE_COOKIE，
+// COOKIE_PERSIST_REFRESH=true 时写回 NETEASE_COOKIE_FILE，未使用Cookie文件时写回 .env
+func storeRefreshedCookie(cookie string) {
+	path := config.Lookup("NETEASE_COOKIE_FILE")
+	if path == "" {
+		os.Setenv("NETEASE_COOKIE", cookie)
+	}
+	if !config.Current().CookiePersistRefresh {
+		return
+	}
+
+	var err error
+	if path != "" {
+		err = os.WriteFile(path, []byte(cookie+"\n"), 0o600)
+	} else {
+		err = config.WriteEnvValue("NETEASE_COOKIE", cookie)
+	}
+	if err != nil {
+		logging.Logger.Warn("failed to persist refreshed cookie", "error", err)
+	}
+}
@@ -0,0 +1,286 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"PMS/internal/api"
+	"PMS/internal/config"
+	"PMS/internal/logging"
+	"PMS/internal/metrics"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 允许的封面缩略图尺寸 (像素)
+var coverSizes = []int{100, 200, 300, 500, 800}
+
+// size 参数的别名
+var coverSizeNames = map[string]int{
+	"small":  100,
+	"medium": 300,
+	"large":  500,
+}
+
+// 单张封面的最大字节数，超出视为上游异常
+const coverMaxBytes = 10 << 20
+
+// 下载封面图片使用的HTTP客户端，在 Setup 中根据配置初始化
+var coverClient = http.DefaultClient
+
+// 封面图片缓存，为 nil 表示禁用；与响应缓存分开以免图片挤占歌曲地址等条目，
+// 除条目数外还以 COVER_CACHE_MAX_BYTES 限制总大小
+var coverCache *memoryCache
+
+// coverImage 缓存的封面图片，在缓存中以 "Content-Type\nETag\n图片数据" 形式存储，命中时无需重新计算ETag
+type coverImage struct {
+	ContentType string
+	Data        []byte
+	ETag        string
+}
+
+func encodeCoverImage(img *coverImage) []byte {
+	buf := make([]byte, 0, len(img.ContentType)+len(img.ETag)+2+len(img.Data))
+	buf = append(buf, img.ContentType...)
+	buf = append(buf, '\n')
+	buf = append(buf, img.ETag...)
+	buf = append(buf, '\n')
+	return append(buf, img.Data...)
+}
+
+func decodeCoverImage(value []byte) (*coverImage, bool) {
+	contentType, rest, ok := bytes.Cut(value, []byte{'\n'})
+	if !ok {
+		return nil, false
+	}
+	etag, data, ok := bytes.Cut(rest, []byte{'\n'})
+	if !ok {
+		return nil, false
+	}
+	return &coverImage{ContentType: string(contentType), Data: data, ETag: string(etag)}, true
+}
+
+// coverETag 以图片内容的MD5作为强ETag
+func coverETag(data []byte) string {
+	sum := md5.Sum(data)
+	return `"` + hex.EncodeToString(sum[:]) + `"`
+}
+
+// parseCoverSize 校验 size 参数 (像素或 small/medium/large)，为空时返回0表示原图，失败时直接写入400响应
+func parseCoverSize(c *gin.Context, value string) (int, bool) {
+	if value == "" {
+		return 0, true
+	}
+	if size, ok := coverSizeNames[value]; ok {
+		return size, true
+	}
+	size, err := strconv.Atoi(value)
+	if err == nil {
+		for _, allowed := range coverSizes {
+			if size == allowed {
+				return size, true
+			}
+		}
+	}
+
+	allowed := make([]string, len(coverSizes))
+	for i, s := range coverSizes {
+		allowed[i] = strconv.Itoa(s)
+	}
+	c.JSON(http.StatusBadRequest, api.NewErrorResponse(c, 400, fmt.Sprintf("Invalid size parameter, must be small, medium, large or one of: %s", strings.Join(allowed, ", "))))
+	return 0, false
+}
+
+// coverImageURL 为封面地址附加网易云的缩略图参数 param=300y300
+func coverImageURL(picURL string, size int) (string, error) {
+	if size == 0 {
+		return picURL, nil
+	}
+	u, err := url.Parse(picURL)
+	if err != nil {
+		return "", err
+	}
+	q := u.Query()
+	q.Set("param", fmt.Sprintf("%dy%d", size, size))
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+// fetchCoverImage 下载封面图片
+func fetchCoverImage(ctx context.Context, imageURL string) (*coverImage, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, imageURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errUpstreamRequest, err)
+	}
+
+	start := time.Now()
+	resp, err := coverClient.Do(req)
+	if err != nil {
+		metrics.ObserveUpstream("cover", "error", time.Since(start))
+		if isTimeout(err) {
+			return nil, fmt.Errorf("%w: %v", errUpstreamTimeout, err)
+		}
+		return nil, fmt.Errorf("%w: %v", errUpstreamRequest, err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, coverMaxBytes+1))
+	metrics.ObserveUpstream("cover", strconv.Itoa(resp.StatusCode), time.Since(start))
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: status %d", errUpstreamBadStatus, resp.StatusCode)
+	}
+	if err != nil {
+		if isTimeout(err) {
+			return nil, fmt.Errorf("%w: %v", errUpstreamTimeout, err)
+		}
+		return nil, fmt.Errorf("%w: %v", errUpstreamRead, err)
+	}
+	if len(data) > coverMaxBytes {
+		return nil, fmt.Errorf("%w: image exceeds %d bytes", errUpstreamRead, coverMaxBytes)
+	}
+
+	contentType := resp.Header.Get("Content-Type")
+	if !strings.HasPrefix(contentType, "image/") {
+		contentType = http.DetectContentType(data)
+	}
+	return &coverImage{ContentType: contentType, Data: data, ETag: coverETag(data)}, nil
+}
+
+// getCoverImageCached 优先从封面缓存读取图片，键为最终图片地址
+func getCoverImageCached(ctx context.Context, imageURL string, nocache bool) (*coverImage, error) {
+	key := "pms:cover:" + imageURL
+	if coverCache != nil && !nocache {
+		if value, ok := coverCache.Get(ctx, key); ok {
+			if img, ok := decodeCoverImage(value); ok {
+				return img, nil
+			}
+		}
+	}
+
+	img, err := fetchCoverImage(ctx, imageURL)
+	if err != nil {
+		return nil, err
+	}
+	if coverCache != nil {
+		coverCache.Set(ctx, key, encodeCoverImage(img), config.Current().CoverCacheTTL)
+	}
+	return img, nil
+}
+
+// GetCover 处理 GET /cover?id=…&size=300，代理返回歌曲的专辑封面；
+// type=album 时 id 为专辑ID
+func (s *CatalogService) GetCover(c *gin.Context) {
+	coverType := c.DefaultQuery("type", "song")
+	if coverType != "song" && coverType != "album" {
+		c.JSON(http.StatusBadRequest, api.NewErrorResponse(c, 400, "Invalid type parameter, must be song or album"))
+		return
+	}
+	id, ok := parseNumericID(c, c.Query("id"), coverType)
+	if !ok {
+		return
+	}
+	size, ok := parseCoverSize(c, c.Query("size"))
+	if !ok {
+		return
+	}
+
+	realIP := c.DefaultQuery("realip", defaultRealIP(c))
+	nocache := c.Query("nocache") == "1"
+
+	ctx := c.Request.Context()
+	lookup := s.lookupSongCover
+	if coverType == "album" {
+		lookup = s.lookupAlbumCover
+	}
+	coverURL, ok := lookup(c, id, realIP, nocache)
+	if !ok {
+		return
+	}
+
+	imageURL, err := coverImageURL(coverURL, size)
+	if err != nil {
+		logging.From(ctx).Error("error parsing cover URL", "id", id, "type", coverType, "cover_url", coverURL, "error", err)
+		c.JSON(http.StatusBadGateway, api.NewErrorResponse(c, 502, "Invalid cover URL from music service"))
+		return
+	}
+
+	img, err := getCoverImageCached(ctx, imageURL, nocache)
+	if err != nil {
+		respondUpstreamError(c, err)
+		return
+	}
+
+	c.Header("ETag", img.ETag)
+	c.Header("Cache-Control", fmt.Sprintf("public, max-age=%d", int(config.Current().CoverMaxAge.Seconds())))
+	if match := c.GetHeader("If-None-Match"); match != "" && etagMatches(match, img.ETag) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+	c.Data(http.StatusOK, img.ContentType, img.Data)
+}
+
+// lookupSongCover 从缓存的歌曲详情中查找封面地址，失败时直接写入错误响应
+func (s *CatalogService) lookupSongCover(c *gin.Context, songID int64, realIP string, nocache bool) (string, bool) {
+	details, status, err := s.getSongDetailsCached(c.Request.Context(), []int64{songID}, realIP, nocache)
+	if err != nil {
+		respondUpstreamError(c, err)
+		return "", false
+	}
+
+	// 检查网易云音乐API返回的状态码
+	if status.Code != 200 {
+		respondUpstreamCode(c, status.Code, status.Message)
+		return "", false
+	}
+
+	detail, ok := details[songID]
+	if !ok {
+		c.JSON(http.StatusNotFound, api.NewErrorResponse(c, 404, "Song not found"))
+		return "", false
+	}
+	if detail.CoverURL == "" {
+		c.JSON(http.StatusNotFound, api.NewErrorResponse(c, 404, "Cover not found"))
+		return "", false
+	}
+	return detail.CoverURL, true
+}
+
+// lookupAlbumCover 从缓存的专辑信息中查找封面地址，失败时直接写入错误响应
+func (s *CatalogService) lookupAlbumCover(c *gin.Context, albumID int64, realIP string, nocache bool) (string, bool) {
+	album, err := s.getAlbumCached(c.Request.Context(), albumID, realIP, nocache)
+	if err != nil {
+		respondUpstreamError(c, err)
+		return "", false
+	}
+
+	// 检查网易云音乐API返回的状态码
+	if album.Code != 200 {
+		respondUpstreamCode(c, album.Code, album.Message)
+		return "", false
+	}
+	if album.CoverURL == "" {
+		c.JSON(http.StatusNotFound, api.NewErrorResponse(c, 404, "Cover not found"))
+		return "", false
+	}
+	return album.CoverURL, true
+}
+
+// etagMatches 判断 If-None-Match 是否包含给定ETag，支持逗号分隔的多个值、弱校验前缀与 *
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
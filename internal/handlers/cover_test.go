@@ -0,0 +1,20 @@
+package handlers
+
+import "testing"
+
+func TestCoverImageEncodingKeepsETag(t *testing.T) {
+	data := []byte("\x89PNG\n\x1a\nimage")
+	img := &coverImage{ContentType: "image/png", Data: data, ETag: coverETag(data)}
+
+	got, ok := decodeCoverImage(encodeCoverImage(img))
+	if !ok {
+		t.Fatal("decodeCoverImage failed on an encoded image")
+	}
+	if got.ContentType != img.ContentType || got.ETag != img.ETag || string(got.Data) != string(data) {
+		t.Errorf("decoded = %+v, want %+v", got, img)
+	}
+
+	if _, ok := decodeCoverImage([]byte("image/png\nno etag separator")); ok {
+		t.Error("decodeCoverImage accepted a value without the ETag line")
+	}
+}
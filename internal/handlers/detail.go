@@ -0,0 +1,176 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"PMS/internal/api"
+	"PMS/internal/config"
+	"PMS/internal/netease"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 单次 /detail 请求允许的最大歌曲数量
+const detailMaxIDs = 20
+
+// artistNames 拼接歌手名，多位歌手以 ", " 分隔
+func artistNames(artists []Artist) string {
+	names := make([]string, 0, len(artists))
+	for _, ar := range artists {
+		if ar.Name != "" {
+			names = append(names, ar.Name)
+		}
+	}
+	return strings.Join(names, ", ")
+}
+
+// SongDetail 归一化后的歌曲元数据
+type SongDetail struct {
+	ID          int64    `json:"id"`
+	Name        string   `json:"name"`
+	Artists     []Artist `json:"artists"`
+	Album       Album    `json:"album"`
+	CoverURL    string   `json:"coverUrl"`
+	Duration    int      `json:"duration"`
+	PublishTime int64    `json:"publishTime"`
+}
+
+type SongDetailResponse struct {
+	Code     int          `json:"code"`
+	Songs    []SongDetail `json:"songs"`
+	NotFound []int64      `json:"notFound,omitempty"`
+}
+
+func toSongDetail(s netease.Song) SongDetail {
+	return SongDetail{
+		ID:          s.ID,
+		Name:        s.Name,
+		Artists:     toArtists(s.Ar),
+		Album:       Album{ID: s.Al.ID, Name: s.Al.Name, PicURL: s.Al.PicURL},
+		CoverURL:    s.Al.PicURL,
+		Duration:    s.Dt,
+		PublishTime: s.PublishTime,
+	}
+}
+
+// detailCacheKey 生成歌曲详情的缓存键，按租户区分
+func detailCacheKey(ctx context.Context, songID int64) string {
+	return fmt.Sprintf("pms:detail:%d", songID) + tenantKeySuffix(ctx)
+}
+
+// getSongDetailsCached 按ID逐个读取缓存，未命中的ID合并为一次上游请求；
+// 上游返回非200时返回其状态码与说明
+func (s *CatalogService) getSongDetailsCached(ctx context.Context, songIDs []int64, realIP string, nocache bool) (map[int64]SongDetail, upstreamStatus, error) {
+	details := make(map[int64]SongDetail, len(songIDs))
+	missing := songIDs
+	if responseCache != nil && !nocache {
+		missing = nil
+		for _, id := range songIDs {
+			var detail SongDetail
+			if cacheGetJSON(ctx, detailCacheKey(ctx, id), &detail) {
+				details[id] = detail
+			} else {
+				missing = append(missing, id)
+			}
+		}
+	}
+
+	if len(missing) == 0 {
+		return details, upstreamStatus{Code: 200}, nil
+	}
+
+	detailResp, err := s.client.SongDetail(ctx, missing, realIP)
+	if err != nil {
+		return nil, upstreamStatus{}, err
+	}
+	if detailResp.Code != 200 {
+		return nil, upstreamStatus{Code: detailResp.Code, Message: detailResp.Message}, nil
+	}
+
+	for _, song := range detailResp.Songs {
+		detail := toSongDetail(song)
+		details[detail.ID] = detail
+		if responseCache != nil {
+			cacheSetJSON(ctx, detailCacheKey(ctx, detail.ID), detail, config.Current().DetailCacheTTL)
+		}
+	}
+	return details, upstreamStatus{Code: 200}, nil
+}
+
+// parseSongIDList 解析逗号分隔的歌曲ID列表并去重，失败时直接写入400响应
+func parseSongIDList(c *gin.Context, value string, maxIDs int) ([]int64, bool) {
+	var ids []string
+	for _, id := range strings.Split(value, ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		c.JSON(http.StatusBadRequest, api.NewErrorResponse(c, 400, "Missing required parameter: id"))
+		return nil, false
+	}
+
+	ids = dedupeIDs(ids)
+	if len(ids) > maxIDs {
+		c.JSON(http.StatusBadRequest, api.NewErrorResponse(c, 400, fmt.Sprintf("Too many ids, at most %d are allowed", maxIDs)))
+		return nil, false
+	}
+
+	songIDs := make([]int64, 0, len(ids))
+	for _, id := range ids {
+		songID, ok := parseSongID(c, id)
+		if !ok {
+			return nil, false
+		}
+		songIDs = append(songIDs, songID)
+	}
+	return songIDs, true
+}
+
+// GetSongDetail 处理 GET /detail?id=1,2,3，返回歌曲元数据
+func (s *CatalogService) GetSongDetail(c *gin.Context) {
+	songIDs, ok := parseSongIDList(c, c.Query("id"), detailMaxIDs)
+	if !ok {
+		return
+	}
+
+	realIP := c.DefaultQuery("realip", defaultRealIP(c))
+	nocache := c.Query("nocache") == "1"
+
+	details, status, err := s.getSongDetailsCached(c.Request.Context(), songIDs, realIP, nocache)
+	if err != nil {
+		respondUpstreamError(c, err)
+		return
+	}
+
+	// 检查网易云音乐API返回的状态码
+	if status.Code != 200 {
+		respondUpstreamCode(c, status.Code, status.Message)
+		return
+	}
+
+	// 按请求顺序返回，上游未返回的ID记入 notFound
+	songs := make([]SongDetail, 0, len(songIDs))
+	var notFound []int64
+	for _, id := range songIDs {
+		if detail, ok := details[id]; ok {
+			songs = append(songs, detail)
+		} else {
+			notFound = append(notFound, id)
+		}
+	}
+
+	if len(songs) == 0 {
+		c.JSON(http.StatusNotFound, api.NewErrorResponse(c, 404, "Song not found"))
+		return
+	}
+
+	c.JSON(http.StatusOK, SongDetailResponse{
+		Code:     200,
+		Songs:    songs,
+		NotFound: notFound,
+	})
+}
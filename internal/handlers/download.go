@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"PMS/internal/api"
+	"PMS/internal/config"
+	"PMS/internal/logging"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 文件名中不允许出现的字符
+var filenameReplacer = strings.NewReplacer("/", "_", "\\", "_", "\"", "'", ":", "_", "*", "_", "?", "_", "<", "_", ">", "_", "|", "_")
+
+// DownloadSong 处理 GET /download，以附件形式返回音频文件并校验大小与MD5
+func (s *SongURLService) DownloadSong(c *gin.Context) {
+	songID, ok := parseSongID(c, c.Query("id"))
+	if !ok {
+		return
+	}
+
+	level := c.DefaultQuery("level", defaultLevel(c))
+	if !checkLevel(c, level) {
+		return
+	}
+	realIP := c.DefaultQuery("realip", defaultRealIP(c))
+	nocache := c.Query("nocache") == "1"
+	fallback := c.Query("fallback") != "false"
+
+	ctx := c.Request.Context()
+	songResp, _, err := s.resolve(ctx, songID, level, realIP, nocache, fallback)
+	if err != nil {
+		respondUpstreamError(c, err)
+		return
+	}
+
+	// 检查网易云音乐API返回的状态码
+	if songResp.Code != 200 {
+		respondUpstreamCode(c, songResp.Code, songResp.Message)
+		return
+	}
+
+	if len(songResp.Data) == 0 || songResp.Data[0].URL == "" {
+		c.JSON(http.StatusNotFound, api.NewErrorResponse(c, 404, "No playable URL available for this song"))
+		return
+	}
+	song := songResp.Data[0]
+
+	streamCtx, cancel := streamContext(ctx)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(streamCtx, http.MethodGet, song.URL, nil)
+	if err != nil {
+		logging.From(ctx).Error("error building audio request", "song_id", songID, "error", err)
+		c.JSON(http.StatusInternalServerError, api.NewErrorResponse(c, 500, "Failed to request audio file"))
+		return
+	}
+
+	resp, err := streamClient.Do(req)
+	if err != nil {
+		if errors.Is(err, context.Canceled) {
+			return
+		}
+		if isProxyError(err) {
+			logging.From(ctx).Error("audio file proxy connection failed", "song_id", songID, "error", err)
+			c.JSON(http.StatusBadGateway, api.NewErrorResponse(c, 502, "Failed to connect to audio source through proxy"))
+			return
+		}
+		logging.From(ctx).Error("error requesting audio file", "song_id", songID, "error", err)
+		c.JSON(http.StatusBadGateway, api.NewErrorResponse(c, 502, "Failed to request audio file"))
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		logging.From(ctx).Error("audio CDN returned error", "song_id", songID, "status_code", resp.StatusCode)
+		c.JSON(http.StatusBadGateway, api.NewErrorResponse(c, 502, "Audio source returned error"))
+		return
+	}
+
+	contentType := audioContentType(song.Type)
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{
+		"filename": s.downloadFilename(ctx, songID, realIP, song.Type),
+	}))
+	if resp.ContentLength >= 0 {
+		c.Header("Content-Length", strconv.FormatInt(resp.ContentLength, 10))
+	}
+	c.Status(http.StatusOK)
+	extendWriteDeadline(c)
+
+	hash := md5.New()
+	written, err := io.Copy(io.MultiWriter(c.Writer, hash), resp.Body)
+	if err != nil {
+		switch {
+		case errors.Is(err, context.Canceled):
+		case errors.Is(streamCtx.Err(), context.DeadlineExceeded):
+			logging.From(ctx).Warn("download exceeded STREAM_MAX_DURATION", "song_id", songID, "max_duration", config.Current().StreamMaxDuration.String())
+		default:
+			logging.From(ctx).Warn("download interrupted", "song_id", songID, "error", err)
+		}
+		return
+	}
+
+	// 校验下载内容与上游声明的大小和MD5是否一致
+	if song.Size > 0 && written != int64(song.Size) {
+		logging.From(ctx).Warn("download size mismatch", "song_id", songID, "bytes", written, "expected_bytes", song.Size)
+	}
+	if song.MD5 != "" && !strings.EqualFold(hex.EncodeToString(hash.Sum(nil)), song.MD5) {
+		logging.From(ctx).Warn("download MD5 mismatch", "song_id", songID, "expected_md5", song.MD5)
+	}
+}
+
+// downloadFilename 生成 "歌手 - 歌名.扩展名" 格式的文件名，
+// 详情查询失败时退回使用歌曲ID
+func (s *SongURLService) downloadFilename(ctx context.Context, songID int64, realIP, audioType string) string {
+	ext := strings.ToLower(audioType)
+	if ext == "" {
+		ext = "mp3"
+	}
+
+	name := strconv.FormatInt(songID, 10)
+	details, status, err := s.catalog.getSongDetailsCached(ctx, []int64{songID}, realIP, false)
+	detail, found := details[songID]
+	switch {
+	case err != nil:
+		logging.From(ctx).Warn("error fetching song detail, using id as filename", "song_id", songID, "error", err)
+	case status.Code != 200 || !found:
+		logging.From(ctx).Warn("no song detail, using id as filename", "song_id", songID, "upstream_code", status.Code)
+	default:
+		if artists := artistNames(detail.Artists); artists != "" {
+			name = artists + " - " + detail.Name
+		} else if detail.Name != "" {
+			name = detail.Name
+		}
+	}
+
+	return filenameReplacer.Replace(name) + "." + ext
+}
@@ -0,0 +1,232 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"PMS/internal/api"
+	"PMS/internal/config"
+	"PMS/internal/logging"
+	"PMS/internal/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// 没有事件时发送注释行的间隔，避免代理因连接空闲而断开
+	eventsHeartbeatInterval = 15 * time.Second
+	// 每个客户端未发送的事件上限，超出时断开该客户端，由 EventSource 自动重连
+	eventsClientBuffer = 64
+)
+
+// songEvent /events 推送的事件，type 目前只有 song_expired
+type songEvent struct {
+	Type  string `json:"type"`
+	ID    int64  `json:"id"`
+	Level string `json:"level,omitempty"`
+	// 租户的缓存项产生的事件为租户名称，只推送给该租户的连接
+	tenant string
+}
+
+// eventClient 一个 /events 连接，ids 为空表示接收全部歌曲的事件；
+// 以租户的API Key连接时 tenant 为租户名称，只接收该租户的事件，否则只接收非租户的事件
+type eventClient struct {
+	ids    map[int64]bool
+	tenant string
+	send   chan songEvent
+}
+
+func (cl *eventClient) wants(ev songEvent) bool {
+	return ev.tenant == cl.tenant && (len(cl.ids) == 0 || cl.ids[ev.ID])
+}
+
+// eventHub 由单个 goroutine 维护连接列表并分发事件，注册、注销与广播均通过 channel 交给该 goroutine
+type eventHub struct {
+	register   chan *eventClient
+	unregister chan *eventClient
+	broadcast  chan songEvent
+	clients    map[*eventClient]bool
+	count      atomic.Int64
+}
+
+var events = newEventHub()
+
+func newEventHub() *eventHub {
+	h := &eventHub{
+		register:   make(chan *eventClient),
+		unregister: make(chan *eventClient),
+		broadcast:  make(chan songEvent, eventsClientBuffer),
+		clients:    make(map[*eventClient]bool),
+	}
+	go h.run()
+	return h
+}
+
+func (h *eventHub) run() {
+	for {
+		select {
+		case cl := <-h.register:
+			h.clients[cl] = true
+			h.count.Store(int64(len(h.clients)))
+		case cl := <-h.unregister:
+			h.remove(cl)
+		case ev := <-h.broadcast:
+			for cl := range h.clients {
+				if !cl.wants(ev) {
+					continue
+				}
+				select {
+				case cl.send <- ev:
+				default:
+					// 客户端读取过慢，断开以免拖慢其他客户端
+					h.remove(cl)
+				}
+			}
+		}
+	}
+}
+
+// remove 注销客户端并关闭其 send，处理函数随之结束；重复注销时忽略
+func (h *eventHub) remove(cl *eventClient) {
+	if !h.clients[cl] {
+		return
+	}
+	delete(h.clients, cl)
+	close(cl.send)
+	h.count.Store(int64(len(h.clients)))
+}
+
+// publish 将事件交给 hub 分发，没有连接时直接丢弃
+func (h *eventHub) publish(ev songEvent) {
+	if h.count.Load() == 0 {
+		return
+	}
+	h.broadcast <- ev
+}
+
+// EventClients 返回当前的 /events 连接数
+func EventClients() int64 {
+	return events.count.Load()
+}
+
+// GetEvents 处理 GET /events，以 Server-Sent Events 推送缓存中歌曲地址过期的事件，
+// ?ids=123,456 只接收指定歌曲的事件；客户端断开或服务停机时结束
+func GetEvents(c *gin.Context) {
+	cl := &eventClient{send: make(chan songEvent, eventsClientBuffer)}
+	if tenant, ok := config.TenantFrom(c.Request.Context()); ok {
+		cl.tenant = tenant.Name
+	}
+	if raw := c.Query("ids"); raw != "" {
+		cl.ids = make(map[int64]bool)
+		for _, idStr := range strings.Split(raw, ",") {
+			id, ok := parseID(idStr)
+			if !ok {
+				c.JSON(http.StatusBadRequest, api.NewErrorResponse(c, 400, invalidIDMessage(idStr, "song")))
+				return
+			}
+			cl.ids[id] = true
+		}
+	}
+
+	// 长连接不受 SERVER_WRITE_TIMEOUT 限制
+	if err := http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{}); err != nil {
+		logging.From(c.Request.Context()).Warn("failed to clear write deadline", "error", err)
+	}
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	// 关闭Nginx等反向代理的响应缓冲，事件才能及时送达
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	events.register <- cl
+	defer func() { events.unregister <- cl }()
+
+	fmt.Fprint(c.Writer, ": connected\n\n")
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(eventsHeartbeatInterval)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case <-middleware.ShutdownStarted():
+			return
+		case <-heartbeat.C:
+			fmt.Fprint(c.Writer, ": ping\n\n")
+		case ev, ok := <-cl.send:
+			if !ok {
+				return
+			}
+			data, _ := json.Marshal(ev)
+			fmt.Fprintf(c.Writer, "data: %s\n\n", data)
+		}
+		c.Writer.Flush()
+	}
+}
+
+// RunCacheReaper 每隔 interval 清除内存缓存 (含Redis不可用时的内存缓存) 中已过期的项，
+// 清除的歌曲地址由缓存的 onExpire 通过 /events 与webhook通知客户端；Redis中的缓存项由Redis自行过期，不产生事件
+func RunCacheReaper(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		var mc *memoryCache
+		switch cache := responseCache.(type) {
+		case *memoryCache:
+			mc = cache
+		case *redisCache:
+			mc = cache.fallback
+		}
+		if mc != nil {
+			mc.reapExpired(time.Now())
+		}
+	}
+}
+
+// publishSongExpired 响应缓存的 onExpire：歌曲地址的缓存项过期移除时推送 song_expired 事件，其他缓存项忽略
+func publishSongExpired(key string) {
+	if ev, ok := songExpiredEvent(key); ok {
+		publishSongEvent(ev)
+	}
+}
+
+// publishSongEvent 将事件推送给 /events 连接与注册的webhook
+func publishSongEvent(ev songEvent) {
+	events.publish(ev)
+	if webhooks != nil {
+		webhooks.publish(ev)
+	}
+}
+
+// songExpiredEvent 从歌曲地址的缓存键 pms:songurl:<id>:<level>[:ip:<realIP>][:t:<租户>] 生成事件，其他缓存键返回 false
+func songExpiredEvent(key string) (songEvent, bool) {
+	rest, ok := strings.CutPrefix(key, "pms:songurl:")
+	if !ok {
+		return songEvent{}, false
+	}
+	// realIP 与音质中不会出现 :t:，租户名称为其后的全部内容
+	rest, tenant, _ := strings.Cut(rest, ":t:")
+	parts := strings.SplitN(rest, ":", 3)
+	if len(parts) < 2 {
+		return songEvent{}, false
+	}
+	id, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return songEvent{}, false
+	}
+	return songEvent{Type: "song_expired", ID: id, Level: parts[1], tenant: tenant}, true
+}
@@ -0,0 +1,208 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"PMS/internal/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestSongExpiredEvent(t *testing.T) {
+	tests := []struct {
+		key    string
+		want   songEvent
+		wantOK bool
+	}{
+		{key: "pms:songurl:1:exhigh", want: songEvent{Type: "song_expired", ID: 1, Level: "exhigh"}, wantOK: true},
+		{key: "pms:songurl:1:exhigh:ip:1.2.3.4", want: songEvent{Type: "song_expired", ID: 1, Level: "exhigh"}, wantOK: true},
+		{key: "pms:songurl:1:exhigh:t:acme", want: songEvent{Type: "song_expired", ID: 1, Level: "exhigh", tenant: "acme"}, wantOK: true},
+		{key: "pms:songurl:1:exhigh:ip:2001:db8::1:t:acme:eu", want: songEvent{Type: "song_expired", ID: 1, Level: "exhigh", tenant: "acme:eu"}, wantOK: true},
+		{key: "pms:stale:songurl:1:exhigh"},
+		{key: "pms:neg:songurl:1:exhigh:false"},
+		{key: "pms:lyric:1"},
+		{key: "pms:songurl:abc:exhigh"},
+	}
+	for _, tt := range tests {
+		got, ok := songExpiredEvent(tt.key)
+		if ok != tt.wantOK || got != tt.want {
+			t.Errorf("songExpiredEvent(%q) = %+v, %t; want %+v, %t", tt.key, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+// expiryRecorder 记录 memoryCache 通知的过期键
+type expiryRecorder struct {
+	mu   sync.Mutex
+	keys []string
+}
+
+func (r *expiryRecorder) record(key string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.keys = append(r.keys, key)
+}
+
+func (r *expiryRecorder) take() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	keys := r.keys
+	r.keys = nil
+	return keys
+}
+
+func TestMemoryCacheNotifiesExpiredEntries(t *testing.T) {
+	ctx := context.Background()
+	var rec expiryRecorder
+	cache := newMemoryCache(2)
+	cache.onExpire = rec.record
+	wait := func() { time.Sleep(20 * time.Millisecond) }
+
+	// 过期后先被访问，在清理前即通知
+	cache.Set(ctx, "get", []byte("v"), 10*time.Millisecond)
+	wait()
+	if _, ok := cache.Get(ctx, "get"); ok {
+		t.Fatal("Get returned an expired entry")
+	}
+	if got := rec.take(); len(got) != 1 || got[0] != "get" {
+		t.Errorf("expired on Get: notified %v, want [get]", got)
+	}
+
+	// 覆盖已过期的项时通知，覆盖未过期的项时不通知
+	cache.Set(ctx, "set", []byte("v"), 10*time.Millisecond)
+	cache.Set(ctx, "set", []byte("v"), time.Minute)
+	wait()
+	cache.Set(ctx, "set", []byte("v"), 10*time.Millisecond)
+	if got := rec.take(); len(got) != 0 {
+		t.Errorf("overwriting a live entry: notified %v, want none", got)
+	}
+	wait()
+	cache.Set(ctx, "set", []byte("v"), time.Minute)
+	if got := rec.take(); len(got) != 1 || got[0] != "set" {
+		t.Errorf("overwriting an expired entry: notified %v, want [set]", got)
+	}
+
+	cache.Set(ctx, "reaped", []byte("v"), time.Millisecond)
+	if got := cache.reapExpired(time.Now().Add(time.Hour)); len(got) != 2 {
+		t.Fatalf("reapExpired = %v, want both entries", got)
+	}
+	if got := rec.take(); len(got) != 2 {
+		t.Errorf("reapExpired: notified %v, want 2 keys", got)
+	}
+}
+
+func TestMemoryCacheNotifiesEvictedExpiredEntries(t *testing.T) {
+	ctx := context.Background()
+	var rec expiryRecorder
+	cache := newMemoryCache(1)
+	cache.onExpire = rec.record
+
+	// 已过期的项被LRU淘汰时通知，未过期的项被淘汰时不通知
+	cache.Set(ctx, "old", []byte("v"), 10*time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	cache.Set(ctx, "new", []byte("v"), time.Minute)
+	if got := rec.take(); len(got) != 1 || got[0] != "old" {
+		t.Errorf("evicting an expired entry: notified %v, want [old]", got)
+	}
+	cache.Set(ctx, "newer", []byte("v"), time.Minute)
+	if got := rec.take(); len(got) != 0 {
+		t.Errorf("evicting a live entry: notified %v, want none", got)
+	}
+}
+
+// eventStream 连接 /events，返回读取下一条事件的函数；tenant 非空时以该租户的身份连接
+func eventStream(t *testing.T, srv *httptest.Server, tenant string) func() (songEvent, bool) {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+"/events?tenant="+tenant, nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GET /events: %v", err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+
+	lines := make(chan string)
+	go func() {
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+		close(lines)
+	}()
+	// 收到 : connected 时连接已注册
+	if line := <-lines; line != ": connected" {
+		t.Fatalf("first line = %q, want the connected comment", line)
+	}
+
+	return func() (songEvent, bool) {
+		timeout := time.After(200 * time.Millisecond)
+		for {
+			select {
+			case line, ok := <-lines:
+				if !ok {
+					return songEvent{}, false
+				}
+				data, found := strings.CutPrefix(line, "data: ")
+				if !found {
+					continue
+				}
+				var ev songEvent
+				if err := json.Unmarshal([]byte(data), &ev); err != nil {
+					t.Fatalf("decoding event %q: %v", data, err)
+				}
+				return ev, true
+			case <-timeout:
+				return songEvent{}, false
+			}
+		}
+	}
+}
+
+func TestEventsDeliverExpiryToMatchingTenant(t *testing.T) {
+	useTestConfig(t, nil)
+	cache := newMemoryCache(10)
+	cache.onExpire = publishSongExpired
+	useResponseCache(t, cache)
+
+	r := gin.New()
+	r.GET("/events", func(c *gin.Context) {
+		// 模拟 APIKey 中间件按租户的API Key附加租户配置
+		if name := c.Query("tenant"); name != "" {
+			c.Request = c.Request.WithContext(config.WithTenant(c.Request.Context(), config.TenantConfig{Name: name}))
+		}
+		GetEvents(c)
+	})
+	srv := httptest.NewServer(r)
+	t.Cleanup(srv.Close)
+
+	global := eventStream(t, srv, "")
+	acme := eventStream(t, srv, "acme")
+
+	ctx := context.Background()
+	cache.Set(ctx, "pms:songurl:1:exhigh", []byte("{}"), time.Millisecond)
+	cache.Set(ctx, "pms:songurl:2:exhigh:t:acme", []byte("{}"), time.Millisecond)
+	time.Sleep(10 * time.Millisecond)
+	// 在清理前访问过期项同样产生事件
+	cache.Get(ctx, "pms:songurl:1:exhigh")
+	cache.Get(ctx, "pms:songurl:2:exhigh:t:acme")
+
+	if ev, ok := global(); !ok || ev.ID != 1 {
+		t.Errorf("global client: event = %+v, %t; want song 1", ev, ok)
+	}
+	if ev, ok := global(); ok {
+		t.Errorf("global client received a tenant event %+v", ev)
+	}
+	if ev, ok := acme(); !ok || ev.ID != 2 {
+		t.Errorf("tenant client: event = %+v, %t; want song 2", ev, ok)
+	}
+	if ev, ok := acme(); ok {
+		t.Errorf("tenant client received another event %+v", ev)
+	}
+}
@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+
+	"PMS/internal/config"
+	"PMS/internal/logging"
+	"PMS/internal/metrics"
+)
+
+// fallbackLevels 返回 level 之后可依次尝试的更低音质；
+// 不在降级链中的音质（如 jyeffect）会尝试整条降级链
+func fallbackLevels(level string) []string {
+	for i, l := range config.Current().LevelFallback {
+		if l == level {
+			return config.Current().LevelFallback[i+1:]
+		}
+	}
+	return config.Current().LevelFallback
+}
+
+// hasPlayableURL 判断响应中是否包含可用的播放地址
+func hasPlayableURL(resp *SongURLResponse) bool {
+	return resp.Code == 200 && len(resp.Data) > 0 && resp.Data[0].URL != ""
+}
+
+// resolve 获取歌曲地址，上游明确答复无法获取的结果在 NEGATIVE_CACHE_TTL 内直接返回，nocache 时跳过
+func (s *SongURLService) resolve(ctx context.Context, songID int64, level, realIP string, nocache, fallback bool) (*SongURLResponse, cacheStatus, error) {
+	negativeTTL := config.Current().NegativeCacheTTL
+	if responseCache == nil || negativeTTL <= 0 {
+		return s.resolveFallback(ctx, songID, level, realIP, nocache, fallback)
+	}
+
+	key := negativeCacheKey(ctx, songID, level, realIP, fallback)
+	if !nocache {
+		// 未命中不计入统计，随后读取歌曲地址缓存时会计入
+		if data, ok := responseCache.Get(ctx, key); ok {
+			var resp SongURLResponse
+			if json.Unmarshal(data, &resp) == nil {
+				metrics.CacheHits.Add(1)
+				return &resp, cacheNegative, nil
+			}
+		}
+	}
+
+	resp, status, err := s.resolveFallback(ctx, songID, level, realIP, nocache, fallback)
+	if err == nil && isDefinitiveMiss(resp) {
+		cacheSetJSON(ctx, key, resp, negativeTTL)
+	}
+	return resp, status, err
+}
+
+// isDefinitiveMiss 判断结果是否为上游对歌曲本身的明确答复：code 非200 (如歌曲已下架的404) 或降级后仍没有播放地址；
+// Cookie失效、请求过于频繁 (-110、405) 与上游故障 (5xx) 的 code 与歌曲无关，是暂时性错误，不视为明确答复
+func isDefinitiveMiss(resp *SongURLResponse) bool {
+	if resp.Code != 200 {
+		return !cookieFailureCodes[resp.Code] && resp.Code != -110 && resp.Code < 500
+	}
+	return !hasPlayableURL(resp)
+}
+
+// resolveFallback 获取歌曲地址，请求的音质没有可用地址时按降级链依次尝试更低音质，
+// 返回的 ServedLevel 为实际提供的音质，fallback 为 false 时只尝试请求的音质
+func (s *SongURLService) resolveFallback(ctx context.Context, songID int64, level, realIP string, nocache, fallback bool) (*SongURLResponse, cacheStatus, error) {
+	resp, status, err := s.cached(ctx, songID, level, realIP, nocache)
+	if err != nil || !fallback || hasPlayableURL(resp) || resp.Code != 200 {
+		if resp != nil {
+			resp.annotate(level, level)
+		}
+		return resp, status, err
+	}
+
+	for _, lower := range fallbackLevels(level) {
+		lowerResp, lowerStatus, err := s.cached(ctx, songID, lower, realIP, nocache)
+		if err != nil {
+			return nil, lowerStatus, err
+		}
+		if hasPlayableURL(lowerResp) {
+			logging.From(ctx).Info("song not available at requested level, falling back",
+				"song_id", songID,
+				"level", level,
+				"served_level", lower,
+			)
+			lowerResp.annotate(level, lower)
+			return lowerResp, lowerStatus, nil
+		}
+	}
+
+	// 降级链耗尽，返回原始请求音质的结果
+	resp.annotate(level, level)
+	return resp, status, nil
+}
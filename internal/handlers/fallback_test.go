@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"PMS/internal/netease"
+)
+
+// unavailableSong 返回上游明确答复没有播放地址的响应
+func unavailableSong(id int64) *netease.SongURLResponse {
+	return &netease.SongURLResponse{Code: 200, Data: []netease.SongURLData{{ID: id, Code: 404}}}
+}
+
+func TestNegativeCache(t *testing.T) {
+	tests := []struct {
+		name       string
+		setup      func(f *netease.Fake)
+		wantStatus int
+		// 第二次请求是否命中无结果缓存
+		wantCached bool
+	}{
+		{
+			name:       "song without playable URL",
+			setup:      func(f *netease.Fake) { f.SetSongURL(1, "standard", unavailableSong(1)) },
+			wantStatus: http.StatusNotFound,
+			wantCached: true,
+		},
+		{
+			name:       "song removed upstream",
+			setup:      func(f *netease.Fake) { f.SetSongURL(1, "standard", &netease.SongURLResponse{Code: 404}) },
+			wantStatus: http.StatusNotFound,
+			wantCached: true,
+		},
+		{
+			name:       "other client error code",
+			setup:      func(f *netease.Fake) { f.SetSongURL(1, "standard", &netease.SongURLResponse{Code: 400}) },
+			wantStatus: http.StatusBadGateway,
+			wantCached: true,
+		},
+		{
+			name:       "upstream rate limited",
+			setup:      func(f *netease.Fake) { f.SetSongURL(1, "standard", &netease.SongURLResponse{Code: 405}) },
+			wantStatus: http.StatusTooManyRequests,
+		},
+		{
+			name:       "upstream busy",
+			setup:      func(f *netease.Fake) { f.SetSongURL(1, "standard", &netease.SongURLResponse{Code: -110}) },
+			wantStatus: http.StatusTooManyRequests,
+		},
+		{
+			name:       "upstream server error",
+			setup:      func(f *netease.Fake) { f.SetSongURL(1, "standard", &netease.SongURLResponse{Code: 500}) },
+			wantStatus: http.StatusBadGateway,
+		},
+		{
+			name:       "cookie expired",
+			setup:      func(f *netease.Fake) { f.SetSongURL(1, "standard", &netease.SongURLResponse{Code: 301}) },
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "upstream timeout",
+			setup:      func(f *netease.Fake) { f.SetError(1, "standard", netease.ErrTimeout) },
+			wantStatus: http.StatusGatewayTimeout,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useTestConfig(t, map[string]string{"NEGATIVE_CACHE_TTL": "1m"})
+			useResponseCache(t, newMemoryCache(10))
+			fake := netease.NewFake()
+			tt.setup(fake)
+			s := NewSongURLService(fake)
+
+			serve(s.GetSongURL, http.MethodGet, "/song?id=1")
+			w := serve(s.GetSongURL, http.MethodGet, "/song?id=1")
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d; body %s", w.Code, tt.wantStatus, w.Body)
+			}
+			wantCalls, wantHeader := 2, string(cacheMiss)
+			if tt.wantCached {
+				wantCalls, wantHeader = 1, string(cacheNegative)
+			}
+			if got := fake.Calls(1, "standard"); got != wantCalls {
+				t.Errorf("upstream calls = %d, want %d", got, wantCalls)
+			}
+			if got := w.Header().Get("X-PMS-Cache"); got != wantHeader {
+				t.Errorf("X-PMS-Cache = %q, want %q", got, wantHeader)
+			}
+		})
+	}
+}
+
+func TestNegativeCacheExpiresAndBypass(t *testing.T) {
+	useTestConfig(t, map[string]string{"NEGATIVE_CACHE_TTL": "50ms"})
+	useResponseCache(t, newMemoryCache(10))
+	fake := netease.NewFake()
+	fake.SetSongURL(1, "standard", unavailableSong(1))
+	s := NewSongURLService(fake)
+
+	serve(s.GetSongURL, http.MethodGet, "/song?id=1")
+	if w := serve(s.GetSongURL, http.MethodGet, "/song?id=1&nocache=1"); w.Header().Get("X-PMS-Cache") != string(cacheBypass) {
+		t.Errorf("nocache=1: X-PMS-Cache = %q, want %q", w.Header().Get("X-PMS-Cache"), cacheBypass)
+	}
+	if got := fake.Calls(1, "standard"); got != 2 {
+		t.Fatalf("upstream calls = %d, want nocache=1 to skip the negative cache", got)
+	}
+
+	// 歌曲恢复可用后，无结果缓存过期前仍不请求上游
+	fake.SetSongURL(1, "standard", playableSong(1))
+	serve(s.GetSongURL, http.MethodGet, "/song?id=1")
+	if got := fake.Calls(1, "standard"); got != 2 {
+		t.Errorf("within NEGATIVE_CACHE_TTL: upstream calls = %d, want 2", got)
+	}
+	time.Sleep(80 * time.Millisecond)
+	if w := serve(s.GetSongURL, http.MethodGet, "/song?id=1"); w.Code != http.StatusOK || fake.Calls(1, "standard") != 3 {
+		t.Errorf("after NEGATIVE_CACHE_TTL: status = %d, upstream calls = %d, want %d and 3", w.Code, fake.Calls(1, "standard"), http.StatusOK)
+	}
+}
+
+func TestNegativeCacheDisabled(t *testing.T) {
+	useTestConfig(t, map[string]string{"NEGATIVE_CACHE_TTL": "0"})
+	useResponseCache(t, newMemoryCache(10))
+	fake := netease.NewFake()
+	fake.SetSongURL(1, "standard", unavailableSong(1))
+	s := NewSongURLService(fake)
+
+	for range 2 {
+		serve(s.GetSongURL, http.MethodGet, "/song?id=1")
+	}
+	if got := fake.Calls(1, "standard"); got != 2 {
+		t.Errorf("upstream calls = %d with NEGATIVE_CACHE_TTL=0, want 2", got)
+	}
+}
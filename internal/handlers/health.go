@@ -0,0 +1,312 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"PMS/internal/config"
+	"PMS/internal/logging"
+	"PMS/internal/metrics"
+	"PMS/internal/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 深度检查使用的歌曲ID，只需要上游能正常响应，不关心是否有可用地址
+const healthProbeSongID = "33894312"
+
+// dependencyStatus 一个依赖的探测结果；URL 与 Error 可能暴露内部地址，只在 /admin/health 中返回
+type dependencyStatus struct {
+	Name      string `json:"name"`
+	URL       string `json:"url,omitempty"`
+	Status    string `json:"status"`
+	LatencyMS int64  `json:"latency_ms"`
+	Error     string `json:"error,omitempty"`
+}
+
+// healthProbeResult 缓存的探测结果，避免编排系统频繁探测时压垮上游
+type healthProbeResult struct {
+	upstreamUp bool
+	// 最快可达上游的延迟，全部不可达时为首个上游的耗时
+	upstreamLatencyMS int64
+	dependencies      []dependencyStatus
+	checkedAt         time.Time
+}
+
+// 进程启动时间，用于 /health 中的 uptime_seconds
+var startTime = time.Now()
+
+// HealthService 处理 /health 与 /ready，通过构造时传入的 UpstreamTransport 探测各上游实例
+type HealthService struct {
+	transport *UpstreamTransport
+
+	probeMu   sync.Mutex
+	probeLast *healthProbeResult
+}
+
+func NewHealthService(transport *UpstreamTransport) *HealthService {
+	return &HealthService{transport: transport}
+}
+
+// 启动时确认上游可达后置为 true，在此之前 /readyz 返回503
+var startupReady atomic.Bool
+
+// 启动时等待上游可达的重试间隔
+const startupProbeInterval = 2 * time.Second
+
+// ProbeResponse /healthz 与 /readyz 的响应
+type ProbeResponse struct {
+	Status string `json:"status"`
+	Reason string `json:"reason"`
+}
+
+// GetHealthz 处理 /healthz 与 /live 存活检查，进程能处理请求即返回200；
+// 上游故障不影响存活状态，避免编排系统无意义地重启实例
+func GetHealthz(c *gin.Context) {
+	c.JSON(http.StatusOK, ProbeResponse{Status: "ok", Reason: "process is running"})
+}
+
+// GetReadyz 就绪检查，启动校验完成前、WARMUP_BLOCK_READY=true 时缓存预热完成前与停机期间返回503
+func GetReadyz(c *gin.Context) {
+	switch {
+	case middleware.ShuttingDown():
+		c.JSON(http.StatusServiceUnavailable, ProbeResponse{Status: "not_ready", Reason: "server is shutting down"})
+	case !startupReady.Load():
+		c.JSON(http.StatusServiceUnavailable, ProbeResponse{Status: "not_ready", Reason: "waiting for upstream music API to become reachable"})
+	case warmupPending.Load():
+		c.JSON(http.StatusServiceUnavailable, ProbeResponse{Status: "not_ready", Reason: "warming cache"})
+	default:
+		c.JSON(http.StatusOK, ProbeResponse{Status: "ready", Reason: "startup checks passed"})
+	}
+}
+
+// GetReady 处理 /ready，上游可达且Cookie未过期 (WARMUP_BLOCK_READY=true 时还需缓存预热完成) 时才返回200；
+// 上游探测结果按 HEALTH_PROBE_CACHE_TTL 缓存，多数请求无需访问上游
+func (h *HealthService) GetReady(c *gin.Context) {
+	if middleware.ShuttingDown() {
+		c.JSON(http.StatusServiceUnavailable, ProbeResponse{Status: "not_ready", Reason: "server is shutting down"})
+		return
+	}
+	if warmupPending.Load() {
+		c.JSON(http.StatusServiceUnavailable, ProbeResponse{Status: "not_ready", Reason: "warming cache"})
+		return
+	}
+	if probe := h.probeDependencies(c.Request.Context()); !probe.upstreamUp {
+		c.JSON(http.StatusServiceUnavailable, ProbeResponse{Status: "not_ready", Reason: "upstream music API is unreachable"})
+		return
+	}
+	// 匿名模式不依赖Cookie；尚未检查或关闭了检查时不视为未就绪
+	if !AnonymousMode() && currentCookieState() == cookieExpired {
+		c.JSON(http.StatusServiceUnavailable, ProbeResponse{Status: "not_ready", Reason: "cookie has expired"})
+		return
+	}
+	c.JSON(http.StatusOK, ProbeResponse{Status: "ready", Reason: "ready to serve requests"})
+}
+
+// WaitForUpstream 启动时反复探测上游，直到任一实例可达后标记为就绪；
+// STARTUP_UPSTREAM_CHECK=false 时跳过探测直接就绪
+func (h *HealthService) WaitForUpstream(ctx context.Context) {
+	if !config.Current().StartupUpstreamCheck {
+		startupReady.Store(true)
+		return
+	}
+
+	ticker := time.NewTicker(startupProbeInterval)
+	defer ticker.Stop()
+	for attempt := 1; ; attempt++ {
+		probeCtx, cancel := context.WithTimeout(ctx, config.Current().HealthProbeTimeout)
+		for _, target := range h.transport.targets {
+			if status := h.transport.probe(probeCtx, target); status.Status == "up" {
+				cancel()
+				startupReady.Store(true)
+				logging.Logger.Info("upstream reachable, server is ready", "upstream_url", target.base, "attempts", attempt)
+				return
+			}
+		}
+		cancel()
+		if attempt == 1 {
+			logging.Logger.Warn("upstream unreachable at startup, readiness pending", "retry_interval", startupProbeInterval.String())
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// GetHealth 处理 GET /health，探测上游与Redis，上游全部不可达时返回503
+func (h *HealthService) GetHealth(c *gin.Context) {
+	health := gin.H{
+		"status":            "ok",
+		"service":           "PublicMusicService",
+		"version":           "1.0.0",
+		"timestamp":         time.Now().Unix(),
+		"uptime_seconds":    int64(time.Since(startTime).Seconds()),
+		"mock_upstream":     config.Current().MockUpstream,
+		"cookie_configured": !AnonymousMode(),
+		"cookie":            currentCookieState(),
+		"cookie_valid":      currentCookieState() == cookieValid && !cookieRefreshFailed.Load(),
+		"cookie_checked_at": cookieCheckedAt(),
+		"cookie_expires_at": cookieExpiresAt(),
+		"circuit_state":     h.transport.circuitState(),
+		"event_clients":     EventClients(),
+	}
+	if active, failed := currentCookiePool().Counts(); active+failed > 0 {
+		health["cookie_pool"] = gin.H{"active": active, "failed": failed}
+	}
+	if responseCache != nil {
+		cache := gin.H{
+			"hits":   metrics.CacheHits.Load(),
+			"misses": metrics.CacheMisses.Load(),
+		}
+		cache["backend"] = CacheBackend()
+		if mc, ok := responseCache.(*memoryCache); ok {
+			cache["size"] = mc.Len()
+			health["cache_size"] = mc.Len()
+		}
+		health["cache"] = cache
+	}
+	// 停机期间返回503，让负载均衡器停止转发流量
+	if middleware.ShuttingDown() {
+		health["status"] = "shutting_down"
+		c.JSON(http.StatusServiceUnavailable, health)
+		return
+	}
+
+	probe := h.probeDependencies(c.Request.Context())
+	health["upstream_reachable"] = probe.upstreamUp
+	health["upstream_latency_ms"] = probe.upstreamLatencyMS
+	health["dependencies"] = publicDependencies(probe.dependencies)
+	health["checked_at"] = probe.checkedAt.Unix()
+	if !probe.upstreamUp {
+		health["status"] = "upstream_unavailable"
+		c.JSON(http.StatusServiceUnavailable, health)
+		return
+	}
+	c.JSON(http.StatusOK, health)
+}
+
+// GetAdminHealth 处理 GET /admin/health，返回各上游实例的地址与状态以及依赖探测的错误详情
+func (h *HealthService) GetAdminHealth(c *gin.Context) {
+	probe := h.probeDependencies(c.Request.Context())
+	c.JSON(http.StatusOK, gin.H{
+		"code":               200,
+		"upstream_reachable": probe.upstreamUp,
+		"upstreams":          h.transport.targetsHealth(),
+		"active_upstream":    h.transport.active.Load(),
+		"dependencies":       probe.dependencies,
+		"checked_at":         probe.checkedAt.Unix(),
+	})
+}
+
+// publicDependencies 去掉探测结果中的地址与错误详情，公开的 /health 只返回名称、状态与延迟
+func publicDependencies(deps []dependencyStatus) []dependencyStatus {
+	public := make([]dependencyStatus, len(deps))
+	for i, dep := range deps {
+		public[i] = dependencyStatus{Name: dep.Name, Status: dep.Status, LatencyMS: dep.LatencyMS}
+	}
+	return public
+}
+
+// probeDependencies 返回缓存的探测结果，过期后重新探测；并发请求共用同一次探测
+func (h *HealthService) probeDependencies(ctx context.Context) *healthProbeResult {
+	h.probeMu.Lock()
+	defer h.probeMu.Unlock()
+
+	if last := h.probeLast; last != nil && time.Since(last.checkedAt) < config.Current().HealthProbeCacheTTL {
+		return last
+	}
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), config.Current().HealthProbeTimeout)
+	defer cancel()
+
+	targets := h.transport.targets
+	result := &healthProbeResult{dependencies: make([]dependencyStatus, len(targets))}
+	var wg sync.WaitGroup
+	for i, target := range targets {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result.dependencies[i] = h.transport.probe(ctx, target)
+		}()
+	}
+	var redisStatus *dependencyStatus
+	if rc, ok := responseCache.(*redisCache); ok {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			status := probeRedis(ctx, rc)
+			redisStatus = &status
+		}()
+	}
+	wg.Wait()
+	if redisStatus != nil {
+		result.dependencies = append(result.dependencies, *redisStatus)
+	}
+
+	if len(result.dependencies) > 0 {
+		result.upstreamLatencyMS = result.dependencies[0].LatencyMS
+	}
+	for _, dep := range result.dependencies {
+		if dep.Name != "upstream" || dep.Status != "up" {
+			continue
+		}
+		if !result.upstreamUp || dep.LatencyMS < result.upstreamLatencyMS {
+			result.upstreamLatencyMS = dep.LatencyMS
+		}
+		result.upstreamUp = true
+	}
+	result.checkedAt = time.Now()
+	h.probeLast = result
+	return result
+}
+
+// probe 直接请求上游实例一次，不经过熔断器与重试，也不携带Cookie
+func (t *UpstreamTransport) probe(ctx context.Context, target *upstreamTarget) dependencyStatus {
+	status := dependencyStatus{Name: "upstream", URL: target.base, Status: "up"}
+	apiURL := target.base + upstreamURL("/song/url/v1", url.Values{
+		"id":    {healthProbeSongID},
+		"level": {config.AnonymousLevel},
+	}, "")
+
+	start := time.Now()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, apiURL, nil)
+	if err == nil {
+		var resp *http.Response
+		if resp, err = t.client.Do(req); err == nil {
+			resp.Body.Close()
+			if resp.StatusCode >= http.StatusInternalServerError {
+				status.Status = "down"
+				status.Error = http.StatusText(resp.StatusCode)
+			}
+		}
+	}
+	status.LatencyMS = time.Since(start).Milliseconds()
+	if err != nil {
+		status.Status = "down"
+		status.Error = errUpstreamRequest.Error()
+		if isTimeout(err) {
+			status.Error = errUpstreamTimeout.Error()
+		}
+	}
+	return status
+}
+
+// probeRedis 检查共享缓存是否可用；Redis不可用时请求会回源，不影响整体状态码
+func probeRedis(ctx context.Context, rc *redisCache) dependencyStatus {
+	status := dependencyStatus{Name: "redis", Status: "up"}
+	start := time.Now()
+	err := rc.client.Ping(ctx).Err()
+	status.LatencyMS = time.Since(start).Milliseconds()
+	if err != nil {
+		status.Status = "down"
+		status.Error = err.Error()
+	}
+	return status
+}
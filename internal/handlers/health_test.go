@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestHealthHidesDependencyDetails(t *testing.T) {
+	transport := newTestUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "maintenance", http.StatusServiceUnavailable)
+	}, nil)
+	upstreamURL := transport.targets[0].base
+	h := NewHealthService(transport)
+
+	w := serve(h.GetHealth, http.MethodGet, "/health")
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("/health status = %d, want 503 with the only upstream down", w.Code)
+	}
+	if body := w.Body.String(); strings.Contains(body, upstreamURL) || strings.Contains(body, `"error"`) {
+		t.Errorf("/health body = %s, want no upstream URL or error details", body)
+	}
+	health := decodeBody[struct {
+		Dependencies []map[string]any `json:"dependencies"`
+	}](t, w)
+	if len(health.Dependencies) != 1 || health.Dependencies[0]["status"] != "down" {
+		t.Errorf("dependencies = %v, want the upstream reported down", health.Dependencies)
+	}
+
+	w = serve(h.GetAdminHealth, http.MethodGet, "/admin/health")
+	admin := decodeBody[struct {
+		Dependencies []dependencyStatus `json:"dependencies"`
+	}](t, w)
+	if len(admin.Dependencies) != 1 || admin.Dependencies[0].URL != upstreamURL || admin.Dependencies[0].Error == "" {
+		t.Errorf("/admin/health dependencies = %+v, want URL %s with the error", admin.Dependencies, upstreamURL)
+	}
+}
@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"PMS/internal/config"
+	"PMS/internal/netease"
+
+	"github.com/gin-gonic/gin"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+// useTestConfig 以默认值加上 env 加载配置并设为当前配置，测试结束后恢复原配置；
+// 未设置Cookie时与匿名模式一样默认使用 standard 音质
+func useTestConfig(t *testing.T, env map[string]string) *config.Config {
+	t.Helper()
+	for key, value := range env {
+		t.Setenv(key, value)
+	}
+	cfg, err := config.Load(AnonymousMode())
+	if err != nil {
+		t.Fatalf("config.Load: %v", err)
+	}
+	prev := config.Current()
+	config.Store(cfg)
+	t.Cleanup(func() { config.Store(prev) })
+	return cfg
+}
+
+// newTestUpstream 启动以 handler 应答的模拟上游，并按 env 加载指向它的配置
+func newTestUpstream(t *testing.T, handler http.HandlerFunc, env map[string]string) *UpstreamTransport {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+
+	merged := map[string]string{"NETEASE_MUSIC_API": srv.URL}
+	for key, value := range env {
+		merged[key] = value
+	}
+	return NewUpstreamTransport(useTestConfig(t, merged))
+}
+
+// writeJSON 以200返回 body 的JSON编码
+func writeJSON(w http.ResponseWriter, body any) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	json.NewEncoder(w).Encode(body)
+}
+
+// serve 以 handler 处理一次 method 请求，路由取 target 的路径
+func serve(handler gin.HandlerFunc, method, target string) *httptest.ResponseRecorder {
+	u, _ := url.Parse(target)
+	r := gin.New()
+	r.Handle(method, u.Path, handler)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(method, target, nil))
+	return w
+}
+
+// decodeBody 解析JSON响应体，失败时终止测试
+func decodeBody[T any](t *testing.T, w *httptest.ResponseRecorder) T {
+	t.Helper()
+	var v T
+	if err := json.Unmarshal(w.Body.Bytes(), &v); err != nil {
+		t.Fatalf("decoding response %q: %v", w.Body.String(), err)
+	}
+	return v
+}
+
+// playableSong 返回 id 可播放的上游响应，地址有效期20分钟
+func playableSong(id int64) *netease.SongURLResponse {
+	return &netease.SongURLResponse{
+		Code: 200,
+		Data: netease.SongURLList{{ID: id, URL: "http://m701.music.126.net/test.mp3", Br: 320000, Code: 200, Expi: 1200, Type: "mp3"}},
+	}
+}
+
+// useResponseCache 替换全局响应缓存，测试结束后恢复
+func useResponseCache(t *testing.T, cache ResponseCache) {
+	t.Helper()
+	prev := responseCache
+	responseCache = cache
+	t.Cleanup(func() { responseCache = prev })
+}
+
+// useCookie 设置当前Cookie，测试结束后恢复原Cookie
+func useCookie(t *testing.T, cookie string) {
+	t.Helper()
+	prev := cookieValue.Load()
+	SetCookie(cookie)
+	t.Cleanup(func() { cookieValue.Store(prev) })
+}
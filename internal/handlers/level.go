@@ -0,0 +1,31 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"PMS/internal/api"
+	"PMS/internal/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AnonymousMode 未配置 NETEASE_COOKIE 时以匿名模式运行，仅能获取标准音质
+func AnonymousMode() bool {
+	return currentCookie() == ""
+}
+
+// checkLevel 校验请求中的音质参数，无效时写入400响应；
+// 匿名模式下请求高于标准的音质时写入403响应
+func checkLevel(c *gin.Context, level string) bool {
+	if !config.IsValidLevel(level) {
+		c.JSON(http.StatusBadRequest, api.NewErrorResponse(c, 400, config.InvalidLevelMessage(level)))
+		return false
+	}
+	// 租户使用自己的Cookie，不受全局匿名模式限制
+	if _, tenant := config.TenantFrom(c.Request.Context()); AnonymousMode() && !tenant && level != config.AnonymousLevel {
+		c.JSON(http.StatusForbidden, api.NewErrorResponse(c, 403, fmt.Sprintf("Level %q requires NETEASE_COOKIE to be configured, only %q is available in anonymous mode", level, config.AnonymousLevel)))
+		return false
+	}
+	return true
+}
@@ -0,0 +1,168 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"PMS/internal/api"
+	"PMS/internal/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+// LyricResponse 整理后的歌词，原文、翻译与罗马音均为LRC格式文本
+type LyricResponse struct {
+	Code int `json:"code"`
+	// 上游返回的错误说明，只用于错误响应
+	Message      string `json:"-"`
+	Lyric        string `json:"lyric"`
+	Translation  string `json:"translation,omitempty"`
+	Romanization string `json:"romanization,omitempty"`
+	Instrumental bool   `json:"instrumental,omitempty"`
+}
+
+// lyricCacheKey 生成歌词的缓存键，租户的请求附加租户名称
+func lyricCacheKey(ctx context.Context, songID int64) string {
+	return fmt.Sprintf("pms:lyric:%d", songID) + tenantKeySuffix(ctx)
+}
+
+// fetchLyric 向上游请求歌词
+func (s *CatalogService) fetchLyric(ctx context.Context, songID int64, realIP string) (*LyricResponse, error) {
+	lyricResp, err := s.client.Lyric(ctx, songID, realIP)
+	if err != nil {
+		return nil, err
+	}
+
+	return &LyricResponse{
+		Code:         lyricResp.Code,
+		Message:      lyricResp.Message,
+		Lyric:        lyricResp.Lrc.Lyric,
+		Translation:  lyricResp.Tlyric.Lyric,
+		Romanization: lyricResp.Romalrc.Lyric,
+		Instrumental: lyricResp.NoLyric,
+	}, nil
+}
+
+// getLyricCached 优先从缓存读取歌词，未命中时请求上游并写入缓存
+func (s *CatalogService) getLyricCached(ctx context.Context, songID int64, realIP string, nocache bool) (*LyricResponse, error) {
+	key := lyricCacheKey(ctx, songID)
+
+	if responseCache != nil && !nocache {
+		var lyricResp LyricResponse
+		if cacheGetJSON(ctx, key, &lyricResp) {
+			return &lyricResp, nil
+		}
+	}
+
+	lyricResp, err := s.fetchLyric(ctx, songID, realIP)
+	if err != nil {
+		return nil, err
+	}
+
+	if responseCache != nil && lyricResp.Code == 200 {
+		cacheSetJSON(ctx, key, lyricResp, config.Current().LyricCacheTTL)
+	}
+	return lyricResp, nil
+}
+
+// GetLyric 处理 GET /lyric?id=，format=lrc 时返回可直接保存的LRC文本
+func (s *CatalogService) GetLyric(c *gin.Context) {
+	// 获取歌曲ID
+	songID, ok := parseSongID(c, c.Query("id"))
+	if !ok {
+		return
+	}
+
+	realIP := c.DefaultQuery("realip", defaultRealIP(c))
+	nocache := c.Query("nocache") == "1"
+	format := c.DefaultQuery("format", "json")
+	if format != "json" && format != "lrc" {
+		c.JSON(http.StatusBadRequest, api.NewErrorResponse(c, 400, "Invalid format, allowed values: json, lrc"))
+		return
+	}
+
+	lyricResp, err := s.getLyricCached(c.Request.Context(), songID, realIP, nocache)
+	if err != nil {
+		respondUpstreamError(c, err)
+		return
+	}
+
+	// 检查网易云音乐API返回的状态码
+	if lyricResp.Code != 200 {
+		respondUpstreamCode(c, lyricResp.Code, lyricResp.Message)
+		return
+	}
+
+	// 纯音乐没有歌词
+	if lyricResp.Instrumental {
+		c.Status(http.StatusNoContent)
+		return
+	}
+
+	// 默认不返回翻译歌词，translate=1 或 tlyric=1 时才包含
+	withTranslation := c.Query("translate") == "1" || c.Query("tlyric") == "1"
+
+	if format == "lrc" {
+		body := lyricResp.Lyric
+		if withTranslation && lyricResp.Translation != "" {
+			body = mergeLRC(lyricResp.Lyric, lyricResp.Translation)
+		}
+		c.Header("Content-Disposition", mime.FormatMediaType("inline", map[string]string{
+			"filename": strconv.FormatInt(songID, 10) + ".lrc",
+		}))
+		c.Data(http.StatusOK, "text/plain; charset=utf-8", []byte(body))
+		return
+	}
+
+	result := *lyricResp
+	if !withTranslation {
+		result.Translation = ""
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// mergeLRC 将翻译歌词按时间标签插入到对应原文行之后
+func mergeLRC(original, translation string) string {
+	translated := make(map[string][]string)
+	for _, line := range strings.Split(translation, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if tag, text := splitLRCTag(line); tag != "" && strings.TrimSpace(text) != "" {
+			translated[tag] = append(translated[tag], line)
+		}
+	}
+
+	var b strings.Builder
+	for _, line := range strings.Split(original, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if line == "" {
+			continue
+		}
+		b.WriteString(line)
+		b.WriteByte('\n')
+
+		if tag, _ := splitLRCTag(line); tag != "" {
+			for _, t := range translated[tag] {
+				b.WriteString(t)
+				b.WriteByte('\n')
+			}
+			delete(translated, tag)
+		}
+	}
+	return b.String()
+}
+
+// splitLRCTag 拆分LRC行开头的时间标签，如 "[00:12.34]歌词" 返回 "[00:12.34]" 与 "歌词"
+func splitLRCTag(line string) (string, string) {
+	if !strings.HasPrefix(line, "[") {
+		return "", line
+	}
+	end := strings.Index(line, "]")
+	if end < 0 {
+		return "", line
+	}
+	return line[:end+1], line[end+1:]
+}
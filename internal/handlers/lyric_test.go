@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+
+	"PMS/internal/netease"
+)
+
+func TestGetLyric(t *testing.T) {
+	lyric := &netease.LyricResponse{
+		Code:   200,
+		Lrc:    netease.LyricText{Lyric: "[00:01.00]hello"},
+		Tlyric: netease.LyricText{Lyric: "[00:01.00]你好"},
+	}
+	tests := []struct {
+		name       string
+		target     string
+		setup      func(f *netease.Fake)
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			name:       "json without translation",
+			target:     "/lyric?id=1",
+			setup:      func(f *netease.Fake) { f.SetLyric(1, lyric) },
+			wantStatus: http.StatusOK,
+			wantBody:   `{"code":200,"lyric":"[00:01.00]hello"}`,
+		},
+		{
+			name:       "lrc with translation",
+			target:     "/lyric?id=1&format=lrc&translate=1",
+			setup:      func(f *netease.Fake) { f.SetLyric(1, lyric) },
+			wantStatus: http.StatusOK,
+			wantBody:   "[00:01.00]hello\n[00:01.00]你好\n",
+		},
+		{
+			name:       "instrumental",
+			target:     "/lyric?id=1",
+			setup:      func(f *netease.Fake) { f.SetLyric(1, &netease.LyricResponse{Code: 200, NoLyric: true}) },
+			wantStatus: http.StatusNoContent,
+		},
+		{
+			name:       "not found upstream",
+			target:     "/lyric?id=1",
+			setup:      func(f *netease.Fake) {},
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "malformed upstream JSON",
+			target:     "/lyric?id=1",
+			setup:      func(f *netease.Fake) { f.SetMethodError("Lyric", netease.ErrParse) },
+			wantStatus: http.StatusBadGateway,
+		},
+		{
+			name:       "upstream timeout",
+			target:     "/lyric?id=1",
+			setup:      func(f *netease.Fake) { f.SetMethodError("Lyric", netease.ErrTimeout) },
+			wantStatus: http.StatusGatewayTimeout,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useTestConfig(t, nil)
+			useResponseCache(t, nil)
+			fake := netease.NewFake()
+			tt.setup(fake)
+
+			w := serve(NewCatalogService(fake).GetLyric, http.MethodGet, tt.target)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d; body %s", w.Code, tt.wantStatus, w.Body)
+			}
+			if tt.wantBody != "" && w.Body.String() != tt.wantBody {
+				t.Errorf("body = %q, want %q", w.Body, tt.wantBody)
+			}
+		})
+	}
+}
@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"PMS/internal/config"
+	"PMS/internal/netease"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MOCK_UPSTREAM=true 时上游实例地址固定为该值，请求由 mockTransport 在进程内应答
+const mockUpstreamBase = "http://mock-upstream.invalid"
+
+// MockAudioPath 模拟模式下由PMS自身提供的静音音频，/song 返回的地址均指向它
+const MockAudioPath = "/mock/silence.wav"
+
+// 模拟模式下未配置Cookie时使用的Cookie，使各音质均可请求
+const mockCookie = "MUSIC_U=mock"
+
+// 1秒的8kHz单声道静音WAV
+//
+//go:embed mockdata/silence.wav
+var mockAudio []byte
+
+// mockSong 模拟上游中的一首歌曲；maxLevel 之上的音质没有可用地址，用于触发音质降级
+type mockSong struct {
+	name     string
+	artist   string
+	album    string
+	maxLevel string
+	lyric    string
+}
+
+// 模拟上游已知的歌曲，其他ID按上游的方式返回不存在
+var mockSongs = map[int64]mockSong{
+	1: {name: "Mock Song", artist: "PMS", album: "Mock Album", maxLevel: "jymaster", lyric: "[00:00.00]PMS mock lyric\n[00:00.50]silence\n"},
+	2: {name: "Mock Song (higher only)", artist: "PMS", album: "Mock Album", maxLevel: "higher", lyric: "[00:00.00]falls back to higher\n"},
+	3: {name: "Mock Instrumental", artist: "PMS", album: "Mock Album", maxLevel: "lossless"},
+}
+
+// 模拟音频各音质的码率
+var mockBitrates = map[string]int{
+	"standard": 128000,
+	"higher":   192000,
+	"exhigh":   320000,
+}
+
+// mockTransport 在进程内应答上游请求，替代真实的网易云音乐API；
+// 请求仍经过上游客户端的重试、熔断、Cookie池与缓存逻辑
+type mockTransport struct{}
+
+func (mockTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+	status, body := mockUpstreamResponse(req.URL.Path, req.URL.Query())
+	data, _ := json.Marshal(body)
+	return &http.Response{
+		StatusCode:    status,
+		Status:        strconv.Itoa(status) + " " + http.StatusText(status),
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": {"application/json; charset=utf-8"}},
+		Body:          io.NopCloser(bytes.NewReader(data)),
+		ContentLength: int64(len(data)),
+		Request:       req,
+	}, nil
+}
+
+// mockUpstreamResponse 按上游接口返回固定数据，未实现的接口返回404
+func mockUpstreamResponse(path string, query url.Values) (int, any) {
+	switch path {
+	case "/song/url/v1":
+		id, _ := strconv.ParseInt(query.Get("id"), 10, 64)
+		return http.StatusOK, mockSongURL(id, query.Get("level"))
+	case "/song/detail":
+		return http.StatusOK, mockSongDetail(query.Get("ids"))
+	case "/lyric":
+		id, _ := strconv.ParseInt(query.Get("id"), 10, 64)
+		return http.StatusOK, mockLyric(id)
+	case "/login/status":
+		return http.StatusOK, gin.H{"data": gin.H{
+			"code":    200,
+			"account": gin.H{"id": 1, "vipType": 11},
+			"profile": gin.H{"userId": 1, "nickname": "PMS Mock User", "vipType": 11},
+		}}
+	default:
+		return http.StatusNotFound, gin.H{"code": 404, "msg": "mock upstream does not implement " + path}
+	}
+}
+
+// mockSongURL 已知歌曲在 maxLevel 及以下返回静音音频地址，未知歌曲与更高音质与上游一样返回空地址
+func mockSongURL(id int64, level string) *netease.SongURLResponse {
+	data := netease.SongURLData{ID: id, Code: 404, Level: level}
+	if song, ok := mockSongs[id]; ok {
+		data.Code = 200
+		if levelRank(level) <= levelRank(song.maxLevel) {
+			data.URL = mockAudioURL()
+			data.Br = mockBitrates[level]
+			if data.Br == 0 {
+				data.Br = 1411000
+			}
+			data.Size = len(mockAudio)
+			data.Type = "wav"
+			data.Expi = 1200
+		}
+	}
+	return &netease.SongURLResponse{Code: 200, Data: []netease.SongURLData{data}}
+}
+
+func mockSongDetail(ids string) *netease.SongDetailResponse {
+	resp := &netease.SongDetailResponse{Code: 200, Songs: []netease.Song{}}
+	for _, value := range strings.Split(ids, ",") {
+		id, _ := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+		song, ok := mockSongs[id]
+		if !ok {
+			continue
+		}
+		resp.Songs = append(resp.Songs, netease.Song{
+			ID:   id,
+			Name: song.name,
+			Ar:   []netease.Artist{{ID: 1, Name: song.artist}},
+			Al:   netease.Album{ID: 1, Name: song.album},
+			Dt:   1000,
+			No:   int(id),
+			Cd:   "01",
+		})
+	}
+	return resp
+}
+
+func mockLyric(id int64) *netease.LyricResponse {
+	song, ok := mockSongs[id]
+	if !ok {
+		return &netease.LyricResponse{Code: 404}
+	}
+	if song.lyric == "" {
+		return &netease.LyricResponse{Code: 200, NoLyric: true}
+	}
+	return &netease.LyricResponse{Code: 200, Lrc: netease.LyricText{Lyric: song.lyric}}
+}
+
+// levelRank 返回音质在 config.ValidLevels 中的位置，越大音质越高
+func levelRank(level string) int {
+	for i, l := range config.ValidLevels {
+		if l == level {
+			return i
+		}
+	}
+	return -1
+}
+
+// mockAudioURL 返回 MOCK_BASE_URL 下的静音音频地址
+func mockAudioURL() string {
+	return strings.TrimRight(config.Current().MockBaseURL, "/") + MockAudioPath
+}
+
+// GetMockAudio 处理 GET /mock/silence.wav，仅在模拟模式下注册
+func GetMockAudio(c *gin.Context) {
+	http.ServeContent(c.Writer, c.Request, "silence.wav", startTime, bytes.NewReader(mockAudio))
+}
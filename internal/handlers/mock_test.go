@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"PMS/internal/api"
+	"PMS/internal/netease"
+
+	"github.com/gin-gonic/gin"
+)
+
+// useMockUpstream 以 MOCK_UPSTREAM=true 加载配置并使用模拟Cookie，返回经进程内模拟上游访问的客户端
+func useMockUpstream(t *testing.T) netease.Client {
+	t.Helper()
+	t.Setenv("MOCK_UPSTREAM", "true")
+	t.Setenv("NETEASE_COOKIE", "")
+	cookie, err := LoadCookie()
+	if err != nil {
+		t.Fatalf("LoadCookie: %v", err)
+	}
+	if cookie != mockCookie {
+		t.Fatalf("LoadCookie = %q in mock mode, want %q", cookie, mockCookie)
+	}
+	cfg := useTestConfig(t, map[string]string{"MOCK_BASE_URL": "http://pms.test/"})
+	useCookie(t, cookie)
+	useResponseCache(t, nil)
+	return netease.NewHTTPClient(NewUpstreamTransport(cfg))
+}
+
+func TestMockUpstreamSongURL(t *testing.T) {
+	s := NewSongURLService(useMockUpstream(t))
+
+	tests := []struct {
+		target     string
+		wantLevel  string
+		wantBr     int
+		downgraded bool
+	}{
+		{target: "/song?id=1&level=exhigh", wantLevel: "exhigh", wantBr: 320000},
+		{target: "/song?id=1&level=lossless", wantLevel: "lossless", wantBr: 1411000},
+		{target: "/song?id=2&level=lossless", wantLevel: "higher", wantBr: 192000, downgraded: true},
+	}
+	for _, tt := range tests {
+		w := serve(s.GetSongURL, http.MethodGet, tt.target)
+		if w.Code != http.StatusOK {
+			t.Fatalf("GET %s: status = %d; body %s", tt.target, w.Code, w.Body)
+		}
+		resp := decodeBody[SongURLResponse](t, w)
+		if resp.ServedLevel != tt.wantLevel || resp.Downgraded != tt.downgraded {
+			t.Errorf("GET %s: served level = %q (downgraded %t), want %q (downgraded %t)", tt.target, resp.ServedLevel, resp.Downgraded, tt.wantLevel, tt.downgraded)
+		}
+		if data := resp.Data[0]; data.URL != "http://pms.test"+MockAudioPath || data.Br != tt.wantBr || data.Type != "wav" {
+			t.Errorf("GET %s: data = %+v, want the mock audio at %d bps", tt.target, data, tt.wantBr)
+		}
+	}
+}
+
+func TestMockUpstreamUnknownSong(t *testing.T) {
+	s := NewSongURLService(useMockUpstream(t))
+
+	w := serve(s.GetSongURL, http.MethodGet, "/song?id=999")
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("status = %d, want %d; body %s", w.Code, http.StatusNotFound, w.Body)
+	}
+	if resp := decodeBody[api.ErrorResponse](t, w); resp.Reason != reasonNotFound {
+		t.Errorf("reason = %q, want %q", resp.Reason, reasonNotFound)
+	}
+}
+
+func TestMockUpstreamLyric(t *testing.T) {
+	s := NewCatalogService(useMockUpstream(t))
+
+	w := serve(s.GetLyric, http.MethodGet, "/lyric?id=1")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "PMS mock lyric") {
+		t.Errorf("GET /lyric?id=1: status = %d, body %s; want the mock lyric", w.Code, w.Body)
+	}
+	if w := serve(s.GetLyric, http.MethodGet, "/lyric?id=3"); w.Code != http.StatusNoContent {
+		t.Errorf("GET /lyric?id=3: status = %d, want %d for an instrumental", w.Code, http.StatusNoContent)
+	}
+	if w := serve(s.GetLyric, http.MethodGet, "/lyric?id=999"); w.Code != http.StatusNotFound {
+		t.Errorf("GET /lyric?id=999: status = %d, want %d", w.Code, http.StatusNotFound)
+	}
+}
+
+func TestGetMockAudio(t *testing.T) {
+	w := serve(GetMockAudio, http.MethodGet, MockAudioPath)
+	if w.Code != http.StatusOK || w.Body.Len() != len(mockAudio) {
+		t.Fatalf("status = %d with %d bytes, want %d bytes", w.Code, w.Body.Len(), len(mockAudio))
+	}
+	if !strings.HasPrefix(w.Body.String(), "RIFF") {
+		t.Error("mock audio is not a WAV file")
+	}
+
+	req := httptest.NewRequest(http.MethodGet, MockAudioPath, nil)
+	req.Header.Set("Range", "bytes=0-3")
+	rec := httptest.NewRecorder()
+	r := gin.New()
+	r.GET(MockAudioPath, GetMockAudio)
+	r.ServeHTTP(rec, req)
+	if rec.Code != http.StatusPartialContent || rec.Body.String() != "RIFF" {
+		t.Errorf("range request: status = %d, body %q; want 206 with the first 4 bytes", rec.Code, rec.Body)
+	}
+}
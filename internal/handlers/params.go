@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"PMS/internal/api"
+	"PMS/internal/config"
+	"PMS/internal/logging"
+	"PMS/internal/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+// parseSongID 校验并解析歌曲ID，失败时直接写入400响应
+func parseSongID(c *gin.Context, idStr string) (int64, bool) {
+	return parseNumericID(c, idStr, "song")
+}
+
+// parseNumericID 校验并解析歌曲、歌单、专辑等数字ID，kind 用于错误提示
+func parseNumericID(c *gin.Context, idStr, kind string) (int64, bool) {
+	if strings.TrimSpace(idStr) == "" {
+		c.JSON(http.StatusBadRequest, api.NewErrorResponse(c, 400, "Missing required parameter: id"))
+		return 0, false
+	}
+
+	id, ok := parseID(idStr)
+	if !ok {
+		c.JSON(http.StatusBadRequest, api.NewErrorResponse(c, 400, invalidIDMessage(idStr, kind)))
+		return 0, false
+	}
+	return id, true
+}
+
+// parseID 解析数字ID：忽略首尾空白，只接受不带正负号的十进制数字，且须为正数
+func parseID(idStr string) (int64, bool) {
+	idStr = strings.TrimSpace(idStr)
+	// ParseInt 接受的 + 与 - 号也视为格式错误
+	if !isDigits(idStr) {
+		return 0, false
+	}
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	return id, err == nil && id > 0
+}
+
+// invalidIDMessage 返回 parseID 解析失败时的提示，区分格式错误与非正数
+func invalidIDMessage(idStr, kind string) string {
+	if idStr = strings.TrimSpace(idStr); isDigits(idStr) && strings.Trim(idStr, "0") == "" {
+		return fmt.Sprintf("Invalid %s id, must be a positive number", kind)
+	}
+	return fmt.Sprintf("Invalid %s id format", kind)
+}
+
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// defaultLevel 返回请求未指定音质时使用的音质：依次为 X-PMS-Flags 覆盖的音质、租户的默认音质与 LEVEL
+func defaultLevel(c *gin.Context) string {
+	if level, ok := middleware.FeatureFlag(c, config.FeatureFlagLevel); ok {
+		return level
+	}
+	if tenant, ok := config.TenantFrom(c.Request.Context()); ok && tenant.Level != "" {
+		return tenant.Level
+	}
+	return config.Current().Level
+}
+
+// defaultRealIP 返回请求未指定 realip 时使用的IP：X-PMS-Flags 覆盖的IP，否则为 REAL_IP
+func defaultRealIP(c *gin.Context) string {
+	if realIP, ok := middleware.FeatureFlag(c, config.FeatureFlagRealIP); ok {
+		return realIP
+	}
+	return config.Current().RealIP
+}
+
+// parsePagination 解析 limit/offset 分页参数，limit 超过上限时按上限处理，
+// 失败时直接写入400响应
+func parsePagination(c *gin.Context, defaultLimit, maxLimit int) (int, int, bool) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultLimit)))
+	if err != nil || limit < 1 {
+		c.JSON(http.StatusBadRequest, api.NewErrorResponse(c, 400, "Invalid limit"))
+		return 0, 0, false
+	}
+	if limit > maxLimit {
+		limit = maxLimit
+	}
+
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		c.JSON(http.StatusBadRequest, api.NewErrorResponse(c, 400, "Invalid offset"))
+		return 0, 0, false
+	}
+	return limit, offset, true
+}
+
+// respondUpstreamError 将上游请求错误写入响应
+func respondUpstreamError(c *gin.Context, err error) {
+	if errors.Is(err, errUpstreamCanceled) && clientGone(c) {
+		return
+	}
+	status := upstreamErrorStatus(err)
+	c.JSON(status, api.NewErrorResponse(c, status, upstreamErrorMessage(err)))
+}
+
+// respondUpstreamCode 将上游返回的非200 code 写入响应，附带上游的 code 与说明 (message)；
+// 上游限流时附带 Retry-After
+func respondUpstreamCode(c *gin.Context, code int, message string) {
+	status, msg := upstreamCodeStatus(code)
+	if status == http.StatusTooManyRequests {
+		c.Header("Retry-After", strconv.Itoa(upstreamRateLimitRetryAfter))
+	}
+	resp := api.NewErrorResponse(c, status, msg)
+	resp.UpstreamCode = code
+	resp.UpstreamMessage = message
+	c.JSON(status, resp)
+}
+
+// clientGone 客户端已断开时记录 debug 日志并以499结束请求，不再写入响应体
+func clientGone(c *gin.Context) bool {
+	if !errors.Is(c.Request.Context().Err(), context.Canceled) {
+		return false
+	}
+	logging.From(c.Request.Context()).Debug("client closed request before response was written")
+	c.AbortWithStatus(statusClientClosedRequest)
+	return true
+}
@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+
+	"PMS/internal/api"
+	"PMS/internal/netease"
+)
+
+func TestParseID(t *testing.T) {
+	tests := []struct {
+		input  string
+		want   int64
+		wantOK bool
+	}{
+		{input: "1", want: 1, wantOK: true},
+		{input: " 42 ", want: 42, wantOK: true},
+		{input: strconv.Itoa(math.MaxInt32), want: math.MaxInt32, wantOK: true},
+		{input: "2147483648", want: math.MaxInt32 + 1, wantOK: true},
+		{input: "4294967296", want: 1 << 32, wantOK: true},
+		{input: strconv.FormatInt(math.MaxInt64, 10), want: math.MaxInt64, wantOK: true},
+		{input: "9223372036854775808"},
+		{input: "0"},
+		{input: "000"},
+		{input: "-1"},
+		{input: "+1"},
+		{input: "1e3"},
+		{input: "12abc"},
+		{input: ""},
+	}
+	for _, tt := range tests {
+		got, ok := parseID(tt.input)
+		if ok != tt.wantOK || (ok && got != tt.want) {
+			t.Errorf("parseID(%q) = %d, %t; want %d, %t", tt.input, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestInvalidIDMessage(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{input: "0", want: "Invalid song id, must be a positive number"},
+		{input: " 00 ", want: "Invalid song id, must be a positive number"},
+		{input: "-1", want: "Invalid song id format"},
+		{input: "abc", want: "Invalid song id format"},
+		{input: "9223372036854775808", want: "Invalid song id format"},
+	}
+	for _, tt := range tests {
+		if got := invalidIDMessage(tt.input, "song"); got != tt.want {
+			t.Errorf("invalidIDMessage(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestGetSongURLLargeID(t *testing.T) {
+	for _, id := range []int64{math.MaxInt32, math.MaxInt32 + 1, 1<<53 + 1} {
+		t.Run(strconv.FormatInt(id, 10), func(t *testing.T) {
+			var gotID string
+			transport := newTestUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+				gotID = r.URL.Query().Get("id")
+				w.Header().Set("Content-Type", "application/json")
+				// 按上游的方式以JSON数字返回ID
+				w.Write([]byte(`{"code":200,"data":[{"id":` + gotID + `,"url":"http://m701.music.126.net/a.mp3","br":128000,"code":200,"expi":1200}]}`))
+			}, nil)
+			useResponseCache(t, nil)
+
+			idStr := strconv.FormatInt(id, 10)
+			w := serve(NewSongURLService(netease.NewHTTPClient(transport)).GetSongURL, http.MethodGet, "/song?id="+idStr)
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d; body %s", w.Code, w.Body)
+			}
+			if gotID != idStr {
+				t.Errorf("upstream id = %q, want %q", gotID, idStr)
+			}
+			// 超过 2^53 的ID在JSON中也须保持精确的数字
+			if !strings.Contains(w.Body.String(), `"id":`+idStr+`,`) {
+				t.Errorf("body %s does not contain id %s", w.Body, idStr)
+			}
+		})
+	}
+}
+
+func TestGetSongURLIDOverflow(t *testing.T) {
+	useTestConfig(t, nil)
+	w := serve(NewSongURLService(netease.NewFake()).GetSongURL, http.MethodGet, "/song?id=9223372036854775808")
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+	if resp := decodeBody[api.ErrorResponse](t, w); resp.Message != "Invalid song id format" {
+		t.Errorf("message = %q, want %q", resp.Message, "Invalid song id format")
+	}
+}
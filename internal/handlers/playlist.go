@@ -0,0 +1,277 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"PMS/internal/config"
+	"PMS/internal/netease"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 歌单曲目分页参数
+const (
+	playlistDefaultLimit = 100
+	playlistMaxLimit     = 1000
+)
+
+// 按 trackIds 补全曲目时单次 /song/detail 请求的歌曲数量
+const playlistDetailBatch = 500
+
+// TrackItem 歌单、专辑等列表中的曲目，不包含播放地址
+type TrackItem struct {
+	ID       int64    `json:"id"`
+	Name     string   `json:"name"`
+	Artists  []Artist `json:"artists"`
+	Album    Album    `json:"album"`
+	Duration int      `json:"duration"`
+	Position int      `json:"position,omitempty"`
+	Disc     int      `json:"disc,omitempty"`
+
+	// 仅在 /playlist?resolve=true 时填充
+	Playback *TrackPlayback `json:"playback,omitempty"`
+}
+
+// TrackPlayback 曲目的播放地址，解析失败时仅包含 error 字段
+type TrackPlayback struct {
+	URL   string `json:"url,omitempty"`
+	Br    int    `json:"br,omitempty"`
+	Size  int    `json:"size,omitempty"`
+	Type  string `json:"type,omitempty"`
+	Level string `json:"level,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+type PlaylistCreator struct {
+	ID        int64  `json:"id"`
+	Nickname  string `json:"nickname"`
+	AvatarURL string `json:"avatarUrl"`
+}
+
+type PlaylistResponse struct {
+	Code int `json:"code"`
+	// 上游返回的错误说明，只用于错误响应
+	Message     string          `json:"-"`
+	ID          int64           `json:"id"`
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	CoverURL    string          `json:"coverUrl"`
+	Creator     PlaylistCreator `json:"creator"`
+	TrackCount  int             `json:"trackCount"`
+	Offset      int             `json:"offset"`
+	Limit       int             `json:"limit"`
+	Tracks      []TrackItem     `json:"tracks"`
+}
+
+// cachedPlaylist 缓存的歌单：Tracks 只包含上游随歌单返回的曲目，TrackIDs 为完整的曲目ID列表
+type cachedPlaylist struct {
+	PlaylistResponse
+	TrackIDs []int64 `json:"trackIds"`
+}
+
+func toTrackItem(s netease.Song) TrackItem {
+	return TrackItem{
+		ID:       s.ID,
+		Name:     s.Name,
+		Artists:  toArtists(s.Ar),
+		Album:    Album{ID: s.Al.ID, Name: s.Al.Name, PicURL: s.Al.PicURL},
+		Duration: s.Dt,
+	}
+}
+
+// playlistCacheKey 生成歌单的缓存键，私人歌单只有租户自己的账号可见，因此按租户区分
+func playlistCacheKey(ctx context.Context, playlistID int64) string {
+	return fmt.Sprintf("pms:playlist:%d", playlistID) + tenantKeySuffix(ctx)
+}
+
+// getPlaylistCached 返回歌单信息、上游随歌单返回的曲目与完整的曲目ID列表，由 playlistPage 分页
+func (s *CatalogService) getPlaylistCached(ctx context.Context, playlistID int64, realIP string, nocache bool) (*cachedPlaylist, error) {
+	key := playlistCacheKey(ctx, playlistID)
+
+	if responseCache != nil && !nocache {
+		var playlist cachedPlaylist
+		if cacheGetJSON(ctx, key, &playlist) {
+			return &playlist, nil
+		}
+	}
+
+	playlistResp, err := s.client.Playlist(ctx, playlistID, realIP)
+	if err != nil {
+		return nil, err
+	}
+	if playlistResp.Code != 200 {
+		return &cachedPlaylist{PlaylistResponse: PlaylistResponse{Code: playlistResp.Code, Message: playlistResp.Message}}, nil
+	}
+
+	p := playlistResp.Playlist
+	playlist := &cachedPlaylist{PlaylistResponse: PlaylistResponse{
+		Code:        200,
+		ID:          p.ID,
+		Name:        p.Name,
+		Description: p.Description,
+		CoverURL:    p.CoverImgURL,
+		Creator: PlaylistCreator{
+			ID:        p.Creator.UserID,
+			Nickname:  p.Creator.Nickname,
+			AvatarURL: p.Creator.AvatarURL,
+		},
+		TrackCount: p.TrackCount,
+		Tracks:     make([]TrackItem, 0, len(p.Tracks)),
+	}}
+	for _, track := range p.Tracks {
+		playlist.Tracks = append(playlist.Tracks, toTrackItem(track))
+	}
+	for _, track := range p.TrackIDs {
+		playlist.TrackIDs = append(playlist.TrackIDs, track.ID)
+	}
+
+	if responseCache != nil {
+		cacheSetJSON(ctx, key, playlist, config.Current().PlaylistCacheTTL)
+	}
+	return playlist, nil
+}
+
+// GetPlaylist 处理 GET /playlist?id=，返回歌单信息与分页后的曲目列表
+func (s *SongURLService) GetPlaylist(c *gin.Context) {
+	playlistID, ok := parseNumericID(c, c.Query("id"), "playlist")
+	if !ok {
+		return
+	}
+
+	limit, offset, ok := parsePagination(c, playlistDefaultLimit, playlistMaxLimit)
+	if !ok {
+		return
+	}
+
+	level := c.DefaultQuery("level", defaultLevel(c))
+	if !checkLevel(c, level) {
+		return
+	}
+	realIP := c.DefaultQuery("realip", defaultRealIP(c))
+	nocache := c.Query("nocache") == "1"
+	fallback := c.Query("fallback") != "false"
+	resolve := c.Query("resolve") == "true"
+
+	cached, err := s.catalog.getPlaylistCached(c.Request.Context(), playlistID, realIP, nocache)
+	if err != nil {
+		respondUpstreamError(c, err)
+		return
+	}
+
+	// 检查网易云音乐API返回的状态码
+	if cached.Code != 200 {
+		respondUpstreamCode(c, cached.Code, cached.Message)
+		return
+	}
+
+	tracks, status, err := s.catalog.playlistPage(c.Request.Context(), cached, offset, limit, realIP, nocache)
+	if err != nil {
+		respondUpstreamError(c, err)
+		return
+	}
+	if status.Code != 200 {
+		respondUpstreamCode(c, status.Code, status.Message)
+		return
+	}
+
+	playlist := &cached.PlaylistResponse
+	playlist.Offset = offset
+	playlist.Limit = limit
+	playlist.Tracks = tracks
+
+	if resolve {
+		s.resolvePlaylistTracks(c.Request.Context(), playlist.Tracks, level, realIP, nocache, fallback)
+		if clientGone(c) {
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, playlist)
+}
+
+// playlistPage 返回歌单中 [offset, offset+limit) 的曲目。上游随歌单返回的曲目直接使用，
+// 超出截断位置的曲目按 trackIds 通过 /song/detail 查询，查询不到的曲目只包含ID；
+// 上游未返回 trackIds 时只能对已返回的曲目分页
+func (s *CatalogService) playlistPage(ctx context.Context, playlist *cachedPlaylist, offset, limit int, realIP string, nocache bool) ([]TrackItem, upstreamStatus, error) {
+	if len(playlist.TrackIDs) == 0 {
+		return paginate(playlist.Tracks, offset, limit), upstreamStatus{Code: 200}, nil
+	}
+
+	ids := paginate(playlist.TrackIDs, offset, limit)
+	tracks := make([]TrackItem, len(ids))
+	var missing []int64
+	for i, id := range ids {
+		if k := offset + i; k < len(playlist.Tracks) && playlist.Tracks[k].ID == id {
+			tracks[i] = playlist.Tracks[k]
+		} else {
+			missing = append(missing, id)
+		}
+	}
+
+	details := make(map[int64]SongDetail, len(missing))
+	for chunk := range slices.Chunk(missing, playlistDetailBatch) {
+		found, status, err := s.getSongDetailsCached(ctx, chunk, realIP, nocache)
+		if err != nil || status.Code != 200 {
+			return nil, status, err
+		}
+		maps.Copy(details, found)
+	}
+	for i, id := range ids {
+		if tracks[i].ID != 0 {
+			continue
+		}
+		tracks[i] = TrackItem{ID: id, Artists: []Artist{}}
+		if detail, ok := details[id]; ok {
+			tracks[i] = TrackItem{ID: id, Name: detail.Name, Artists: detail.Artists, Album: detail.Album, Duration: detail.Duration}
+		}
+	}
+	return tracks, upstreamStatus{Code: 200}, nil
+}
+
+// resolvePlaylistTracks 并发解析曲目的播放地址，整体耗时受 PLAYLIST_RESOLVE_TIMEOUT 限制，
+// 单首失败（如需要VIP）只记录在该曲目上
+func (s *SongURLService) resolvePlaylistTracks(ctx context.Context, tracks []TrackItem, level, realIP string, nocache, fallback bool) {
+	ctx, cancel := context.WithTimeout(ctx, config.Current().PlaylistResolveTimeout)
+	defer cancel()
+
+	ids := make([]string, len(tracks))
+	for i, track := range tracks {
+		ids[i] = strconv.FormatInt(track.ID, 10)
+	}
+	results := s.resolveMany(ctx, dedupeIDs(ids), level, realIP, nocache, fallback)
+
+	for i := range tracks {
+		item := results.items[ids[i]]
+		playback := &TrackPlayback{Error: item.Error}
+		switch {
+		case item.SongURLResponse == nil:
+		case !hasPlayableURL(item.SongURLResponse):
+			playback.Error = "No playable URL available for this song"
+		default:
+			data := item.Data[0]
+			playback.URL = data.URL
+			playback.Br = data.Br
+			playback.Size = data.Size
+			playback.Type = data.Type
+			playback.Level = item.ServedLevel
+		}
+		tracks[i].Playback = playback
+	}
+}
+
+// paginate 返回 items 中 [offset, offset+limit) 范围内的元素
+func paginate[T any](items []T, offset, limit int) []T {
+	if offset >= len(items) {
+		return []T{}
+	}
+	end := offset + limit
+	if end > len(items) {
+		end = len(items)
+	}
+	return items[offset:end]
+}
@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"PMS/internal/netease"
+)
+
+// truncatedPlaylist 返回包含 1..total 共 total 首曲目的歌单，与上游一样只随歌单返回前 returned 首
+func truncatedPlaylist(total, returned int) *netease.PlaylistResponse {
+	resp := &netease.PlaylistResponse{Code: 200}
+	resp.Playlist.ID = 1
+	resp.Playlist.TrackCount = total
+	for id := int64(1); id <= int64(total); id++ {
+		resp.Playlist.TrackIDs = append(resp.Playlist.TrackIDs, netease.TrackID{ID: id})
+		if id <= int64(returned) {
+			resp.Playlist.Tracks = append(resp.Playlist.Tracks, testSong(id))
+		}
+	}
+	return resp
+}
+
+func testSong(id int64) netease.Song {
+	return netease.Song{ID: id, Name: fmt.Sprintf("song %d", id), Ar: []netease.Artist{{ID: 1, Name: "artist"}}}
+}
+
+func TestGetPlaylistPagesOverTrackIDs(t *testing.T) {
+	tests := []struct {
+		name            string
+		target          string
+		wantFirst       int64
+		wantLen         int
+		wantDetailCalls int
+	}{
+		{name: "within returned tracks", target: "/playlist?id=1&limit=5", wantFirst: 1, wantLen: 5},
+		{name: "across the truncation", target: "/playlist?id=1&offset=5&limit=10", wantFirst: 6, wantLen: 10, wantDetailCalls: 1},
+		{name: "chunked song detail", target: "/playlist?id=1&limit=1000", wantFirst: 1, wantLen: 1000, wantDetailCalls: 2},
+		{name: "last page", target: "/playlist?id=1&offset=1195&limit=10", wantFirst: 1196, wantLen: 5, wantDetailCalls: 1},
+		{name: "past the end", target: "/playlist?id=1&offset=5000", wantLen: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useTestConfig(t, nil)
+			useResponseCache(t, nil)
+			fake := netease.NewFake()
+			fake.SetPlaylist(1, truncatedPlaylist(1200, 10))
+			// 最后一首歌曲查询不到详情
+			for id := int64(1); id < 1200; id++ {
+				fake.SetSong(testSong(id))
+			}
+
+			w := serve(NewSongURLService(fake).GetPlaylist, http.MethodGet, tt.target)
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d; body %s", w.Code, w.Body)
+			}
+			resp := decodeBody[PlaylistResponse](t, w)
+			if len(resp.Tracks) != tt.wantLen {
+				t.Fatalf("tracks = %d, want %d", len(resp.Tracks), tt.wantLen)
+			}
+			for i, track := range resp.Tracks {
+				if want := tt.wantFirst + int64(i); track.ID != want {
+					t.Fatalf("tracks[%d].id = %d, want %d", i, track.ID, want)
+				}
+				if track.ID < 1200 && track.Name != fmt.Sprintf("song %d", track.ID) {
+					t.Errorf("tracks[%d] = %+v, want its song detail", i, track)
+				}
+			}
+			if resp.TrackCount != 1200 {
+				t.Errorf("trackCount = %d, want 1200", resp.TrackCount)
+			}
+			if got := fake.MethodCalls("SongDetail"); got != tt.wantDetailCalls {
+				t.Errorf("song detail calls = %d, want %d", got, tt.wantDetailCalls)
+			}
+		})
+	}
+}
+
+func TestGetPlaylistTrackWithoutDetail(t *testing.T) {
+	useTestConfig(t, nil)
+	useResponseCache(t, nil)
+	fake := netease.NewFake()
+	fake.SetPlaylist(1, truncatedPlaylist(3, 1))
+	fake.SetSong(testSong(2))
+
+	w := serve(NewSongURLService(fake).GetPlaylist, http.MethodGet, "/playlist?id=1")
+	resp := decodeBody[PlaylistResponse](t, w)
+	if len(resp.Tracks) != 3 {
+		t.Fatalf("tracks = %+v, want 3", resp.Tracks)
+	}
+	if last := resp.Tracks[2]; last.ID != 3 || last.Name != "" || last.Artists == nil {
+		t.Errorf("track without detail = %+v, want only its id and empty artists", last)
+	}
+}
+
+func TestGetPlaylistWithoutTrackIDs(t *testing.T) {
+	useTestConfig(t, nil)
+	useResponseCache(t, nil)
+	fake := netease.NewFake()
+	playlist := truncatedPlaylist(4, 4)
+	playlist.Playlist.TrackIDs = nil
+	fake.SetPlaylist(1, playlist)
+
+	w := serve(NewSongURLService(fake).GetPlaylist, http.MethodGet, "/playlist?id=1&offset=1&limit=2")
+	resp := decodeBody[PlaylistResponse](t, w)
+	if len(resp.Tracks) != 2 || resp.Tracks[0].ID != 2 || resp.Tracks[1].ID != 3 {
+		t.Errorf("tracks = %+v, want songs 2 and 3", resp.Tracks)
+	}
+	if got := fake.MethodCalls("SongDetail"); got != 0 {
+		t.Errorf("song detail calls = %d, want 0", got)
+	}
+}
+
+func TestGetPlaylistSongDetailError(t *testing.T) {
+	useTestConfig(t, nil)
+	useResponseCache(t, nil)
+	fake := netease.NewFake()
+	fake.SetPlaylist(1, truncatedPlaylist(20, 10))
+	fake.SetMethodError("SongDetail", netease.ErrTimeout)
+
+	if w := serve(NewSongURLService(fake).GetPlaylist, http.MethodGet, "/playlist?id=1&limit=5"); w.Code != http.StatusOK {
+		t.Errorf("page within returned tracks: status = %d, want %d", w.Code, http.StatusOK)
+	}
+	if w := serve(NewSongURLService(fake).GetPlaylist, http.MethodGet, "/playlist?id=1&offset=5&limit=10"); w.Code != http.StatusGatewayTimeout {
+		t.Errorf("page needing song detail: status = %d, want %d", w.Code, http.StatusGatewayTimeout)
+	}
+}
@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"PMS/internal/config"
+)
+
+// upstreamProxyURL 解析 UPSTREAM_PROXY，未配置时返回 nil；地址已在加载配置时校验
+func upstreamProxyURL() *url.URL {
+	proxy := config.Current().UpstreamProxy
+	if proxy == "" {
+		return nil
+	}
+	u, err := url.Parse(proxy)
+	if err != nil {
+		return nil
+	}
+	return u
+}
+
+// UpstreamProxy 返回访问上游实际使用的代理（隐藏密码），用于启动日志；
+// 未配置 UPSTREAM_PROXY 时按环境变量为主上游实例选取，不使用代理时为空
+func (t *UpstreamTransport) UpstreamProxy() (proxy, source string) {
+	if u := upstreamProxyURL(); u != nil {
+		return u.Redacted(), "UPSTREAM_PROXY"
+	}
+	if len(t.targets) == 0 {
+		return "", ""
+	}
+	target, err := url.Parse(t.targets[0].base)
+	if err != nil {
+		return "", ""
+	}
+	u, err := http.ProxyFromEnvironment(&http.Request{URL: target})
+	if err != nil || u == nil {
+		return "", ""
+	}
+	return u.Redacted(), "environment"
+}
+
+// isProxyError 判断请求错误是否发生在连接代理阶段（HTTP代理的 CONNECT 或 SOCKS5 握手）
+func isProxyError(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && (opErr.Op == "proxyconnect" || strings.HasPrefix(opErr.Op, "socks"))
+}
@@ -0,0 +1,211 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"PMS/internal/api"
+	"PMS/internal/config"
+	"PMS/internal/logging"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+)
+
+// 计数按UTC日期区分
+const quotaDayLayout = "2006-01-02"
+
+// quotaCounter 进程内的租户每日上游请求计数，跨过UTC零点后清零；
+// 使用Redis缓存时只在Redis不可用期间使用
+type quotaCounter struct {
+	mu     sync.Mutex
+	day    string
+	counts map[string]int64
+}
+
+var quotas = &quotaCounter{counts: make(map[string]int64)}
+
+// quotaState QUOTA_STATE_FILE 的内容
+type quotaState struct {
+	Day   string           `json:"day"`
+	Usage map[string]int64 `json:"usage"`
+}
+
+// rollover 跨过UTC零点时清空计数，调用方需持有 mu
+func (q *quotaCounter) rollover(now time.Time) {
+	if day := now.UTC().Format(quotaDayLayout); day != q.day {
+		q.day = day
+		clear(q.counts)
+	}
+}
+
+func (q *quotaCounter) incr(tenant string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.rollover(time.Now())
+	q.counts[tenant]++
+}
+
+func (q *quotaCounter) get(tenant string) int64 {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.rollover(time.Now())
+	return q.counts[tenant]
+}
+
+func (q *quotaCounter) snapshot() quotaState {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.rollover(time.Now())
+	usage := make(map[string]int64, len(q.counts))
+	for tenant, n := range q.counts {
+		usage[tenant] = n
+	}
+	return quotaState{Day: q.day, Usage: usage}
+}
+
+// restore 载入检查点中当天的计数，其他日期的检查点已过期，忽略
+func (q *quotaCounter) restore(state quotaState) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.rollover(time.Now())
+	if state.Day != q.day {
+		return false
+	}
+	for tenant, n := range state.Usage {
+		q.counts[tenant] = n
+	}
+	return true
+}
+
+// QuotaReset 返回额度下次重置的时间，即下一个UTC零点
+func QuotaReset(now time.Time) time.Time {
+	return now.UTC().Truncate(24 * time.Hour).Add(24 * time.Hour)
+}
+
+// quotaRedisKey 不使用缓存的 pms: 前缀，清空缓存时计数不受影响
+func quotaRedisKey(tenant string, now time.Time) string {
+	return fmt.Sprintf("pms-quota:%s:%s", now.UTC().Format(quotaDayLayout), tenant)
+}
+
+// QuotaUsage 返回租户当天已使用的上游请求数；读取Redis失败时使用进程内的计数
+func QuotaUsage(ctx context.Context, tenant string) int64 {
+	if rc, ok := responseCache.(*redisCache); ok && !rc.usingFallback() {
+		redisCtx, cancel := context.WithTimeout(ctx, rc.timeout)
+		defer cancel()
+		n, err := rc.client.Get(redisCtx, quotaRedisKey(tenant, time.Now())).Int64()
+		switch {
+		case err == nil:
+			return n
+		case errors.Is(err, redis.Nil):
+			return 0
+		}
+		logging.From(ctx).Warn("error reading quota usage from redis", "tenant", tenant, "error", err)
+		rc.markDown(ctx, err)
+	}
+	return quotas.get(tenant)
+}
+
+// checkBatchQuota 批量请求的每个ID都可能请求一次上游，ID数超过租户当天的剩余额度时直接返回429，
+// 避免一个请求通过额度检查后耗用远超剩余额度的上游请求
+func checkBatchQuota(c *gin.Context, n int) bool {
+	ctx := c.Request.Context()
+	tenant, ok := config.TenantFrom(ctx)
+	if !ok || tenant.DailyQuota <= 0 {
+		return true
+	}
+	remaining := max(tenant.DailyQuota-QuotaUsage(ctx, tenant.Name), 0)
+	if int64(n) <= remaining {
+		return true
+	}
+	c.JSON(http.StatusTooManyRequests, api.NewErrorResponse(c, 429, fmt.Sprintf("Batch of %d ids exceeds the remaining daily quota of %d", n, remaining)))
+	return false
+}
+
+// recordQuotaUsage 上游请求成功后为请求所属的租户计数，未设置 daily_quota 的租户不计数
+func recordQuotaUsage(ctx context.Context) {
+	tenant, ok := config.TenantFrom(ctx)
+	if !ok || tenant.DailyQuota <= 0 {
+		return
+	}
+	if rc, ok := responseCache.(*redisCache); ok && !rc.usingFallback() {
+		// 上游已成功应答，客户端随后断开也要计数
+		ctx = context.WithoutCancel(ctx)
+		now := time.Now()
+		key := quotaRedisKey(tenant.Name, now)
+		redisCtx, cancel := context.WithTimeout(ctx, rc.timeout)
+		defer cancel()
+		pipe := rc.client.TxPipeline()
+		pipe.Incr(redisCtx, key)
+		// 计数保留到重置后一小时，避免各实例时钟略有偏差时提前过期
+		pipe.ExpireAt(redisCtx, key, QuotaReset(now).Add(time.Hour))
+		_, err := pipe.Exec(redisCtx)
+		if err == nil {
+			return
+		}
+		logging.From(ctx).Warn("error recording quota usage in redis", "tenant", tenant.Name, "error", err)
+		rc.markDown(ctx, err)
+	}
+	quotas.incr(tenant.Name)
+}
+
+// LoadQuotaState 启动时从 QUOTA_STATE_FILE 恢复当天的计数，文件不存在时从零开始
+func LoadQuotaState(path string) {
+	if path == "" {
+		return
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return
+	}
+	var state quotaState
+	if err == nil {
+		err = json.Unmarshal(data, &state)
+	}
+	if err != nil {
+		logging.Logger.Warn("failed to load quota state, starting from zero", "path", path, "error", err)
+		return
+	}
+	if quotas.restore(state) {
+		logging.Logger.Info("quota state restored", "path", path, "day", state.Day, "tenants", len(state.Usage))
+	}
+}
+
+// SaveQuotaState 将进程内当天的计数写入 QUOTA_STATE_FILE
+func SaveQuotaState(path string) error {
+	if path == "" {
+		return nil
+	}
+	data, err := json.Marshal(quotas.snapshot())
+	if err != nil {
+		return err
+	}
+	// 先写入临时文件再重命名，避免写入中途失败留下不完整的检查点
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// RunQuotaCheckpoints 每隔 interval 将计数写入 QUOTA_STATE_FILE，停机时由调用方再写入一次
+func RunQuotaCheckpoints(ctx context.Context, path string, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if err := SaveQuotaState(path); err != nil {
+			logging.Logger.Warn("failed to save quota state", "path", path, "error", err)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"PMS/internal/config"
+	"PMS/internal/netease"
+
+	"github.com/gin-gonic/gin"
+)
+
+// useQuotas 使用空的进程内额度计数，测试结束后恢复
+func useQuotas(t *testing.T) {
+	t.Helper()
+	prev := quotas
+	quotas = &quotaCounter{counts: make(map[string]int64)}
+	t.Cleanup(func() { quotas = prev })
+}
+
+func TestQuotaCounterRollsOverAtUTCMidnight(t *testing.T) {
+	q := &quotaCounter{counts: make(map[string]int64)}
+	q.rollover(time.Date(2026, 1, 1, 23, 59, 0, 0, time.UTC))
+	q.counts["acme"] = 10
+
+	q.rollover(time.Date(2026, 1, 1, 23, 59, 59, 0, time.UTC))
+	if q.counts["acme"] != 10 {
+		t.Errorf("count = %d before midnight, want 10", q.counts["acme"])
+	}
+	q.rollover(time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC))
+	if q.counts["acme"] != 0 {
+		t.Errorf("count = %d after midnight, want 0", q.counts["acme"])
+	}
+}
+
+func TestQuotaReset(t *testing.T) {
+	// UTC+8 的上午对应UTC前一天的晚上，重置时间为该UTC日期的下一个零点
+	now := time.Date(2026, 3, 10, 6, 0, 0, 0, time.FixedZone("CST", 8*3600))
+	if got, want := QuotaReset(now), time.Date(2026, 3, 10, 0, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("QuotaReset = %s, want %s", got, want)
+	}
+}
+
+func TestUpstreamRecordsQuotaUsage(t *testing.T) {
+	var fail atomic.Bool
+	transport := newTestUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		if fail.Load() {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		writeJSON(w, map[string]int{"code": 200})
+	}, map[string]string{"UPSTREAM_MAX_RETRIES": "0", "CB_FAILURE_THRESHOLD": "100"})
+	useResponseCache(t, nil)
+	useQuotas(t)
+
+	get := func(ctx context.Context) {
+		transport.Get(ctx, netease.URL("/song/url/v1", url.Values{}, "", ""))
+	}
+	tenantCtx := config.WithTenant(context.Background(), config.TenantConfig{Name: "acme", DailyQuota: 10})
+	get(tenantCtx)
+	get(tenantCtx)
+	get(config.WithTenant(context.Background(), config.TenantConfig{Name: "free"}))
+	get(context.Background())
+	fail.Store(true)
+	get(tenantCtx)
+
+	if got := QuotaUsage(context.Background(), "acme"); got != 2 {
+		t.Errorf("acme usage = %d, want only successful upstream requests counted", got)
+	}
+	if got := QuotaUsage(context.Background(), "free"); got != 0 {
+		t.Errorf("usage = %d for a tenant without daily_quota, want 0", got)
+	}
+}
+
+func TestBatchRejectsIDsOverRemainingQuota(t *testing.T) {
+	useTestConfig(t, nil)
+	useResponseCache(t, nil)
+	useQuotas(t)
+	fake := netease.NewFake()
+	for id := int64(1); id <= 3; id++ {
+		fake.SetSongURL(id, "standard", playableSong(id))
+	}
+	s := NewSongURLService(fake)
+	tenant := config.TenantConfig{Name: "acme", DailyQuota: 5}
+	quotas.counts["acme"] = 3
+	quotas.day = time.Now().UTC().Format(quotaDayLayout)
+
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Request = c.Request.WithContext(config.WithTenant(c.Request.Context(), tenant))
+	})
+	r.GET("/songs", s.BatchGetSongURLsByQuery)
+	get := func(target string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+		return w
+	}
+
+	if w := get("/songs?id=1,2,3"); w.Code != http.StatusTooManyRequests {
+		t.Errorf("3 ids with 2 remaining: status = %d, want %d", w.Code, http.StatusTooManyRequests)
+	}
+	if got := fake.Calls(1, "standard"); got != 0 {
+		t.Errorf("upstream calls = %d, want the rejected batch not to reach upstream", got)
+	}
+	// 重复ID去重后计数
+	if w := get("/songs?id=1,2,2,1"); w.Code != http.StatusOK {
+		t.Errorf("2 distinct ids with 2 remaining: status = %d, want %d; body %s", w.Code, http.StatusOK, w.Body)
+	}
+}
+
+func TestQuotaStateRoundTrip(t *testing.T) {
+	useQuotas(t)
+	path := filepath.Join(t.TempDir(), "quota.json")
+	quotas.incr("acme")
+	quotas.incr("acme")
+	if err := SaveQuotaState(path); err != nil {
+		t.Fatalf("SaveQuotaState: %v", err)
+	}
+
+	quotas = &quotaCounter{counts: make(map[string]int64)}
+	LoadQuotaState(path)
+	if got := quotas.get("acme"); got != 2 {
+		t.Errorf("restored usage = %d, want 2", got)
+	}
+
+	// 其他日期的检查点已过期，不恢复
+	if quotas.restore(quotaState{Day: "2000-01-01", Usage: map[string]int64{"acme": 50}}) {
+		t.Error("restore accepted a checkpoint from another day")
+	}
+	if got := quotas.get("acme"); got != 2 {
+		t.Errorf("usage = %d after a stale checkpoint, want 2", got)
+	}
+}
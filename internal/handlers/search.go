@@ -0,0 +1,184 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"PMS/internal/api"
+	"PMS/internal/netease"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 搜索分页参数
+const (
+	searchDefaultLimit = 30
+	searchMaxLimit     = 100
+)
+
+// 支持的搜索类型，与上游 cloudsearch 的 type 参数一致
+const (
+	searchTypeSong     = 1
+	searchTypeAlbum    = 10
+	searchTypeArtist   = 100
+	searchTypePlaylist = 1000
+)
+
+type Artist struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+}
+
+type Album struct {
+	ID     int64  `json:"id"`
+	Name   string `json:"name"`
+	PicURL string `json:"picUrl,omitempty"`
+}
+
+type SearchSong struct {
+	ID       int64    `json:"id"`
+	Name     string   `json:"name"`
+	Artists  []Artist `json:"artists"`
+	Album    Album    `json:"album"`
+	Duration int      `json:"duration"`
+}
+
+type SearchArtist struct {
+	ID       int64  `json:"id"`
+	Name     string `json:"name"`
+	CoverURL string `json:"coverUrl"`
+}
+
+type SearchPlaylist struct {
+	ID         int64           `json:"id"`
+	Name       string          `json:"name"`
+	CoverURL   string          `json:"coverUrl"`
+	TrackCount int             `json:"trackCount"`
+	Creator    PlaylistCreator `json:"creator"`
+}
+
+// SearchResponse 归一化后的搜索结果，不包含上游内部字段；
+// 仅返回与搜索类型对应的列表，结果为空时为空数组
+type SearchResponse struct {
+	Code      int               `json:"code"`
+	Type      int               `json:"type"`
+	Total     int               `json:"total"`
+	Limit     int               `json:"limit"`
+	Offset    int               `json:"offset"`
+	Songs     *[]SearchSong     `json:"songs,omitempty"`
+	Albums    *[]AlbumSummary   `json:"albums,omitempty"`
+	Artists   *[]SearchArtist   `json:"artists,omitempty"`
+	Playlists *[]SearchPlaylist `json:"playlists,omitempty"`
+}
+
+// SearchSongs 处理 GET /search?keywords=&type=&limit=&offset=，q 为 keywords 的别名
+func (s *CatalogService) SearchSongs(c *gin.Context) {
+	keywords := c.Query("keywords")
+	if keywords == "" {
+		keywords = c.Query("q")
+	}
+	if keywords == "" {
+		c.JSON(http.StatusBadRequest, api.NewErrorResponse(c, 400, "Missing required parameter: keywords"))
+		return
+	}
+
+	searchType, err := strconv.Atoi(c.DefaultQuery("type", strconv.Itoa(searchTypeSong)))
+	if err != nil || (searchType != searchTypeSong && searchType != searchTypeAlbum &&
+		searchType != searchTypeArtist && searchType != searchTypePlaylist) {
+		c.JSON(http.StatusBadRequest, api.NewErrorResponse(c, 400, fmt.Sprintf("Invalid type, allowed values: %d (songs), %d (albums), %d (artists), %d (playlists)", searchTypeSong, searchTypeAlbum, searchTypeArtist, searchTypePlaylist)))
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(searchDefaultLimit)))
+	if err != nil || limit < 1 || limit > searchMaxLimit {
+		c.JSON(http.StatusBadRequest, api.NewErrorResponse(c, 400, fmt.Sprintf("limit must be between 1 and %d", searchMaxLimit)))
+		return
+	}
+
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		c.JSON(http.StatusBadRequest, api.NewErrorResponse(c, 400, "Invalid offset"))
+		return
+	}
+
+	realIP := c.DefaultQuery("realip", defaultRealIP(c))
+
+	searchResp, err := s.client.Search(c.Request.Context(), keywords, searchType, limit, offset, realIP)
+	if err != nil {
+		respondUpstreamError(c, err)
+		return
+	}
+
+	// 检查网易云音乐API返回的状态码
+	if searchResp.Code != 200 {
+		respondUpstreamCode(c, searchResp.Code, searchResp.Message)
+		return
+	}
+
+	result := SearchResponse{
+		Code:   200,
+		Type:   searchType,
+		Limit:  limit,
+		Offset: offset,
+	}
+
+	r := searchResp.Result
+	switch searchType {
+	case searchTypeSong:
+		songs := make([]SearchSong, 0, len(r.Songs))
+		for _, song := range r.Songs {
+			songs = append(songs, SearchSong{
+				ID:       song.ID,
+				Name:     song.Name,
+				Artists:  toArtists(song.Ar),
+				Album:    Album{ID: song.Al.ID, Name: song.Al.Name, PicURL: song.Al.PicURL},
+				Duration: song.Dt,
+			})
+		}
+		result.Total = r.SongCount
+		result.Songs = &songs
+	case searchTypeAlbum:
+		albums := make([]AlbumSummary, 0, len(r.Albums))
+		for _, al := range r.Albums {
+			albums = append(albums, toAlbumSummary(al))
+		}
+		result.Total = r.AlbumCount
+		result.Albums = &albums
+	case searchTypeArtist:
+		artists := make([]SearchArtist, 0, len(r.Artists))
+		for _, ar := range r.Artists {
+			artists = append(artists, SearchArtist{ID: ar.ID, Name: ar.Name, CoverURL: ar.PicURL})
+		}
+		result.Total = r.ArtistCount
+		result.Artists = &artists
+	case searchTypePlaylist:
+		playlists := make([]SearchPlaylist, 0, len(r.Playlists))
+		for _, p := range r.Playlists {
+			playlists = append(playlists, SearchPlaylist{
+				ID:         p.ID,
+				Name:       p.Name,
+				CoverURL:   p.CoverImgURL,
+				TrackCount: p.TrackCount,
+				Creator: PlaylistCreator{
+					ID:        p.Creator.UserID,
+					Nickname:  p.Creator.Nickname,
+					AvatarURL: p.Creator.AvatarURL,
+				},
+			})
+		}
+		result.Total = r.PlaylistCount
+		result.Playlists = &playlists
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// toArtists 将上游歌手列表转换为对外的 Artist 类型
+func toArtists(artists []netease.Artist) []Artist {
+	result := make([]Artist, 0, len(artists))
+	for _, ar := range artists {
+		result = append(result, Artist{ID: ar.ID, Name: ar.Name})
+	}
+	return result
+}
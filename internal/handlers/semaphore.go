@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"PMS/internal/metrics"
+)
+
+// errUpstreamBusy 同时进行的上游请求已达 UPSTREAM_MAX_CONCURRENT，且等待超过 UPSTREAM_CONCURRENCY_TIMEOUT_MS
+var errUpstreamBusy = errors.New("too many concurrent upstream requests")
+
+// acquireSlot 取得一个上游并发名额，名额已满时最多等待 wait；
+// 返回的函数用于释放名额。ctx 先结束时返回 ctx 对应的上游错误
+func (t *UpstreamTransport) acquireSlot(ctx context.Context, wait time.Duration) (func(), error) {
+	if t.slots == nil {
+		return func() {}, nil
+	}
+
+	release := func() {
+		<-t.slots
+		metrics.AddUpstreamConcurrent(-1)
+	}
+	select {
+	case t.slots <- struct{}{}:
+		metrics.AddUpstreamConcurrent(1)
+		metrics.ObserveUpstreamConcurrencyWait(0)
+		return release, nil
+	default:
+	}
+
+	start := time.Now()
+	metrics.AddUpstreamConcurrencyWaiting(1)
+	defer metrics.AddUpstreamConcurrencyWaiting(-1)
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case t.slots <- struct{}{}:
+		metrics.AddUpstreamConcurrent(1)
+		metrics.ObserveUpstreamConcurrencyWait(time.Since(start))
+		return release, nil
+	case <-timer.C:
+		metrics.ObserveUpstreamConcurrencyWait(time.Since(start))
+		return nil, errUpstreamBusy
+	case <-ctx.Done():
+		return nil, upstreamContextError(ctx)
+	}
+}
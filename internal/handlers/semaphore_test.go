@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"PMS/internal/netease"
+)
+
+func TestUpstreamConcurrencyLimit(t *testing.T) {
+	var active, peak atomic.Int32
+	release := make(chan struct{})
+	transport := newTestUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		n := active.Add(1)
+		defer active.Add(-1)
+		for {
+			if p := peak.Load(); n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		<-release
+		writeJSON(w, map[string]int{"code": 200})
+	}, map[string]string{
+		"UPSTREAM_MAX_CONCURRENT":         "2",
+		"UPSTREAM_CONCURRENCY_TIMEOUT_MS": "50",
+		"UPSTREAM_MAX_RETRIES":            "0",
+	})
+
+	errs := make(chan error, 3)
+	for range 3 {
+		go func() {
+			_, err := transport.Get(context.Background(), netease.URL("/song/url/v1", url.Values{}, "", ""))
+			errs <- err
+		}()
+	}
+
+	// 第三个请求等待 UPSTREAM_CONCURRENCY_TIMEOUT_MS 后放弃
+	select {
+	case err := <-errs:
+		if !errors.Is(err, errUpstreamBusy) {
+			t.Errorf("first finished request error = %v, want %v", err, errUpstreamBusy)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("request over the concurrency limit did not give up waiting")
+	}
+	close(release)
+	for range 2 {
+		if err := <-errs; err != nil {
+			t.Errorf("request within the limit: %v", err)
+		}
+	}
+	if got := peak.Load(); got != 2 {
+		t.Errorf("peak concurrent upstream requests = %d, want 2", got)
+	}
+	if got := upstreamErrorStatus(errUpstreamBusy); got != http.StatusTooManyRequests {
+		t.Errorf("status for a busy upstream = %d, want %d", got, http.StatusTooManyRequests)
+	}
+}
+
+func TestUpstreamConcurrencyWaitsForFreeSlot(t *testing.T) {
+	useTestConfig(t, nil)
+	transport := &UpstreamTransport{slots: make(chan struct{}, 1)}
+
+	first, err := transport.acquireSlot(context.Background(), time.Second)
+	if err != nil {
+		t.Fatalf("acquireSlot: %v", err)
+	}
+	time.AfterFunc(20*time.Millisecond, first)
+	second, err := transport.acquireSlot(context.Background(), time.Second)
+	if err != nil {
+		t.Fatalf("acquireSlot after a slot was released: %v", err)
+	}
+	second()
+
+	// 等待期间请求被取消时返回对应的上游错误
+	first, _ = transport.acquireSlot(context.Background(), time.Second)
+	defer first()
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := transport.acquireSlot(ctx, time.Second); !errors.Is(err, errUpstreamTimeout) {
+		t.Errorf("acquireSlot with an expired context = %v, want %v", err, errUpstreamTimeout)
+	}
+}
@@ -0,0 +1,63 @@
+// Package handlers 实现PMS的各个接口，以及上游访问、缓存、Cookie池等处理请求所需的组件
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"PMS/internal/config"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+)
+
+// Setup 按启动时的配置创建音频与封面代理的HTTP客户端、缓存与webhook注册表，需在处理请求前调用一次；
+// 上游API的客户端与实例列表由 NewUpstreamTransport 创建
+func Setup(cfg *config.Config) {
+	streamTransport := newHTTPTransport()
+	streamTransport.ResponseHeaderTimeout = cfg.UpstreamTimeout
+	streamClient = &http.Client{Transport: streamTransport}
+	coverClient = &http.Client{
+		Timeout:   cfg.UpstreamTimeout,
+		Transport: otelhttp.NewTransport(newHTTPTransport()),
+	}
+
+	// CACHE_BACKEND 未指定时，配置了Redis则使用共享缓存，否则使用进程内LRU
+	useRedis := cfg.CacheBackend == config.CacheBackendRedis ||
+		cfg.CacheBackend == "" && (cfg.RedisURL != "" || cfg.RedisAddr != "")
+	switch {
+	case useRedis:
+		var fallback *memoryCache
+		if cfg.CacheMaxEntries > 0 {
+			fallback = newMemoryCache(cfg.CacheMaxEntries)
+			fallback.onExpire = publishSongExpired
+		}
+		rc := newRedisCache(redisOptions(cfg), cfg.RedisTimeout, fallback)
+		rc.checkStartup(context.Background())
+		responseCache = rc
+	case cfg.CacheMaxEntries > 0:
+		mc := newMemoryCache(cfg.CacheMaxEntries)
+		mc.onExpire = publishSongExpired
+		responseCache = mc
+	}
+	if cfg.WebhooksEnabled {
+		webhooks = newWebhookRegistry(cfg.WebhooksMax, cfg.WebhooksAllowPrivate)
+	}
+	if cfg.CoverCacheMaxEntries > 0 {
+		coverCache = newMemoryCache(cfg.CoverCacheMaxEntries)
+		coverCache.maxBytes = cfg.CoverCacheMaxBytes
+	}
+}
+
+// CacheBackend 返回响应缓存的类型 (redis/memory/disabled)，Redis不可用而临时使用内存缓存时为 memory-fallback
+func CacheBackend() string {
+	switch rc := responseCache.(type) {
+	case *redisCache:
+		if rc.usingFallback() {
+			return "memory-fallback"
+		}
+		return "redis"
+	case *memoryCache:
+		return "memory"
+	}
+	return "disabled"
+}
@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"PMS/internal/netease"
+)
+
+// gatedClient 的 SongURL 在 release 关闭前阻塞，用于让并发请求在同一次上游请求上等待
+type gatedClient struct {
+	netease.Client
+	release chan struct{}
+	calls   atomic.Int32
+	// 前 failures 次调用返回 ErrBadStatus
+	failures int32
+}
+
+func newGatedClient(failures int32) *gatedClient {
+	fake := netease.NewFake()
+	fake.SetSongURL(1, "standard", playableSong(1))
+	return &gatedClient{Client: fake, release: make(chan struct{}), failures: failures}
+}
+
+func (c *gatedClient) SongURL(ctx context.Context, id int64, level, realIP string) (*netease.SongURLResponse, error) {
+	n := c.calls.Add(1)
+	<-c.release
+	if n <= c.failures {
+		return nil, netease.ErrBadStatus
+	}
+	return c.Client.SongURL(ctx, id, level, realIP)
+}
+
+// concurrentGets 并发发起 targets 中的请求，等待请求都进入处理后放行上游，返回各请求的状态码
+func concurrentGets(s *SongURLService, client *gatedClient, targets []string) []int {
+	codes := make([]int, len(targets))
+	var wg sync.WaitGroup
+	for i, target := range targets {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			codes[i] = serve(s.GetSongURL, http.MethodGet, target).Code
+		}()
+	}
+	// 等待所有请求到达 singleflight，第一个请求此时阻塞在上游
+	time.Sleep(50 * time.Millisecond)
+	close(client.release)
+	wg.Wait()
+	return codes
+}
+
+func repeat(target string, n int) []string {
+	targets := make([]string, n)
+	for i := range targets {
+		targets[i] = target
+	}
+	return targets
+}
+
+func TestSongURLCoalescesConcurrentRequests(t *testing.T) {
+	useTestConfig(t, nil)
+	useResponseCache(t, nil)
+	client := newGatedClient(0)
+	s := NewSongURLService(client)
+
+	for i, code := range concurrentGets(s, client, repeat("/song?id=1", 10)) {
+		if code != http.StatusOK {
+			t.Errorf("request %d: status = %d, want %d", i, code, http.StatusOK)
+		}
+	}
+	if got := client.calls.Load(); got != 1 {
+		t.Errorf("upstream calls = %d, want 10 concurrent requests coalesced into 1", got)
+	}
+}
+
+func TestSongURLDoesNotShareFailures(t *testing.T) {
+	useTestConfig(t, nil)
+	useResponseCache(t, nil)
+	client := newGatedClient(1)
+	s := NewSongURLService(client)
+
+	codes := concurrentGets(s, client, repeat("/song?id=1", 5))
+	failed := 0
+	for _, code := range codes {
+		if code != http.StatusOK {
+			failed++
+		}
+	}
+	// 只有发起请求的一方收到失败，等待者各自重新请求并成功
+	if failed != 1 {
+		t.Errorf("failed requests = %d (statuses %v), want only the leader to fail", failed, codes)
+	}
+	if got := client.calls.Load(); got != 5 {
+		t.Errorf("upstream calls = %d, want 1 failed call plus a retry per waiter", got)
+	}
+}
+
+func TestSongURLDoesNotCoalesceAcrossRealIP(t *testing.T) {
+	useTestConfig(t, nil)
+	useResponseCache(t, nil)
+	client := newGatedClient(0)
+	s := NewSongURLService(client)
+
+	concurrentGets(s, client, []string{"/song?id=1&realip=1.1.1.1", "/song?id=1&realip=2.2.2.2", "/song?id=1&realip=1.1.1.1"})
+	if got := client.calls.Load(); got != 2 {
+		t.Errorf("upstream calls = %d, want one per realIP", got)
+	}
+}
@@ -0,0 +1,376 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"PMS/internal/api"
+	"PMS/internal/config"
+	"PMS/internal/logging"
+	"PMS/internal/netease"
+	"PMS/internal/tracing"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// SongURLResponse /song 的响应，在上游响应的基础上附加音质降级信息
+type SongURLResponse struct {
+	netease.SongURLResponse
+
+	// 以下字段由PMS填充，用于告知客户端音质是否被降级
+	RequestedLevel string `json:"requestedLevel,omitempty"`
+	ServedLevel    string `json:"servedLevel,omitempty"`
+	Downgraded     bool   `json:"downgraded,omitempty"`
+	// 播放地址的失效时间 (RFC 3339)，由请求上游的时间加 expi 得出，缓存命中时保持不变
+	ExpiresAt string `json:"expires_at,omitempty"`
+	// 返回的地址只是试听片段，片段位置见 data[0].freeTrialInfo
+	IsTrial bool `json:"isTrial"`
+	// 由 data[0] 的 fee 与 freeTrialInfo 解读出的收费与试听信息
+	Meta *SongMeta `json:"meta,omitempty"`
+}
+
+// SongMeta 以易读的字段描述歌曲的收费方式与试听限制，取自上游的 fee 与 freeTrialInfo
+type SongMeta struct {
+	// fee 为1：VIP歌曲
+	RequiresVIP bool `json:"requires_vip"`
+	// fee 为4：付费专辑中的歌曲
+	IsPaidAlbum bool `json:"is_paid_album"`
+	// fee 为8：非VIP用户可免费播放，但音质受限
+	FreeBitrateCapped bool `json:"free_bitrate_capped"`
+	// 返回的地址只是试听片段，片段在歌曲中的起止位置 (秒) 见 TrialStart、TrialEnd
+	IsTrial    bool `json:"is_trial"`
+	TrialStart *int `json:"trial_start,omitempty"`
+	TrialEnd   *int `json:"trial_end,omitempty"`
+	// 只能播放试听片段时给出的提示，便于播放器提醒用户而不是静默播放片段
+	Notice string `json:"notice,omitempty"`
+}
+
+// 上游 fee 字段的取值
+const (
+	feeVIP               = 1
+	feePaidAlbum         = 4
+	feeFreeBitrateCapped = 8
+)
+
+// newSongMeta 解读 data 的 fee 与 freeTrialInfo
+func newSongMeta(data netease.SongURLData) *SongMeta {
+	meta := &SongMeta{
+		RequiresVIP:       data.Fee == feeVIP,
+		IsPaidAlbum:       data.Fee == feePaidAlbum,
+		FreeBitrateCapped: data.Fee == feeFreeBitrateCapped,
+	}
+	if trial := data.FreeTrialInfo; trial != nil {
+		meta.IsTrial = true
+		meta.TrialStart, meta.TrialEnd = &trial.Start, &trial.End
+		meta.Notice = fmt.Sprintf("Only a %d-second trial clip (%ds-%ds) is available for this song", trial.Duration, trial.Start, trial.End)
+	}
+	return meta
+}
+
+// annotate 填充由PMS计算的音质与试听字段
+func (r *SongURLResponse) annotate(requested, served string) {
+	r.RequestedLevel = requested
+	r.ServedLevel = served
+	r.Downgraded = requested != served
+	r.IsTrial = len(r.Data) > 0 && r.Data[0].FreeTrialInfo != nil
+	if len(r.Data) > 0 {
+		r.Meta = newSongMeta(r.Data[0])
+	}
+}
+
+// SongURLService 获取歌曲播放地址，负责缓存、并发请求合并与音质降级；
+// 上游通过构造时传入的 netease.Client 访问，/song、/songs、/stream、/download 与 /playlist 共用
+type SongURLService struct {
+	client netease.Client
+	// /playlist 的歌单信息与 /download 的文件名所需的歌曲详情
+	catalog *CatalogService
+}
+
+func NewSongURLService(client netease.Client) *SongURLService {
+	return &SongURLService{client: client, catalog: NewCatalogService(client)}
+}
+
+// GetSongURL 处理 GET /song?id=&level=&type=&min_br=&simple=&fields=&format=
+func (s *SongURLService) GetSongURL(c *gin.Context) {
+	// 获取歌曲ID
+	songID, ok := parseSongID(c, c.Query("id"))
+	if !ok {
+		return
+	}
+
+	// 获取可选参数
+	level := c.DefaultQuery("level", defaultLevel(c))
+	if !checkLevel(c, level) {
+		return
+	}
+	audioType := c.Query("type")
+	if !checkAudioType(c, audioType) {
+		return
+	}
+	minBitrate, ok := parseMinBitrate(c)
+	if !ok {
+		return
+	}
+	fields, ok := parseSimpleFields(c)
+	if !ok {
+		return
+	}
+	format := c.Query("format")
+	if format != "" && format != "json" && format != "txt" {
+		c.JSON(http.StatusBadRequest, api.NewErrorResponse(c, 400, "Invalid format, must be json or txt"))
+		return
+	}
+	realIP := c.DefaultQuery("realip", defaultRealIP(c))
+	nocache := c.Query("nocache") == "1"
+	fallback := c.Query("fallback") != "false"
+
+	ctx := c.Request.Context()
+	tracing.SetAttributes(ctx, attribute.Int64("pms.song_id", songID), attribute.String("pms.level", level))
+
+	songResp, cached, err := s.resolve(ctx, songID, level, realIP, nocache, fallback)
+	if cached != cacheDisabled {
+		c.Header("X-PMS-Cache", string(cached))
+		tracing.SetAttributes(ctx, attribute.String("pms.cache", string(cached)))
+	}
+	if err != nil {
+		respondUpstreamError(c, err)
+		return
+	}
+	tracing.SetAttributes(ctx, attribute.Int("pms.upstream_code", songResp.Code))
+	logging.From(ctx).Debug("song url resolved",
+		"song_id", songID,
+		"level", level,
+		"served_level", songResp.ServedLevel,
+		"cache", string(cached),
+		"upstream_code", songResp.Code,
+	)
+
+	// 检查网易云音乐API返回的状态码
+	if songResp.Code != 200 {
+		respondUpstreamCode(c, songResp.Code, songResp.Message)
+		return
+	}
+
+	// 上游无法提供播放地址时返回404与原因；raw=true 时保持上游的响应
+	raw := c.Query("raw") == "true"
+	if reason, unavailable := songUnavailableReason(songResp); unavailable && !raw {
+		if format == "txt" {
+			c.Status(http.StatusNotFound)
+			return
+		}
+		resp := api.NewErrorResponse(c, 404, songUnavailableMessages[reason])
+		resp.Reason = reason
+		c.JSON(http.StatusNotFound, resp)
+		return
+	}
+
+	// 指定了 type 但格式不符时返回204，X-PMS-Audio-Type 告知实际格式，客户端可换用其他音质或格式
+	if audioTypeMismatch(songResp, audioType) {
+		c.Header("X-PMS-Audio-Type", songResp.Data[0].Type)
+		c.Status(http.StatusNoContent)
+		return
+	}
+
+	// 码率低于 min_br 时拒绝，X-PMS-Bitrate 与错误信息中给出实际码率
+	if br, low := belowMinBitrate(songResp, minBitrate); low {
+		c.Header("X-PMS-Bitrate", strconv.Itoa(br))
+		c.JSON(http.StatusUnprocessableEntity, api.NewErrorResponse(c, 422, fmt.Sprintf("Bitrate %d bps is below the minimum of %d bps", br, minBitrate)))
+		return
+	}
+
+	// 重定向模式：直接302跳转到音频地址
+	if c.Query("redirect") == "true" {
+		redirectToSongURL(c, songResp)
+		return
+	}
+
+	// format=txt 只返回播放地址，便于 mpv "$(curl ...)" 等命令行用法；没有地址时返回空的404
+	if format == "txt" {
+		if !hasPlayableURL(songResp) {
+			c.Status(http.StatusNotFound)
+			return
+		}
+		c.String(http.StatusOK, songResp.Data[0].URL+"\n")
+		return
+	}
+
+	// 返回结果，raw=true 时只返回上游的响应，不附加PMS填充的字段；simple=true 或 fields= 时返回扁平的精简结果
+	if fields != nil {
+		c.JSON(http.StatusOK, newSimpleSongURL(songID, songResp).only(fields))
+		return
+	}
+	if raw {
+		c.JSON(http.StatusOK, songResp.SongURLResponse)
+		return
+	}
+	c.JSON(http.StatusOK, songResp)
+}
+
+// redirectToSongURL 302跳转到解析出的音频地址，地址为空时返回404
+func redirectToSongURL(c *gin.Context, songResp *SongURLResponse) {
+	if len(songResp.Data) == 0 || songResp.Data[0].URL == "" {
+		c.JSON(http.StatusNotFound, api.NewErrorResponse(c, 404, "No playable URL available for this song"))
+		return
+	}
+
+	// 缓存时间不超过地址有效期，避免CDN缓存已过期的链接；来自缓存的地址按 ExpiresAt 计算剩余有效期
+	maxAge := songResp.Data[0].Expi - int(config.Current().CacheTTLSafety.Seconds())
+	if remaining, ok := urlValidFor(songResp); ok {
+		maxAge = int((remaining - config.Current().CacheTTLSafety).Seconds())
+	}
+	if maxAge > 0 {
+		c.Header("Cache-Control", fmt.Sprintf("public, max-age=%d", maxAge))
+	} else {
+		c.Header("Cache-Control", "no-store")
+	}
+	c.Redirect(http.StatusFound, songResp.Data[0].URL)
+}
+
+// SimpleSongURL /song?simple=true 返回的扁平结果，便于嵌入式设备等只需要播放地址的客户端解析
+type SimpleSongURL struct {
+	ID    int64  `json:"id"`
+	URL   string `json:"url"`
+	Br    int    `json:"br"`
+	Size  int    `json:"size"`
+	Type  string `json:"type"`
+	Level string `json:"level"`
+}
+
+// simpleSongURLFields ?fields= 可选的字段，顺序与 SimpleSongURL 一致
+var simpleSongURLFields = []string{"id", "url", "br", "size", "type", "level"}
+
+// newSimpleSongURL 取 data[0] 生成精简结果，上游未返回音质时使用 servedLevel
+func newSimpleSongURL(songID int64, songResp *SongURLResponse) SimpleSongURL {
+	simple := SimpleSongURL{ID: songID, Level: songResp.ServedLevel}
+	if len(songResp.Data) > 0 {
+		data := songResp.Data[0]
+		simple.URL, simple.Br, simple.Size, simple.Type = data.URL, data.Br, data.Size, data.Type
+		if data.Level != "" {
+			simple.Level = data.Level
+		}
+	}
+	return simple
+}
+
+// only 只保留 fields 中的字段，fields 包含全部字段时原样返回
+func (s SimpleSongURL) only(fields []string) any {
+	if len(fields) == len(simpleSongURLFields) {
+		return s
+	}
+	all := map[string]any{"id": s.ID, "url": s.URL, "br": s.Br, "size": s.Size, "type": s.Type, "level": s.Level}
+	selected := make(map[string]any, len(fields))
+	for _, field := range fields {
+		selected[field] = all[field]
+	}
+	return selected
+}
+
+// parseSimpleFields 读取 simple 与 fields 参数，返回需要的精简字段，均未指定时返回 nil 表示完整响应；
+// 未知字段或与 raw=true 同时使用时写入400响应
+func parseSimpleFields(c *gin.Context) ([]string, bool) {
+	value, hasFields := c.GetQuery("fields")
+	simple := c.Query("simple") == "true"
+	if !hasFields && !simple {
+		return nil, true
+	}
+	if c.Query("raw") == "true" {
+		c.JSON(http.StatusBadRequest, api.NewErrorResponse(c, 400, "raw=true cannot be combined with simple or fields"))
+		return nil, false
+	}
+	if !hasFields {
+		return simpleSongURLFields, true
+	}
+
+	var fields, unknown []string
+	seen := make(map[string]bool)
+	for _, field := range strings.Split(value, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" || seen[field] {
+			continue
+		}
+		seen[field] = true
+		if !slices.Contains(simpleSongURLFields, field) {
+			unknown = append(unknown, field)
+			continue
+		}
+		fields = append(fields, field)
+	}
+	if len(unknown) > 0 || len(fields) == 0 {
+		msg := "Missing fields"
+		if len(unknown) > 0 {
+			msg = "Unknown fields: " + strings.Join(unknown, ", ")
+		}
+		c.JSON(http.StatusBadRequest, api.NewErrorResponse(c, 400, fmt.Sprintf("%s, valid fields are %s", msg, strings.Join(simpleSongURLFields, ", "))))
+		return nil, false
+	}
+	return fields, true
+}
+
+// parseMinBitrate 读取 min_br 参数，未指定时使用 MIN_BITRATE，无效时写入400响应
+func parseMinBitrate(c *gin.Context) (int, bool) {
+	value := c.Query("min_br")
+	if value == "" {
+		return config.Current().MinBitrate, true
+	}
+	minBitrate, err := strconv.Atoi(value)
+	if err != nil || minBitrate < 0 {
+		c.JSON(http.StatusBadRequest, api.NewErrorResponse(c, 400, "Invalid min_br, must be a non-negative bitrate in bps"))
+		return 0, false
+	}
+	return minBitrate, true
+}
+
+// belowMinBitrate 判断上游返回的码率是否低于 minBitrate，并返回实际码率；
+// 没有播放地址或上游未返回码率时不做限制
+func belowMinBitrate(songResp *SongURLResponse, minBitrate int) (int, bool) {
+	if minBitrate <= 0 || !hasPlayableURL(songResp) {
+		return 0, false
+	}
+	br := songResp.Data[0].Br
+	return br, br > 0 && br < minBitrate
+}
+
+// 上游无法提供播放地址的原因 (ErrorResponse.Reason)
+const (
+	reasonNotFound      = "not_found"
+	reasonVIPRequired   = "vip_required"
+	reasonPaidAlbum     = "paid_album"
+	reasonRegionBlocked = "region_blocked"
+	reasonUnavailable   = "unavailable"
+)
+
+var songUnavailableMessages = map[string]string{
+	reasonNotFound:      "Song not found",
+	reasonVIPRequired:   "Song requires a VIP account",
+	reasonPaidAlbum:     "Song is part of a paid album",
+	reasonRegionBlocked: "Song is not available in this region",
+	reasonUnavailable:   "No playable URL available for this song",
+}
+
+// songUnavailableReason 判断降级后是否仍没有播放地址，并按 data[0] 的 code、fee 与 freeTrialInfo 推断原因：
+// 没有 data 或 code 为404时为 not_found，fee 为1 或只有试听片段时为 vip_required，fee 为4时为 paid_album，
+// code 为 -110 (无版权，通常因地区限制) 时为 region_blocked
+func songUnavailableReason(songResp *SongURLResponse) (string, bool) {
+	if len(songResp.Data) == 0 {
+		return reasonNotFound, true
+	}
+	data := songResp.Data[0]
+	if data.URL != "" && (data.Code == 200 || data.Code == 0) {
+		return "", false
+	}
+	switch {
+	case data.Code == 404:
+		return reasonNotFound, true
+	case data.Fee == feeVIP || data.FreeTrialInfo != nil:
+		return reasonVIPRequired, true
+	case data.Fee == feePaidAlbum:
+		return reasonPaidAlbum, true
+	case data.Code == -110:
+		return reasonRegionBlocked, true
+	}
+	return reasonUnavailable, true
+}
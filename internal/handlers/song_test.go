@@ -0,0 +1,227 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"PMS/internal/api"
+	"PMS/internal/netease"
+)
+
+func TestGetSongURL(t *testing.T) {
+	tests := []struct {
+		name             string
+		setup            func(f *netease.Fake)
+		wantStatus       int
+		wantMessage      string
+		wantUpstreamCode int
+	}{
+		{
+			name:       "success",
+			setup:      func(f *netease.Fake) { f.SetSongURL(1, "standard", playableSong(1)) },
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:             "upstream requires login",
+			setup:            func(f *netease.Fake) { f.SetSongURL(1, "standard", &netease.SongURLResponse{Code: 301}) },
+			wantStatus:       http.StatusUnauthorized,
+			wantMessage:      "Music service requires login, the configured cookie is missing or expired",
+			wantUpstreamCode: 301,
+		},
+		{
+			name:             "upstream risk control",
+			setup:            func(f *netease.Fake) { f.SetSongURL(1, "standard", &netease.SongURLResponse{Code: -460}) },
+			wantStatus:       http.StatusForbidden,
+			wantUpstreamCode: -460,
+		},
+		{
+			name:             "unknown upstream code",
+			setup:            func(f *netease.Fake) { f.SetSongURL(1, "standard", &netease.SongURLResponse{Code: 500}) },
+			wantStatus:       http.StatusBadGateway,
+			wantMessage:      "Music service returned error",
+			wantUpstreamCode: 500,
+		},
+		{
+			name:        "malformed upstream JSON",
+			setup:       func(f *netease.Fake) { f.SetError(1, "standard", netease.ErrParse) },
+			wantStatus:  http.StatusBadGateway,
+			wantMessage: "Failed to parse response from music service",
+		},
+		{
+			name:        "upstream timeout",
+			setup:       func(f *netease.Fake) { f.SetError(1, "standard", netease.ErrTimeout) },
+			wantStatus:  http.StatusGatewayTimeout,
+			wantMessage: "Music service request timed out",
+		},
+		{
+			name:        "upstream unreachable",
+			setup:       func(f *netease.Fake) { f.SetError(1, "standard", netease.ErrBadStatus) },
+			wantStatus:  http.StatusBadGateway,
+			wantMessage: "Music service is temporarily unavailable",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useTestConfig(t, nil)
+			useResponseCache(t, nil)
+			fake := netease.NewFake()
+			tt.setup(fake)
+
+			w := serve(NewSongURLService(fake).GetSongURL, http.MethodGet, "/song?id=1")
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d; body %s", w.Code, tt.wantStatus, w.Body)
+			}
+			if tt.wantStatus == http.StatusOK {
+				resp := decodeBody[SongURLResponse](t, w)
+				if len(resp.Data) != 1 || resp.Data[0].URL == "" || resp.ServedLevel != "standard" {
+					t.Errorf("response = %+v, want the playable URL at standard", resp)
+				}
+				return
+			}
+			resp := decodeBody[api.ErrorResponse](t, w)
+			if resp.Code != tt.wantStatus {
+				t.Errorf("error code = %d, want %d", resp.Code, tt.wantStatus)
+			}
+			if tt.wantMessage != "" && resp.Message != tt.wantMessage {
+				t.Errorf("error message = %q, want %q", resp.Message, tt.wantMessage)
+			}
+			if resp.UpstreamCode != tt.wantUpstreamCode {
+				t.Errorf("upstream_code = %d, want %d", resp.UpstreamCode, tt.wantUpstreamCode)
+			}
+		})
+	}
+}
+
+func TestGetSongURLMalformedUpstreamJSON(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        string
+		wantMessage string
+	}{
+		{name: "truncated JSON", body: `{"code":200,"data":[{"id":1`, wantMessage: "Failed to parse response from music service"},
+		{name: "wrong field type", body: `{"code":"ok","data":[]}`, wantMessage: "Failed to parse response from music service"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transport := newTestUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				io.WriteString(w, tt.body)
+			}, map[string]string{"UPSTREAM_MAX_RETRIES": "0"})
+			useResponseCache(t, nil)
+
+			w := serve(NewSongURLService(netease.NewHTTPClient(transport)).GetSongURL, http.MethodGet, "/song?id=1")
+			if w.Code != http.StatusBadGateway {
+				t.Fatalf("status = %d, want %d; body %s", w.Code, http.StatusBadGateway, w.Body)
+			}
+			if resp := decodeBody[api.ErrorResponse](t, w); resp.Message != tt.wantMessage {
+				t.Errorf("error message = %q, want %q", resp.Message, tt.wantMessage)
+			}
+		})
+	}
+}
+
+func TestGetSongURLInvalidID(t *testing.T) {
+	useTestConfig(t, nil)
+	fake := netease.NewFake()
+
+	for _, target := range []string{"/song", "/song?id=abc", "/song?id=-1"} {
+		w := serve(NewSongURLService(fake).GetSongURL, http.MethodGet, target)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("GET %s: status = %d, want %d", target, w.Code, http.StatusBadRequest)
+		}
+	}
+	if got := fake.Calls(1, "standard"); got != 0 {
+		t.Errorf("upstream calls = %d, want none for invalid ids", got)
+	}
+}
+
+func TestSongMetaFromUpstreamFixtures(t *testing.T) {
+	trialStart, trialEnd := 30, 60
+	tests := []struct {
+		name    string
+		fixture string
+		want    SongMeta
+	}{
+		{
+			name:    "free song",
+			fixture: `{"id":1,"url":"http://m701.music.126.net/a.mp3","code":200,"fee":0,"freeTrialInfo":null}`,
+		},
+		{
+			name:    "vip song with trial clip",
+			fixture: `{"id":1,"url":"http://m701.music.126.net/a.mp3","code":200,"fee":1,"freeTrialInfo":{"start":29.6,"end":59.6}}`,
+			want: SongMeta{RequiresVIP: true, IsTrial: true, TrialStart: &trialStart, TrialEnd: &trialEnd,
+				Notice: "Only a 30-second trial clip (30s-60s) is available for this song"},
+		},
+		{
+			name:    "paid album",
+			fixture: `{"id":1,"url":"","code":200,"fee":4,"freeTrialInfo":null}`,
+			want:    SongMeta{IsPaidAlbum: true},
+		},
+		{
+			name:    "free with capped bitrate",
+			fixture: `{"id":1,"url":"http://m701.music.126.net/a.mp3","code":200,"fee":8}`,
+			want:    SongMeta{FreeBitrateCapped: true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var data netease.SongURLData
+			if err := json.Unmarshal([]byte(tt.fixture), &data); err != nil {
+				t.Fatalf("decoding fixture: %v", err)
+			}
+			if got := newSongMeta(data); !reflect.DeepEqual(*got, tt.want) {
+				gotJSON, _ := json.Marshal(got)
+				wantJSON, _ := json.Marshal(tt.want)
+				t.Errorf("newSongMeta = %s, want %s", gotJSON, wantJSON)
+			}
+		})
+	}
+}
+
+func TestSongUnavailableReason(t *testing.T) {
+	tests := []struct {
+		name string
+		data netease.SongURLList
+		want string
+	}{
+		{name: "no data", want: reasonNotFound},
+		{name: "playable", data: netease.SongURLList{{URL: "http://a.mp3", Code: 200}}},
+		{name: "not found", data: netease.SongURLList{{Code: 404}}, want: reasonNotFound},
+		{name: "vip", data: netease.SongURLList{{Code: 200, Fee: feeVIP}}, want: reasonVIPRequired},
+		{name: "trial without url", data: netease.SongURLList{{Code: 200, FreeTrialInfo: &netease.FreeTrialInfo{Start: 0, End: 30}}}, want: reasonVIPRequired},
+		{name: "paid album", data: netease.SongURLList{{Code: 200, Fee: feePaidAlbum}}, want: reasonPaidAlbum},
+		{name: "no copyright", data: netease.SongURLList{{Code: -110}}, want: reasonRegionBlocked},
+		{name: "other", data: netease.SongURLList{{Code: 200}}, want: reasonUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := &SongURLResponse{SongURLResponse: netease.SongURLResponse{Code: 200, Data: tt.data}}
+			got, unavailable := songUnavailableReason(resp)
+			if got != tt.want || unavailable != (tt.want != "") {
+				t.Errorf("songUnavailableReason = %q, %t; want %q", got, unavailable, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetSongURLTrialClip(t *testing.T) {
+	useTestConfig(t, nil)
+	useResponseCache(t, nil)
+	fake := netease.NewFake()
+	song := playableSong(1)
+	song.Data[0].Fee = feeVIP
+	song.Data[0].FreeTrialInfo = &netease.FreeTrialInfo{Start: 10, End: 40, Duration: 30}
+	fake.SetSongURL(1, "standard", song)
+
+	w := serve(NewSongURLService(fake).GetSongURL, http.MethodGet, "/song?id=1")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d; body %s", w.Code, w.Body)
+	}
+	resp := decodeBody[SongURLResponse](t, w)
+	if !resp.IsTrial || resp.Meta == nil || !resp.Meta.IsTrial || !resp.Meta.RequiresVIP {
+		t.Errorf("response = isTrial %t, meta %+v; want a VIP trial clip", resp.IsTrial, resp.Meta)
+	}
+}
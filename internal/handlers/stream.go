@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"PMS/internal/api"
+	"PMS/internal/config"
+	"PMS/internal/logging"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 转发音频时使用的HTTP客户端，不设置整体超时以免长时间传输被中断，
+// 仅限制等待CDN响应头的时间，在 Setup 中初始化
+var streamClient = http.DefaultClient
+
+// 透传给客户端的CDN响应头
+var streamPassthroughHeaders = []string{"Content-Length", "Content-Range", "Accept-Ranges", "Last-Modified", "ETag"}
+
+// audioContentType 根据上游返回的 type 字段推断 Content-Type
+func audioContentType(audioType string) string {
+	switch strings.ToLower(audioType) {
+	case "mp3":
+		return "audio/mpeg"
+	case "flac":
+		return "audio/flac"
+	case "m4a", "aac":
+		return "audio/mp4"
+	case "ogg":
+		return "audio/ogg"
+	case "wav":
+		return "audio/wav"
+	default:
+		return ""
+	}
+}
+
+// streamContext 为音频传输设置 STREAM_MAX_DURATION 的总时限，防止长时间占用连接与带宽
+func streamContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if limit := config.Current().StreamMaxDuration; limit > 0 {
+		return context.WithTimeout(ctx, limit)
+	}
+	return context.WithCancel(ctx)
+}
+
+// StreamSong 处理 GET /stream/:id 与 GET /stream?id=，解析歌曲地址后由PMS代理音频数据
+func (s *SongURLService) StreamSong(c *gin.Context) {
+	if !config.Current().StreamEnabled {
+		c.JSON(http.StatusNotFound, api.NewErrorResponse(c, 404, "Streaming is disabled"))
+		return
+	}
+
+	id := c.Param("id")
+	if id == "" {
+		id = c.Query("id")
+	}
+	songID, ok := parseSongID(c, id)
+	if !ok {
+		return
+	}
+
+	level := c.DefaultQuery("level", defaultLevel(c))
+	if !checkLevel(c, level) {
+		return
+	}
+	realIP := c.DefaultQuery("realip", defaultRealIP(c))
+	nocache := c.Query("nocache") == "1"
+	fallback := c.Query("fallback") != "false"
+
+	songResp, _, err := s.resolve(c.Request.Context(), songID, level, realIP, nocache, fallback)
+	if err != nil {
+		respondUpstreamError(c, err)
+		return
+	}
+
+	// 检查网易云音乐API返回的状态码
+	if songResp.Code != 200 {
+		respondUpstreamCode(c, songResp.Code, songResp.Message)
+		return
+	}
+
+	if len(songResp.Data) == 0 || songResp.Data[0].URL == "" {
+		c.JSON(http.StatusNotFound, api.NewErrorResponse(c, 404, "No playable URL available for this song"))
+		return
+	}
+
+	proxyAudio(c, songResp.Data[0].URL, audioContentType(songResp.Data[0].Type))
+}
+
+// proxyAudio 将音频数据从CDN流式转发给客户端，透传 Range 请求；
+// 客户端断开时请求上下文被取消，CDN传输随之中止
+func proxyAudio(c *gin.Context, audioURL, contentType string) {
+	log := logging.From(c.Request.Context())
+	ctx, cancel := streamContext(c.Request.Context())
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, audioURL, nil)
+	if err != nil {
+		log.Error("error building audio request", "error", err)
+		c.JSON(http.StatusInternalServerError, api.NewErrorResponse(c, 500, "Failed to request audio stream"))
+		return
+	}
+	if rangeHeader := c.GetHeader("Range"); rangeHeader != "" {
+		req.Header.Set("Range", rangeHeader)
+	}
+
+	resp, err := streamClient.Do(req)
+	if err != nil {
+		if errors.Is(err, context.Canceled) {
+			return
+		}
+		if isProxyError(err) {
+			log.Error("audio stream proxy connection failed", "error", err)
+			c.JSON(http.StatusBadGateway, api.NewErrorResponse(c, 502, "Failed to connect to audio source through proxy"))
+			return
+		}
+		log.Error("error requesting audio stream", "error", err)
+		c.JSON(http.StatusBadGateway, api.NewErrorResponse(c, 502, "Failed to request audio stream"))
+		return
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusPartialContent, http.StatusRequestedRangeNotSatisfiable:
+	default:
+		log.Error("audio CDN returned error", "status_code", resp.StatusCode)
+		c.JSON(http.StatusBadGateway, api.NewErrorResponse(c, 502, "Audio source returned error"))
+		return
+	}
+
+	for _, header := range streamPassthroughHeaders {
+		if value := resp.Header.Get(header); value != "" {
+			c.Header(header, value)
+		}
+	}
+	if contentType == "" {
+		contentType = resp.Header.Get("Content-Type")
+	}
+	if contentType != "" {
+		c.Header("Content-Type", contentType)
+	}
+
+	c.Status(resp.StatusCode)
+	extendWriteDeadline(c)
+	if _, err := io.Copy(c.Writer, resp.Body); err != nil && !errors.Is(err, context.Canceled) {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			log.Warn("audio stream exceeded STREAM_MAX_DURATION", "max_duration", config.Current().StreamMaxDuration.String())
+			return
+		}
+		log.Warn("audio stream interrupted", "error", err)
+	}
+}
+
+// extendWriteDeadline 将当前响应的写超时替换为 STREAM_MAX_DURATION (0 表示不限制)，
+// 避免较大的音频传输被 SERVER_WRITE_TIMEOUT 中断
+func extendWriteDeadline(c *gin.Context) {
+	var deadline time.Time
+	if timeout := config.Current().StreamMaxDuration; timeout > 0 {
+		deadline = time.Now().Add(timeout)
+	}
+	if err := http.NewResponseController(c.Writer).SetWriteDeadline(deadline); err != nil {
+		logging.From(c.Request.Context()).Warn("failed to extend write deadline", "error", err)
+	}
+}
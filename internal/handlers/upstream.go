@@ -0,0 +1,486 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"PMS/internal/config"
+	"PMS/internal/logging"
+	"PMS/internal/metrics"
+	"PMS/internal/middleware"
+	"PMS/internal/netease"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+)
+
+// newHTTPTransport 创建按 HTTP_* 配置连接池的 Transport，上游API与音频代理各用一个；
+// 代理默认取自 HTTP_PROXY/HTTPS_PROXY/NO_PROXY，配置了 UPSTREAM_PROXY 时改用该代理
+func newHTTPTransport() *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConns = config.Current().HTTPMaxIdleConns
+	transport.MaxIdleConnsPerHost = config.Current().HTTPMaxIdleConnsPerHost
+	transport.IdleConnTimeout = config.Current().HTTPIdleConnTimeout
+	if proxy := upstreamProxyURL(); proxy != nil {
+		transport.Proxy = http.ProxyURL(proxy)
+	}
+	return transport
+}
+
+var (
+	errUpstreamTimeout   = netease.ErrTimeout
+	errUpstreamRequest   = netease.ErrRequest
+	errUpstreamBadStatus = netease.ErrBadStatus
+	errUpstreamRead      = netease.ErrRead
+	errUpstreamParse     = netease.ErrParse
+	errUpstreamProxy     = netease.ErrProxy
+	errUpstreamCanceled  = netease.ErrCanceled
+)
+
+// 上游响应体的大小上限，超出时视为读取失败
+const upstreamMaxBodyBytes = 5 << 20
+
+// 客户端在响应前断开连接时使用的状态码 (沿用 nginx 的 499 Client Closed Request)
+const statusClientClosedRequest = 499
+
+// UpstreamTransport 实现 netease.Transport，持有访问上游的HTTP客户端、按优先级排列的上游实例与并发名额，
+// 提供多实例故障转移、熔断、重试与Cookie池
+type UpstreamTransport struct {
+	client *http.Client
+	// 按优先级排列的上游实例，第一个为主实例
+	targets []*upstreamTarget
+	// 限制同时进行的上游请求数，为 nil 时不限制
+	slots chan struct{}
+	// 最近一次成功请求所用实例的下标
+	active atomic.Int32
+	// UPSTREAM_STRATEGY=round-robin 时下一个请求的首选实例
+	next atomic.Uint64
+	// 未指定Cookie的请求使用的Cookie池，随Cookie更新而替换
+	cookies func() *cookiePool
+}
+
+// NewUpstreamTransport 按启动时的配置创建上游HTTP客户端与实例列表；
+// MOCK_UPSTREAM=true 时上游替换为进程内的模拟数据，请求仍经过重试、熔断与Cookie池
+func NewUpstreamTransport(cfg *config.Config) *UpstreamTransport {
+	client := &http.Client{
+		Timeout:   cfg.UpstreamTimeout,
+		Transport: otelhttp.NewTransport(newHTTPTransport()),
+	}
+	bases := config.ParseUpstreamBases(cfg.NeteaseMusicAPI)
+	if cfg.MockUpstream {
+		client.Transport = otelhttp.NewTransport(mockTransport{})
+		bases = []string{mockUpstreamBase}
+	}
+
+	t := &UpstreamTransport{
+		client:  client,
+		targets: newUpstreamTargets(bases, cfg.CBFailureThreshold, cfg.CBOpenDuration),
+		cookies: currentCookiePool,
+	}
+	if cfg.UpstreamMaxConcurrent > 0 {
+		t.slots = make(chan struct{}, cfg.UpstreamMaxConcurrent)
+	}
+	return t
+}
+
+// upstreamURL 构建不携带Cookie的上游接口路径与查询参数，用于健康探测
+func upstreamURL(path string, params url.Values, realIP string) string {
+	return netease.URL(path, params, realIP, "")
+}
+
+// Get 实现 netease.Transport，apiURL 为 netease.URL 生成的路径与参数。
+// 每次尝试按优先级依次请求各上游实例，网络错误或5xx时立即换下一个实例；
+// 所有实例都失败后按指数退避重试，所有重试共享 UPSTREAM_TIMEOUT 的总时限
+func (t *UpstreamTransport) Get(ctx context.Context, apiURL string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, config.Current().UpstreamTimeout)
+	defer cancel()
+
+	endpoint, rawQuery, _ := strings.Cut(apiURL, "?")
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		logging.From(ctx).Error("error parsing upstream request parameters", "error", err)
+		return nil, errUpstreamRequest
+	}
+
+	// netease.URL 指定的cookie从参数中取出单独发送，实际发送方式由 UPSTREAM_COOKIE_MODE 决定；
+	// 租户的请求使用租户的Cookie；都未指定时每次尝试都从池中选取，重试可换用其他Cookie
+	fixedCookie := query.Get("cookie")
+	var pool *cookiePool
+	if query.Has("cookie") {
+		query.Del("cookie")
+	} else if tenant, ok := config.TenantFrom(ctx); ok {
+		fixedCookie = tenant.Cookie
+	} else {
+		pool = t.cookies()
+	}
+	reqURL := endpoint + "?" + query.Encode()
+
+	for attempt := 0; ; attempt++ {
+		cookie := fixedCookie
+		var slot *cookieSlot
+		if pool != nil {
+			if slot = pool.Pick(); slot != nil {
+				cookie = slot.value
+			}
+		}
+
+		body, err := t.getLimited(ctx, reqURL, cookie, endpoint)
+		if err == nil && slot != nil {
+			pool.Record(slot, body)
+		}
+		if err == nil {
+			if attempt > 0 {
+				logging.From(ctx).Info("upstream request succeeded after retries", "retries", attempt)
+			}
+			recordQuotaUsage(ctx)
+			return body, nil
+		}
+
+		if !isRetryable(err) || attempt >= config.Current().UpstreamRetries {
+			if attempt > 0 && !errors.Is(err, errUpstreamCanceled) {
+				logging.From(ctx).Error("upstream request failed after retries", "retries", attempt, "error", err)
+			}
+			return nil, err
+		}
+
+		delay := retryDelay(attempt)
+		metrics.ObserveUpstreamRetry(endpoint, attempt+1)
+		logging.From(ctx).Warn("upstream request failed, retrying",
+			"error", err,
+			"retry_in_ms", delay.Milliseconds(),
+			"attempt", attempt+1,
+			"max_retries", config.Current().UpstreamRetries,
+		)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, upstreamContextError(ctx)
+		case <-timer.C:
+		}
+	}
+}
+
+// getLimited 在 UPSTREAM_MAX_CONCURRENT 的限制内请求上游，名额在每次尝试结束后释放，
+// 重试前的等待不占用名额
+func (t *UpstreamTransport) getLimited(ctx context.Context, reqURL, cookie, endpoint string) ([]byte, error) {
+	release, err := t.acquireSlot(ctx, config.Current().UpstreamConcurrencyWait)
+	if err != nil {
+		if errors.Is(err, errUpstreamBusy) {
+			logging.From(ctx).Warn("too many concurrent upstream requests", "upstream_endpoint", endpoint, "max_concurrent", cap(t.slots))
+		}
+		return nil, err
+	}
+	defer release()
+	return t.getFailover(ctx, reqURL, cookie, endpoint)
+}
+
+// getFailover 按 order 的顺序请求各上游实例，跳过已熔断的实例；
+// priority 策略下每个请求都从主实例开始，主实例恢复后立即重新使用
+func (t *UpstreamTransport) getFailover(ctx context.Context, reqURL, cookie, endpoint string) ([]byte, error) {
+	err := errCircuitOpen
+	for _, i := range t.order() {
+		target := t.targets[i]
+		if target.breaker.Allow() != nil {
+			continue
+		}
+
+		attemptCtx, cancel := ctx, context.CancelFunc(func() {})
+		if timeout := t.attemptTimeout(); timeout > 0 {
+			attemptCtx, cancel = context.WithTimeout(ctx, timeout)
+		}
+		var body []byte
+		body, err = t.getOnce(attemptCtx, target.base+reqURL, cookie, endpoint, i)
+		cancel()
+		if errors.Is(err, errUpstreamCanceled) {
+			// 客户端已断开，本次结果与实例是否健康无关
+			target.breaker.Cancel()
+			return nil, err
+		}
+		failed := isUpstreamFailure(err)
+		target.breaker.Record(failed)
+		if err == nil {
+			target.markSucceeded()
+			t.setActive(i)
+			return body, nil
+		}
+		if failed {
+			target.markFailed()
+		}
+		// 单个实例超时且总时限未耗尽时换下一个实例；总时限耗尽或错误与实例无关时不再尝试后续实例
+		if !isRetryable(err) && !(errors.Is(err, errUpstreamTimeout) && ctx.Err() == nil) {
+			return nil, err
+		}
+	}
+	return nil, err
+}
+
+func (t *UpstreamTransport) getOnce(ctx context.Context, fullURL, cookie, endpoint string, upstreamIndex int) ([]byte, error) {
+	req, err := newUpstreamRequest(ctx, fullURL, cookie)
+	if err != nil {
+		logging.From(ctx).Error("error building upstream request", "error", err)
+		return nil, errUpstreamRequest
+	}
+
+	log := logging.From(ctx).With("upstream_endpoint", endpoint, "upstream", upstreamIndex)
+	start := time.Now()
+
+	// 发起HTTP请求
+	resp, err := t.client.Do(req)
+	if err != nil {
+		// url.Error 包含带cookie的完整地址，只保留底层错误以免写入日志
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		if errors.Is(err, context.Canceled) {
+			metrics.ObserveUpstream(endpoint, "canceled", time.Since(start))
+			log.Debug("upstream request canceled, client closed request", "upstream_latency_ms", time.Since(start).Milliseconds())
+			return nil, errUpstreamCanceled
+		}
+		if isTimeout(err) {
+			metrics.ObserveUpstream(endpoint, "timeout", time.Since(start))
+			log.Error("upstream request timed out", "upstream_latency_ms", time.Since(start).Milliseconds())
+			return nil, errUpstreamTimeout
+		}
+		metrics.ObserveUpstream(endpoint, "error", time.Since(start))
+		if isProxyError(err) {
+			log.Error("upstream proxy connection failed", "error", err, "upstream_latency_ms", time.Since(start).Milliseconds())
+			return nil, errUpstreamProxy
+		}
+		log.Error("error requesting upstream", "error", err, "upstream_latency_ms", time.Since(start).Milliseconds())
+		return nil, errUpstreamRequest
+	}
+	defer resp.Body.Close()
+	defer func() { metrics.ObserveUpstream(endpoint, strconv.Itoa(resp.StatusCode), time.Since(start)) }()
+
+	if resp.StatusCode >= 500 {
+		log.Error("upstream returned server error",
+			"status_code", resp.StatusCode,
+			"upstream_latency_ms", time.Since(start).Milliseconds(),
+		)
+		return nil, errUpstreamBadStatus
+	}
+
+	// 读取响应，最多读取 upstreamMaxBodyBytes
+	body, err := io.ReadAll(io.LimitReader(resp.Body, upstreamMaxBodyBytes+1))
+	if err != nil {
+		if errors.Is(err, context.Canceled) {
+			log.Debug("upstream response canceled, client closed request")
+			return nil, errUpstreamCanceled
+		}
+		if isTimeout(err) {
+			log.Error("upstream response timed out", "upstream_latency_ms", time.Since(start).Milliseconds())
+			return nil, errUpstreamTimeout
+		}
+		log.Error("error reading upstream response body", "error", err)
+		return nil, errUpstreamRead
+	}
+	if len(body) > upstreamMaxBodyBytes {
+		log.Error("upstream response too large", "limit_bytes", upstreamMaxBodyBytes)
+		return nil, errUpstreamRead
+	}
+	// 上游前的代理配置错误时可能返回HTML错误页
+	if !netease.LooksLikeJSON(body) {
+		log.Error("upstream returned non-JSON response",
+			"status_code", resp.StatusCode,
+			"content_type", resp.Header.Get("Content-Type"),
+			"upstream_latency_ms", time.Since(start).Milliseconds(),
+		)
+		return nil, &netease.NonJSONError{Status: resp.StatusCode}
+	}
+
+	// priority 策略下使用备用实例时以 info 级别记录，便于发现主实例异常
+	level := slog.LevelDebug
+	if upstreamIndex > 0 && config.Current().UpstreamStrategy == config.UpstreamStrategyPriority {
+		level = slog.LevelInfo
+	}
+	log.Log(ctx, level, "upstream request completed",
+		"status_code", resp.StatusCode,
+		"upstream_latency_ms", time.Since(start).Milliseconds(),
+	)
+
+	return body, nil
+}
+
+// newUpstreamRequest 构建上游请求并附带当前请求ID
+func newUpstreamRequest(ctx context.Context, fullURL, cookie string) (*http.Request, error) {
+	req, err := buildUpstreamRequest(ctx, fullURL, cookie)
+	if err != nil {
+		return nil, err
+	}
+	// 透传请求ID，便于与上游日志对应
+	if id := logging.RequestID(ctx); id != "" {
+		req.Header.Set(middleware.RequestIDHeader, id)
+	}
+	return req, nil
+}
+
+// buildUpstreamRequest 按 UPSTREAM_COOKIE_MODE 构建上游请求：
+// header 通过 Cookie 请求头发送，post 通过表单请求体发送，query 附加在查询参数中（兼容旧部署）
+func buildUpstreamRequest(ctx context.Context, fullURL, cookie string) (*http.Request, error) {
+	if cookie == "" {
+		return http.NewRequestWithContext(ctx, http.MethodGet, fullURL, nil)
+	}
+
+	switch config.Current().UpstreamCookieMode {
+	case config.UpstreamCookieHeader:
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, fullURL, nil)
+		if err != nil {
+			return nil, err
+		}
+		// 只配置了 MUSIC_U 的值时补全键名
+		if !strings.Contains(cookie, "=") {
+			cookie = "MUSIC_U=" + cookie
+		}
+		req.Header.Set("Cookie", cookie)
+		return req, nil
+	case config.UpstreamCookiePost:
+		form := url.Values{"cookie": {cookie}}
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, fullURL, strings.NewReader(form.Encode()))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		return req, nil
+	default:
+		return http.NewRequestWithContext(ctx, http.MethodGet, fullURL+"&cookie="+url.QueryEscape(cookie), nil)
+	}
+}
+
+// isUpstreamFailure 判断错误是否说明上游不可用，用于熔断计数
+func isUpstreamFailure(err error) bool {
+	return isRetryable(err) || errors.Is(err, errUpstreamTimeout)
+}
+
+// isRetryable 仅网络错误和5xx响应可重试，4xx属于请求本身的问题；总时限已耗尽时不再重试
+func isRetryable(err error) bool {
+	return errors.Is(err, errUpstreamRequest) || errors.Is(err, errUpstreamBadStatus)
+}
+
+// retryDelay 计算第 attempt 次重试前的等待时间（指数退避 + 全抖动），
+// 在 [0, min(UPSTREAM_RETRY_MAX_MS, UPSTREAM_RETRY_BASE_MS*2^attempt)] 内随机
+func retryDelay(attempt int) time.Duration {
+	delay := config.Current().UpstreamRetryBase << attempt
+	if delay <= 0 || delay > config.Current().UpstreamRetryMax {
+		delay = config.Current().UpstreamRetryMax
+	}
+	if delay <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(delay) + 1))
+}
+
+// isTimeout 判断错误是否由超时引起
+func isTimeout(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// upstreamContextError 请求的 context 结束时返回对应的错误：客户端断开为 errUpstreamCanceled，否则为总时限耗尽
+func upstreamContextError(ctx context.Context) error {
+	if errors.Is(ctx.Err(), context.Canceled) {
+		return errUpstreamCanceled
+	}
+	return errUpstreamTimeout
+}
+
+// upstreamErrorStatus 将上游错误转换为HTTP状态码
+func upstreamErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, errUpstreamCanceled):
+		return statusClientClosedRequest
+	case errors.Is(err, errUpstreamTimeout):
+		return http.StatusGatewayTimeout
+	case errors.Is(err, errCircuitOpen):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, errUpstreamBusy):
+		return http.StatusTooManyRequests
+	case errors.Is(err, errUpstreamBadStatus), errors.Is(err, errUpstreamProxy),
+		errors.Is(err, errUpstreamRead), errors.Is(err, errUpstreamParse):
+		return http.StatusBadGateway
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// upstreamErrorMessage 将上游错误转换为返回给客户端的提示信息
+func upstreamErrorMessage(err error) string {
+	var nonJSON *netease.NonJSONError
+	switch {
+	case errors.As(err, &nonJSON):
+		return nonJSON.Error()
+	case errors.Is(err, errUpstreamCanceled):
+		return "Client closed request"
+	case errors.Is(err, errUpstreamTimeout):
+		return "Music service request timed out"
+	case errors.Is(err, errCircuitOpen):
+		return "upstream unavailable, circuit open"
+	case errors.Is(err, errUpstreamBusy):
+		return "too many concurrent upstream requests"
+	case errors.Is(err, errUpstreamProxy):
+		return "Failed to connect to music service through proxy"
+	case errors.Is(err, errUpstreamRequest):
+		return "Failed to request music service"
+	case errors.Is(err, errUpstreamBadStatus):
+		return "Music service is temporarily unavailable"
+	case errors.Is(err, errUpstreamRead):
+		return "Failed to read response from music service"
+	case errors.Is(err, errUpstreamParse):
+		return "Failed to parse response from music service"
+	default:
+		return "Internal server error"
+	}
+}
+
+// 上游因请求过于频繁拒绝时建议客户端等待的秒数
+const upstreamRateLimitRetryAfter = 60
+
+// upstreamCodeMapping 上游返回的非200 code 对应的HTTP状态码与提示信息
+type upstreamCodeMapping struct {
+	status  int
+	message string
+}
+
+// upstreamCodeMappings 上游 code 与HTTP状态码的对应关系，未列出的 code 视为上游故障，返回502
+var upstreamCodeMappings = map[int]upstreamCodeMapping{
+	301:  {http.StatusUnauthorized, "Music service requires login, the configured cookie is missing or expired"},
+	404:  {http.StatusNotFound, "Resource not found on music service"},
+	-460: {http.StatusForbidden, "Request blocked by music service risk control, setting realip to a mainland China IP may help"},
+	-462: {http.StatusForbidden, "Request blocked by music service risk control, setting realip to a mainland China IP may help"},
+	405:  {http.StatusTooManyRequests, "Music service rate limited the request, retry later"},
+	-110: {http.StatusTooManyRequests, "Music service rate limited the request, retry later"},
+}
+
+// upstreamStatus 上游响应中的 code 及出错时的说明
+type upstreamStatus struct {
+	Code    int
+	Message string
+}
+
+// upstreamCodeStatus 将上游返回的非200 code 转换为HTTP状态码与提示信息
+func upstreamCodeStatus(code int) (int, string) {
+	if m, ok := upstreamCodeMappings[code]; ok {
+		return m.status, m.message
+	}
+	return http.StatusBadGateway, "Music service returned error"
+}
+
+// upstreamCodeMessage 返回上游 code 对应的提示信息，用于批量结果中的单项错误
+func upstreamCodeMessage(code int) string {
+	_, message := upstreamCodeStatus(code)
+	return message
+}
@@ -0,0 +1,327 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"PMS/internal/api"
+	"PMS/internal/netease"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestGetSongURLUpstreamTimeout(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	transport := newTestUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		// 直到请求被取消或测试结束都不响应
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	}, map[string]string{
+		"UPSTREAM_TIMEOUT_SECONDS": "200ms",
+		"UPSTREAM_MAX_RETRIES":     "0",
+	})
+	s := NewSongURLService(netease.NewHTTPClient(transport))
+
+	start := time.Now()
+	w := serve(s.GetSongURL, http.MethodGet, "/song?id=1")
+	elapsed := time.Since(start)
+
+	if w.Code != http.StatusGatewayTimeout {
+		t.Fatalf("status = %d, want %d; body %s", w.Code, http.StatusGatewayTimeout, w.Body)
+	}
+	if resp := decodeBody[api.ErrorResponse](t, w); resp.Code != http.StatusGatewayTimeout || resp.Message != "Music service request timed out" {
+		t.Errorf("error response = %+v", resp)
+	}
+	if elapsed > time.Second {
+		t.Errorf("handler returned after %s, want close to UPSTREAM_TIMEOUT (200ms)", elapsed)
+	}
+}
+
+func TestUpstreamRetriesTransientFailures(t *testing.T) {
+	tests := []struct {
+		name      string
+		failures  int
+		status    int
+		retries   string
+		wantErr   error
+		wantCalls int32
+	}{
+		{name: "succeeds after retries", failures: 2, status: http.StatusBadGateway, retries: "2", wantCalls: 3},
+		{name: "gives up after max retries", failures: 3, status: http.StatusBadGateway, retries: "2", wantErr: errUpstreamBadStatus, wantCalls: 3},
+		{name: "retries disabled", failures: 1, status: http.StatusServiceUnavailable, retries: "0", wantErr: errUpstreamBadStatus, wantCalls: 1},
+		{name: "4xx is not retried", failures: 1, status: http.StatusBadRequest, retries: "2", wantCalls: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls atomic.Int32
+			transport := newTestUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+				if int(calls.Add(1)) <= tt.failures {
+					w.WriteHeader(tt.status)
+					writeJSON(w, map[string]int{"code": tt.status})
+					return
+				}
+				writeJSON(w, map[string]int{"code": 200})
+			}, map[string]string{
+				"UPSTREAM_MAX_RETRIES":   tt.retries,
+				"UPSTREAM_RETRY_BASE_MS": "1",
+				"UPSTREAM_RETRY_MAX_MS":  "5",
+				"CB_FAILURE_THRESHOLD":   "100",
+			})
+
+			_, err := transport.Get(context.Background(), netease.URL("/song/url/v1", url.Values{}, "", ""))
+			if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+				t.Errorf("Get error = %v, want %v", err, tt.wantErr)
+			}
+			if got := calls.Load(); got != tt.wantCalls {
+				t.Errorf("upstream calls = %d, want %d", got, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestUpstreamRetriesRespectTotalTimeout(t *testing.T) {
+	transport := newTestUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}, map[string]string{
+		"UPSTREAM_TIMEOUT_SECONDS": "300ms",
+		"UPSTREAM_MAX_RETRIES":     "100",
+		"UPSTREAM_RETRY_BASE_MS":   "50",
+		"UPSTREAM_RETRY_MAX_MS":    "50",
+		"CB_FAILURE_THRESHOLD":     "1000",
+	})
+
+	start := time.Now()
+	_, err := transport.Get(context.Background(), netease.URL("/song/url/v1", url.Values{}, "", ""))
+	if !errors.Is(err, errUpstreamTimeout) {
+		t.Errorf("Get error = %v, want %v", err, errUpstreamTimeout)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("retries took %s, want them bounded by UPSTREAM_TIMEOUT (300ms)", elapsed)
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	useTestConfig(t, map[string]string{
+		"UPSTREAM_RETRY_BASE_MS": "100",
+		"UPSTREAM_RETRY_MAX_MS":  "1000",
+	})
+
+	for attempt, ceiling := range []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond, 800 * time.Millisecond, time.Second, time.Second} {
+		for range 50 {
+			if delay := retryDelay(attempt); delay < 0 || delay > ceiling {
+				t.Fatalf("retryDelay(%d) = %s, want within [0, %s]", attempt, delay, ceiling)
+			}
+		}
+	}
+}
+
+func TestUpstreamCookieMode(t *testing.T) {
+	const cookie = "MUSIC_U=secret-token"
+	tests := []struct {
+		mode       string
+		wantMethod string
+		wantQuery  string
+		wantHeader string
+		wantForm   string
+	}{
+		{mode: "query", wantMethod: http.MethodGet, wantQuery: cookie},
+		{mode: "header", wantMethod: http.MethodGet, wantHeader: cookie},
+		{mode: "post", wantMethod: http.MethodPost, wantForm: cookie},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			var got *http.Request
+			transport := newTestUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+				r.ParseForm()
+				got = r
+				writeJSON(w, map[string]int{"code": 200})
+			}, map[string]string{"UPSTREAM_COOKIE_MODE": tt.mode})
+			useCookie(t, cookie)
+
+			if _, err := netease.NewHTTPClient(transport).SongURL(context.Background(), 1, "standard", ""); err != nil {
+				t.Fatalf("SongURL: %v", err)
+			}
+			if got.Method != tt.wantMethod {
+				t.Errorf("method = %s, want %s", got.Method, tt.wantMethod)
+			}
+			if q := got.URL.Query().Get("cookie"); q != tt.wantQuery {
+				t.Errorf("cookie query parameter = %q, want %q", q, tt.wantQuery)
+			}
+			if tt.wantQuery == "" && strings.Contains(got.URL.RawQuery, "secret-token") {
+				t.Errorf("request URL %q contains the cookie", got.URL)
+			}
+			if h := got.Header.Get("Cookie"); h != tt.wantHeader {
+				t.Errorf("Cookie header = %q, want %q", h, tt.wantHeader)
+			}
+			if f := got.PostForm.Get("cookie"); f != tt.wantForm {
+				t.Errorf("cookie form value = %q, want %q", f, tt.wantForm)
+			}
+			if got.URL.Query().Get("id") != "1" || got.URL.Query().Get("level") != "standard" {
+				t.Errorf("request parameters = %q, want id and level kept", got.URL.RawQuery)
+			}
+		})
+	}
+}
+
+func TestBuildUpstreamRequestHeaderModeAddsCookieName(t *testing.T) {
+	useTestConfig(t, map[string]string{"UPSTREAM_COOKIE_MODE": "header"})
+
+	req, err := buildUpstreamRequest(context.Background(), "http://upstream/song/url/v1?id=1", "bare-value")
+	if err != nil {
+		t.Fatalf("buildUpstreamRequest: %v", err)
+	}
+	if h := req.Header.Get("Cookie"); h != "MUSIC_U=bare-value" {
+		t.Errorf("Cookie header = %q, want MUSIC_U=bare-value", h)
+	}
+}
+
+func TestBuildUpstreamRequestWithoutCookie(t *testing.T) {
+	for _, mode := range []string{"query", "header", "post"} {
+		useTestConfig(t, map[string]string{"UPSTREAM_COOKIE_MODE": mode})
+
+		req, err := buildUpstreamRequest(context.Background(), "http://upstream/song/url/v1?id=1", "")
+		if err != nil {
+			t.Fatalf("%s: buildUpstreamRequest: %v", mode, err)
+		}
+		if req.Method != http.MethodGet || req.Header.Get("Cookie") != "" || req.URL.Query().Has("cookie") {
+			t.Errorf("%s: anonymous request = %s %s with Cookie %q, want a plain GET", mode, req.Method, req.URL, req.Header.Get("Cookie"))
+		}
+	}
+}
+
+func TestGetSongURLClientCancel(t *testing.T) {
+	var calls atomic.Int32
+	started := make(chan struct{}, 1)
+	aborted := make(chan struct{}, 1)
+	transport := newTestUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		started <- struct{}{}
+		<-r.Context().Done()
+		aborted <- struct{}{}
+	}, map[string]string{
+		"UPSTREAM_TIMEOUT_SECONDS": "5",
+		"UPSTREAM_MAX_RETRIES":     "2",
+		"CB_FAILURE_THRESHOLD":     "1",
+	})
+	useResponseCache(t, nil)
+	s := NewSongURLService(netease.NewHTTPClient(transport))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-started
+		cancel()
+	}()
+	r := gin.New()
+	r.GET("/song", s.GetSongURL)
+	w := httptest.NewRecorder()
+	start := time.Now()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/song?id=1", nil).WithContext(ctx))
+
+	if w.Code != statusClientClosedRequest || w.Body.Len() != 0 {
+		t.Errorf("status = %d with body %q, want %d and no body", w.Code, w.Body, statusClientClosedRequest)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("handler returned after %s, want it to stop as soon as the client disconnects", elapsed)
+	}
+	select {
+	case <-aborted:
+	case <-time.After(time.Second):
+		t.Fatal("upstream request was not canceled")
+	}
+	if got := calls.Load(); got != 1 {
+		t.Errorf("upstream calls = %d, want no retries after the client disconnects", got)
+	}
+	// 客户端断开与上游是否健康无关，不计入熔断与冷却
+	target := transport.targets[0]
+	if state := target.breaker.State(); state != circuitClosed {
+		t.Errorf("circuit state = %v, want closed", state)
+	}
+	if !target.healthy() || target.errors.Load() != 0 {
+		t.Errorf("upstream marked failed (errors %d) after a client disconnect", target.errors.Load())
+	}
+}
+
+func TestUpstreamCodeStatus(t *testing.T) {
+	tests := []struct {
+		code           int
+		wantStatus     int
+		wantRetryAfter string
+	}{
+		{code: 301, wantStatus: http.StatusUnauthorized},
+		{code: 404, wantStatus: http.StatusNotFound},
+		{code: -460, wantStatus: http.StatusForbidden},
+		{code: -462, wantStatus: http.StatusForbidden},
+		{code: 405, wantStatus: http.StatusTooManyRequests, wantRetryAfter: "60"},
+		{code: -110, wantStatus: http.StatusTooManyRequests, wantRetryAfter: "60"},
+		{code: 400, wantStatus: http.StatusBadGateway},
+		{code: 500, wantStatus: http.StatusBadGateway},
+		{code: -1, wantStatus: http.StatusBadGateway},
+	}
+	for _, tt := range tests {
+		handler := func(c *gin.Context) { respondUpstreamCode(c, tt.code, "upstream says no") }
+		w := serve(handler, http.MethodGet, "/song")
+
+		if w.Code != tt.wantStatus {
+			t.Errorf("code %d: status = %d, want %d", tt.code, w.Code, tt.wantStatus)
+		}
+		if got := w.Header().Get("Retry-After"); got != tt.wantRetryAfter {
+			t.Errorf("code %d: Retry-After = %q, want %q", tt.code, got, tt.wantRetryAfter)
+		}
+		resp := decodeBody[api.ErrorResponse](t, w)
+		if _, message := upstreamCodeStatus(tt.code); resp.Code != tt.wantStatus || resp.Message != message {
+			t.Errorf("code %d: error response = %+v, want code %d and %q", tt.code, resp, tt.wantStatus, message)
+		}
+		if resp.UpstreamCode != tt.code || resp.UpstreamMessage != "upstream says no" {
+			t.Errorf("code %d: upstream fields = %d %q, want the upstream code and message", tt.code, resp.UpstreamCode, resp.UpstreamMessage)
+		}
+	}
+}
+
+func TestUpstreamErrorStatus(t *testing.T) {
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{errUpstreamTimeout, http.StatusGatewayTimeout},
+		{errCircuitOpen, http.StatusServiceUnavailable},
+		{errUpstreamBusy, http.StatusTooManyRequests},
+		{errUpstreamBadStatus, http.StatusBadGateway},
+		{errUpstreamProxy, http.StatusBadGateway},
+		{errUpstreamRead, http.StatusBadGateway},
+		{errUpstreamParse, http.StatusBadGateway},
+		{&netease.NonJSONError{Status: 200}, http.StatusBadGateway},
+		{errUpstreamCanceled, statusClientClosedRequest},
+	}
+	for _, tt := range tests {
+		if got := upstreamErrorStatus(tt.err); got != tt.want {
+			t.Errorf("upstreamErrorStatus(%v) = %d, want %d", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestUpstreamNonJSONResponse(t *testing.T) {
+	transport := newTestUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte("<html><body>502 Bad Gateway</body></html>"))
+	}, map[string]string{"UPSTREAM_MAX_RETRIES": "0"})
+
+	_, err := transport.Get(context.Background(), netease.URL("/song/url/v1", url.Values{}, "", ""))
+	var nonJSON *netease.NonJSONError
+	if !errors.As(err, &nonJSON) || nonJSON.Status != http.StatusOK {
+		t.Fatalf("error = %v, want a NonJSONError with status 200", err)
+	}
+	if got := upstreamErrorMessage(err); got != "upstream returned non-JSON (status 200)" {
+		t.Errorf("message = %q", got)
+	}
+}
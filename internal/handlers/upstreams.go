@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"sync/atomic"
+	"time"
+
+	"PMS/internal/config"
+	"PMS/internal/logging"
+	"PMS/internal/metrics"
+
+	"github.com/gin-gonic/gin"
+)
+
+// upstreamTarget 一个上游API实例及其熔断器、错误计数与冷却截止时间
+type upstreamTarget struct {
+	base    string
+	breaker *circuitBreaker
+	errors  atomic.Uint64
+	// 最近一次失败后的冷却截止时间 (UnixNano)，冷却期间排到其他实例之后尝试
+	cooldownUntil atomic.Int64
+}
+
+func newUpstreamTargets(bases []string, threshold int, openDuration time.Duration) []*upstreamTarget {
+	targets := make([]*upstreamTarget, 0, len(bases))
+	for _, base := range bases {
+		targets = append(targets, &upstreamTarget{
+			base:    base,
+			breaker: newCircuitBreaker(base, threshold, openDuration),
+		})
+		metrics.SetUpstreamHealthy(base, true)
+	}
+	metrics.SetActiveUpstream(bases, 0)
+	return targets
+}
+
+// healthy 判断实例是否不在冷却期
+func (t *upstreamTarget) healthy() bool {
+	return time.Now().UnixNano() >= t.cooldownUntil.Load()
+}
+
+// markFailed 记录一次失败，使实例在 UPSTREAM_COOLDOWN 内排到最后
+func (t *upstreamTarget) markFailed() {
+	t.errors.Add(1)
+	cooldown := config.Current().UpstreamCooldown
+	if cooldown <= 0 {
+		return
+	}
+	if t.healthy() {
+		logging.Logger.Warn("upstream marked unhealthy", "upstream_url", t.base, "cooldown", cooldown.String())
+	}
+	t.cooldownUntil.Store(time.Now().Add(cooldown).UnixNano())
+	metrics.SetUpstreamHealthy(t.base, false)
+}
+
+// markSucceeded 请求成功后立即结束冷却
+func (t *upstreamTarget) markSucceeded() {
+	if t.cooldownUntil.Swap(0) != 0 {
+		metrics.SetUpstreamHealthy(t.base, true)
+	}
+}
+
+// order 返回本次请求尝试各实例的顺序：priority 策略从主实例开始，round-robin 策略轮流选取首选实例；
+// 冷却中的实例排在健康实例之后，全部冷却时仍会依次尝试
+func (t *UpstreamTransport) order() []int {
+	n := len(t.targets)
+	start := 0
+	if config.Current().UpstreamStrategy == config.UpstreamStrategyRoundRobin && n > 1 {
+		start = int((t.next.Add(1) - 1) % uint64(n))
+	}
+
+	order := make([]int, 0, n)
+	var cooling []int
+	for k := 0; k < n; k++ {
+		i := (start + k) % n
+		if t.targets[i].healthy() {
+			order = append(order, i)
+		} else {
+			cooling = append(cooling, i)
+		}
+	}
+	return append(order, cooling...)
+}
+
+// setActive 记录最近一次成功请求所用的实例，变化时更新指标
+func (t *UpstreamTransport) setActive(i int) {
+	if int(t.active.Swap(int32(i))) == i {
+		return
+	}
+	bases := make([]string, len(t.targets))
+	for k, target := range t.targets {
+		bases[k] = target.base
+	}
+	metrics.SetActiveUpstream(bases, i)
+}
+
+// attemptTimeout 返回单个实例的超时时间，超时后切换到下一个实例；为0表示共用总时限
+func (t *UpstreamTransport) attemptTimeout() time.Duration {
+	cfg := config.Current()
+	if cfg.UpstreamAttemptTimeout > 0 {
+		return cfg.UpstreamAttemptTimeout
+	}
+	if n := len(t.targets); n > 1 {
+		return cfg.UpstreamTimeout / time.Duration(n)
+	}
+	return 0
+}
+
+// circuitState 汇总各实例的熔断状态：任一实例闭合即为 closed，全部熔断才为 open
+func (t *UpstreamTransport) circuitState() circuitState {
+	state := circuitOpen
+	for _, target := range t.targets {
+		switch target.breaker.State() {
+		case circuitClosed:
+			return circuitClosed
+		case circuitHalfOpen:
+			state = circuitHalfOpen
+		}
+	}
+	return state
+}
+
+// targetsHealth 返回 /admin/health 中各上游实例的状态
+func (t *UpstreamTransport) targetsHealth() []gin.H {
+	health := make([]gin.H, 0, len(t.targets))
+	active := int(t.active.Load())
+	for i, target := range t.targets {
+		entry := gin.H{
+			"url":           target.base,
+			"errors":        target.errors.Load(),
+			"circuit_state": target.breaker.State(),
+			"healthy":       target.healthy(),
+			"active":        i == active,
+		}
+		if !target.healthy() {
+			entry["cooldown_until"] = time.Unix(0, target.cooldownUntil.Load()).UTC().Format(time.RFC3339)
+		}
+		health = append(health, entry)
+	}
+	return health
+}
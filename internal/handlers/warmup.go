@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"context"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"PMS/internal/config"
+	"PMS/internal/logging"
+)
+
+// WARMUP_BLOCK_READY=true 时缓存预热完成前为 true，此时 /ready 与 /readyz 返回503
+var warmupPending atomic.Bool
+
+// StartCacheWarmup 在后台按 LEVEL 预先获取 ids 的播放地址并写入缓存，超过 timeout 后放弃剩余的歌曲；
+// 上游请求与普通请求一样受 BATCH_CONCURRENCY 与 UPSTREAM_MAX_CONCURRENT 限制。
+// blockReady 为 true 时在返回前标记为未就绪，预热结束 (含超时) 后恢复
+func (s *SongURLService) StartCacheWarmup(ids []int64, timeout time.Duration, blockReady bool) {
+	if len(ids) == 0 || responseCache == nil {
+		return
+	}
+	warmupPending.Store(blockReady)
+	go func() {
+		defer warmupPending.Store(false)
+		s.warmCache(ids, timeout)
+	}()
+}
+
+func (s *SongURLService) warmCache(ids []int64, timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = strconv.FormatInt(id, 10)
+	}
+	level, realIP := config.Current().Level, config.Current().RealIP
+	start := time.Now()
+	logging.Logger.Info("cache warmup started", "songs", len(ids), "level", level, "timeout", timeout.String())
+
+	var warmed atomic.Int64
+	fanOutSongIDs(ctx, keys, func(songID int64) bool {
+		songResp, cached, err := s.resolve(ctx, songID, level, realIP, false, true)
+		switch {
+		case err != nil:
+			logging.Logger.Warn("cache warmup failed", "song_id", songID, "error", upstreamErrorMessage(err))
+			return false
+		case songResp.Code != 200:
+			logging.Logger.Warn("cache warmup failed", "song_id", songID, "error", upstreamCodeMessage(songResp.Code))
+			return false
+		}
+		warmed.Add(1)
+		logging.Logger.Info("cache warmup entry", "song_id", songID, "served_level", songResp.ServedLevel, "cache", string(cached), "playable", hasPlayableURL(songResp))
+		return true
+	}, func(message string) bool {
+		return false
+	})
+
+	if ctx.Err() != nil {
+		logging.Logger.Warn("cache warmup timed out", "songs", len(ids), "warmed", warmed.Load(), "timeout", timeout.String())
+		return
+	}
+	logging.Logger.Info("cache warmup finished", "songs", len(ids), "warmed", warmed.Load(), "duration", time.Since(start).String())
+}
@@ -0,0 +1,386 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"PMS/internal/api"
+	"PMS/internal/logging"
+	"PMS/internal/metrics"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	// 投递失败后的最大重试次数，重试间隔从 webhookRetryBaseDelay 开始依次翻倍
+	webhookMaxRetries     = 3
+	webhookRetryBaseDelay = time.Second
+	// 单次投递的超时时间
+	webhookDeliveryTimeout = 10 * time.Second
+	// 待投递队列长度与并发投递的 goroutine 数量
+	webhookQueueSize = 256
+	webhookWorkers   = 4
+	// 单个webhook可订阅的歌曲ID数量与回调地址长度上限
+	webhookMaxIDs    = 1000
+	webhookMaxURLLen = 2048
+)
+
+// 可订阅的事件类型
+var webhookEventTypes = []string{"song_expired"}
+
+// errWebhookPrivateAddress 回调地址解析到内网或本机地址，WEBHOOKS_ALLOW_PRIVATE=true 时允许
+var errWebhookPrivateAddress = errors.New("webhook url resolves to a private or loopback address")
+
+// WebhookRegisterRequest POST /webhooks/register 的请求体，ids 为空表示接收全部歌曲的事件，
+// secret 为空时由PMS生成
+type WebhookRegisterRequest struct {
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+	IDs    []int64  `json:"ids"`
+	Secret string   `json:"secret"`
+}
+
+// WebhookInfo webhook的注册信息与最近一次投递的结果，不包含签名密钥
+type WebhookInfo struct {
+	ID        string   `json:"id"`
+	URL       string   `json:"url"`
+	Events    []string `json:"events"`
+	IDs       []int64  `json:"ids,omitempty"`
+	CreatedAt string   `json:"created_at"`
+	// 最近一次投递：ok、failed，尚未投递时为空
+	LastStatus     string `json:"last_status,omitempty"`
+	LastStatusCode int    `json:"last_status_code,omitempty"`
+	LastError      string `json:"last_error,omitempty"`
+	LastDeliveryAt string `json:"last_delivery_at,omitempty"`
+	Deliveries     int    `json:"deliveries"`
+	Failures       int    `json:"failures"`
+}
+
+// WebhookRegisterResponse 注册成功的响应，secret 只在此时返回一次
+type WebhookRegisterResponse struct {
+	Code int `json:"code"`
+	WebhookInfo
+	Secret string `json:"secret"`
+}
+
+// webhookPayload 投递的请求体，X-PMS-Signature 为请求体以 secret 计算的 HMAC-SHA256
+type webhookPayload struct {
+	songEvent
+	WebhookID string `json:"webhook_id"`
+	Timestamp int64  `json:"timestamp"`
+}
+
+type webhook struct {
+	info   WebhookInfo
+	ids    map[int64]bool
+	secret string
+}
+
+func (w *webhook) wants(ev songEvent) bool {
+	return slices.Contains(w.info.Events, ev.Type) && (len(w.ids) == 0 || w.ids[ev.ID])
+}
+
+type webhookDelivery struct {
+	hook  *webhook
+	event string
+	body  []byte
+}
+
+// WebhookRegistry 保存注册的webhook (仅在进程内，重启后需重新注册)，事件由后台 goroutine 投递
+type WebhookRegistry struct {
+	mu     sync.Mutex
+	hooks  map[string]*webhook
+	max    int
+	queue  chan webhookDelivery
+	client *http.Client
+}
+
+// 全局webhook注册表，WEBHOOKS_ENABLED=false 时为 nil
+var webhooks *WebhookRegistry
+
+// newWebhookRegistry 创建最多保存 max 个webhook的注册表；allowPrivate 为 false 时拒绝投递到内网与本机地址
+func newWebhookRegistry(max int, allowPrivate bool) *WebhookRegistry {
+	dialer := &net.Dialer{Timeout: 5 * time.Second}
+	if !allowPrivate {
+		// 在连接时检查实际解析出的地址，注册后域名改为解析到内网地址也会被拒绝
+		dialer.Control = func(_, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || isPrivateAddress(ip) {
+				return errWebhookPrivateAddress
+			}
+			return nil
+		}
+	}
+	return &WebhookRegistry{
+		hooks: make(map[string]*webhook),
+		max:   max,
+		queue: make(chan webhookDelivery, webhookQueueSize),
+		client: &http.Client{
+			Timeout:   webhookDeliveryTimeout,
+			Transport: &http.Transport{DialContext: dialer.DialContext, MaxIdleConnsPerHost: 2},
+			// 不跟随重定向，避免绕过地址检查
+			CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+		},
+	}
+}
+
+func isPrivateAddress(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast()
+}
+
+// Run 启动 webhookWorkers 个投递 goroutine，ctx 结束后返回
+func (r *WebhookRegistry) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for range webhookWorkers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case d := <-r.queue:
+					r.deliver(ctx, d)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// publish 将事件加入订阅了该事件的webhook的投递队列，队列已满时丢弃并记为失败
+func (r *WebhookRegistry) publish(ev songEvent) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, hook := range r.hooks {
+		if !hook.wants(ev) {
+			continue
+		}
+		body, err := json.Marshal(webhookPayload{songEvent: ev, WebhookID: hook.info.ID, Timestamp: time.Now().Unix()})
+		if err != nil {
+			continue
+		}
+		select {
+		case r.queue <- webhookDelivery{hook: hook, event: ev.Type, body: body}:
+		default:
+			metrics.ObserveWebhookDelivery("dropped")
+			r.recordLocked(hook, 0, errors.New("delivery queue is full"))
+			logging.Logger.Warn("webhook delivery queue is full, dropping event", "webhook_id", hook.info.ID, "song_id", ev.ID)
+		}
+	}
+}
+
+// deliver 投递一次事件，失败 (连接错误或非2xx) 时按指数退避重试至多 webhookMaxRetries 次
+func (r *WebhookRegistry) deliver(ctx context.Context, d webhookDelivery) {
+	mac := hmac.New(sha256.New, []byte(d.hook.secret))
+	mac.Write(d.body)
+	signature := "hmac-sha256=" + hex.EncodeToString(mac.Sum(nil))
+
+	delay := webhookRetryBaseDelay
+	var status int
+	var err error
+	for attempt := 0; ; attempt++ {
+		status, err = r.post(ctx, d, signature)
+		if err == nil || attempt == webhookMaxRetries {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+
+	if err != nil {
+		metrics.ObserveWebhookDelivery("failed")
+		logging.Logger.Warn("webhook delivery failed", "webhook_id", d.hook.info.ID, "url", d.hook.info.URL, "attempts", webhookMaxRetries+1, "error", err)
+	} else {
+		metrics.ObserveWebhookDelivery("ok")
+	}
+	r.mu.Lock()
+	r.recordLocked(d.hook, status, err)
+	r.mu.Unlock()
+}
+
+func (r *WebhookRegistry) post(ctx context.Context, d webhookDelivery, signature string) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.hook.info.URL, bytes.NewReader(d.body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "PMS-Webhook/1.0")
+	req.Header.Set("X-PMS-Event", d.event)
+	req.Header.Set("X-PMS-Webhook-ID", d.hook.info.ID)
+	req.Header.Set("X-PMS-Signature", signature)
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// recordLocked 记录最近一次投递的结果，调用方需持有 mu
+func (r *WebhookRegistry) recordLocked(hook *webhook, status int, err error) {
+	hook.info.Deliveries++
+	hook.info.LastStatusCode = status
+	hook.info.LastDeliveryAt = time.Now().UTC().Format(time.RFC3339)
+	hook.info.LastStatus, hook.info.LastError = "ok", ""
+	if err != nil {
+		hook.info.Failures++
+		hook.info.LastStatus, hook.info.LastError = "failed", err.Error()
+	}
+}
+
+// RegisterWebhook 处理 POST /webhooks/register，注册后事件以POST投递到 url
+func RegisterWebhook(c *gin.Context) {
+	var req WebhookRegisterRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, api.NewErrorResponse(c, 400, "Invalid request body"))
+		return
+	}
+	if msg := validateWebhookRequest(&req); msg != "" {
+		c.JSON(http.StatusBadRequest, api.NewErrorResponse(c, 400, msg))
+		return
+	}
+
+	secret := req.Secret
+	if secret == "" {
+		buf := make([]byte, 32)
+		rand.Read(buf)
+		secret = hex.EncodeToString(buf)
+	}
+	hook := &webhook{
+		info: WebhookInfo{
+			ID:        uuid.NewString(),
+			URL:       req.URL,
+			Events:    req.Events,
+			IDs:       req.IDs,
+			CreatedAt: time.Now().UTC().Format(time.RFC3339),
+		},
+		ids:    make(map[int64]bool, len(req.IDs)),
+		secret: secret,
+	}
+	for _, id := range req.IDs {
+		hook.ids[id] = true
+	}
+
+	webhooks.mu.Lock()
+	if len(webhooks.hooks) >= webhooks.max {
+		webhooks.mu.Unlock()
+		c.JSON(http.StatusServiceUnavailable, api.NewErrorResponse(c, 503, fmt.Sprintf("Too many webhooks registered, at most %d are allowed", webhooks.max)))
+		return
+	}
+	webhooks.hooks[hook.info.ID] = hook
+	info := hook.info
+	webhooks.mu.Unlock()
+
+	logging.From(c.Request.Context()).Info("webhook registered", "webhook_id", info.ID, "url", info.URL, "events", info.Events, "ids", len(info.IDs))
+	c.JSON(http.StatusCreated, WebhookRegisterResponse{Code: 201, WebhookInfo: info, Secret: secret})
+}
+
+// validateWebhookRequest 校验并规范化注册请求，返回错误提示，合法时返回空字符串
+func validateWebhookRequest(req *WebhookRegisterRequest) string {
+	if req.URL == "" {
+		return "Missing required field: url"
+	}
+	u, err := url.Parse(req.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || len(req.URL) > webhookMaxURLLen {
+		return "Invalid url, must be an absolute http or https URL"
+	}
+
+	if len(req.Events) == 0 {
+		return "Missing required field: events"
+	}
+	var events []string
+	for _, event := range req.Events {
+		if !slices.Contains(webhookEventTypes, event) {
+			return fmt.Sprintf("Unknown event %q, valid events are %s", event, strings.Join(webhookEventTypes, ", "))
+		}
+		if !slices.Contains(events, event) {
+			events = append(events, event)
+		}
+	}
+	req.Events = events
+
+	if len(req.IDs) > webhookMaxIDs {
+		return fmt.Sprintf("Too many ids, at most %d are allowed", webhookMaxIDs)
+	}
+	for _, id := range req.IDs {
+		if id <= 0 {
+			return "Invalid song id " + strconv.FormatInt(id, 10) + ", must be a positive number"
+		}
+	}
+	slices.Sort(req.IDs)
+	req.IDs = slices.Compact(req.IDs)
+	return ""
+}
+
+// ListWebhooks 处理 GET /webhooks，按注册时间列出webhook及最近一次投递的结果
+func ListWebhooks(c *gin.Context) {
+	webhooks.mu.Lock()
+	list := make([]WebhookInfo, 0, len(webhooks.hooks))
+	for _, hook := range webhooks.hooks {
+		list = append(list, hook.info)
+	}
+	webhooks.mu.Unlock()
+
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].CreatedAt != list[j].CreatedAt {
+			return list[i].CreatedAt < list[j].CreatedAt
+		}
+		return list[i].ID < list[j].ID
+	})
+	c.JSON(http.StatusOK, gin.H{"code": 200, "webhooks": list})
+}
+
+// DeleteWebhook 处理 DELETE /webhooks/:id，已在队列中的投递仍会完成
+func DeleteWebhook(c *gin.Context) {
+	id := c.Param("id")
+	webhooks.mu.Lock()
+	_, ok := webhooks.hooks[id]
+	delete(webhooks.hooks, id)
+	webhooks.mu.Unlock()
+
+	if !ok {
+		c.JSON(http.StatusNotFound, api.NewErrorResponse(c, 404, "Webhook not found"))
+		return
+	}
+	logging.From(c.Request.Context()).Info("webhook deleted via admin API", "webhook_id", id)
+	c.JSON(http.StatusOK, gin.H{"code": 200, "deleted": id})
+}
+
+// RunWebhookDelivery 在后台投递webhook事件，WEBHOOKS_ENABLED=false 时直接返回
+func RunWebhookDelivery(ctx context.Context) {
+	if webhooks != nil {
+		webhooks.Run(ctx)
+	}
+}
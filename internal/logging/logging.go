@@ -0,0 +1,171 @@
+// Package logging 提供全局结构化日志与请求ID上下文，并对日志中的敏感值做脱敏
+package logging
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// 当前日志级别，可在运行时通过 SetLevel 修改
+var logLevel = new(slog.LevelVar)
+
+// Logger 全局结构化日志，默认输出JSON行；在 Init 之前使用默认级别
+var Logger = newLogger("json")
+
+// secretValues 返回需要在日志中整体隐藏的值，由 SetSecretValues 注册
+var secretValues func() []string
+
+// 日志中需要隐藏值的参数与请求头
+var (
+	secretParamPattern  = regexp.MustCompile(`(?i)\b(cookie|MUSIC_U|__csrf|api_key|key)=[^&\s;"]*`)
+	secretHeaderPattern = regexp.MustCompile(`(?i)\b(authorization)(["':=\s]+)(bearer\s+)?[^\s",]+`)
+)
+
+// 单独作为字段记录时整体隐藏的键
+var secretAttrKeys = map[string]bool{
+	"authorization": true,
+	"cookie_value":  true,
+	"admin_token":   true,
+	"x-api-key":     true,
+}
+
+func newLogger(format string) *slog.Logger {
+	opts := &slog.HandlerOptions{
+		Level:       logLevel,
+		ReplaceAttr: replaceLogAttr,
+	}
+	if format == "text" {
+		return slog.New(slog.NewTextHandler(os.Stdout, opts))
+	}
+	return slog.New(slog.NewJSONHandler(os.Stdout, opts))
+}
+
+// replaceLogAttr 统一字段名便于日志平台解析，并对所有字符串与错误字段做脱敏
+func replaceLogAttr(groups []string, a slog.Attr) slog.Attr {
+	if len(groups) == 0 {
+		switch a.Key {
+		case slog.TimeKey:
+			a.Key = "timestamp"
+			return a
+		case slog.MessageKey:
+			a.Key = "message"
+		}
+	}
+
+	if secretAttrKeys[strings.ToLower(a.Key)] {
+		return slog.String(a.Key, "REDACTED")
+	}
+	switch a.Value.Kind() {
+	case slog.KindString:
+		return slog.String(a.Key, redactSecrets(a.Value.String()))
+	case slog.KindAny:
+		if err, ok := a.Value.Any().(error); ok {
+			return slog.String(a.Key, redactSecrets(err.Error()))
+		}
+	}
+	return a
+}
+
+// SetSecretValues 注册返回敏感值 (如当前Cookie池中的Cookie) 的函数，需在处理请求前调用
+func SetSecretValues(fn func() []string) {
+	secretValues = fn
+}
+
+// redactSecrets 隐藏文本中的cookie、API Key、Authorization 等敏感值，
+// 并替换 SetSecretValues 注册的每个值的原文，防止其通过错误信息等途径写入日志
+func redactSecrets(text string) string {
+	text = secretParamPattern.ReplaceAllString(text, "$1=REDACTED")
+	text = secretHeaderPattern.ReplaceAllString(text, "${1}${2}REDACTED")
+	if secretValues != nil {
+		for _, value := range secretValues() {
+			// 过短的值替换会误伤普通文本，真实Cookie远长于此
+			if len(value) >= 16 {
+				text = strings.ReplaceAll(text, value, "REDACTED")
+			}
+		}
+	}
+	return text
+}
+
+// Init 按 LOG_LEVEL 与 LOG_FORMAT (json/text) 初始化日志，标准库 log 的输出也会经过该日志
+func Init(levelName, format string) error {
+	level, err := parseLogLevel(levelName)
+	if err == nil && format != "" && format != "json" && format != "text" {
+		err = fmt.Errorf("invalid LOG_FORMAT %q, must be json or text", format)
+	}
+	if format != "text" {
+		format = "json"
+	}
+	logLevel.Set(level)
+	Logger = newLogger(format)
+	slog.SetDefault(Logger)
+	return err
+}
+
+// SetLevel 在运行时修改日志级别，级别无法识别时保持不变并返回错误
+func SetLevel(name string) error {
+	level, err := parseLogLevel(name)
+	if err != nil {
+		return err
+	}
+	logLevel.Set(level)
+	return nil
+}
+
+// ValidateLevel 检查日志级别名称能否被 SetLevel 识别
+func ValidateLevel(name string) error {
+	_, err := parseLogLevel(name)
+	return err
+}
+
+// Level 返回当前日志级别的名称，如 info
+func Level() string {
+	return strings.ToLower(logLevel.Level().String())
+}
+
+// parseLogLevel 解析 debug/info/warn/error，无法识别时返回 info 与错误
+func parseLogLevel(name string) (slog.Level, error) {
+	switch strings.ToLower(name) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "", "info":
+		return slog.LevelInfo, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	default:
+		return slog.LevelInfo, fmt.Errorf("invalid LOG_LEVEL %q, must be one of: debug, info, warn, error", name)
+	}
+}
+
+// Fatal 记录错误日志后退出进程
+func Fatal(msg string, args ...any) {
+	Logger.Error(msg, args...)
+	os.Exit(1)
+}
+
+// From 返回附带当前请求ID的日志
+func From(ctx context.Context) *slog.Logger {
+	if id := RequestID(ctx); id != "" {
+		return Logger.With("request_id", id)
+	}
+	return Logger
+}
+
+type requestIDKey struct{}
+
+// WithRequestID 返回携带请求ID的上下文
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID 返回请求上下文中的请求ID，不存在时返回空字符串
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
@@ -0,0 +1,229 @@
+// Package metrics 定义PMS的Prometheus指标
+package metrics
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"PMS/internal/api"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// 缓存命中统计，与具体后端无关
+var (
+	CacheHits   atomic.Uint64
+	CacheMisses atomic.Uint64
+	// 返回过期缓存的次数，按原因细分的计数见 pms_cache_stale_serves_total
+	CacheStaleServes atomic.Uint64
+)
+
+var (
+	httpRequestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "pms_http_requests_total",
+		Help: "Total number of HTTP requests handled by PMS.",
+	}, []string{"endpoint", "status"})
+
+	httpRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "pms_http_request_duration_seconds",
+		Help:    "Duration of HTTP requests handled by PMS.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"endpoint", "status"})
+
+	httpRequestsInFlight = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "pms_http_requests_in_flight",
+		Help: "Number of HTTP requests currently being handled by PMS.",
+	})
+
+	upstreamRequestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "pms_upstream_requests_total",
+		Help: "Total number of requests sent to the upstream music API.",
+	}, []string{"endpoint", "status"})
+
+	upstreamRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "pms_upstream_request_duration_seconds",
+		Help:    "Duration of requests sent to the upstream music API.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"endpoint"})
+
+	upstreamRetriesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "pms_upstream_retries_total",
+		Help: "Total number of upstream retries, labelled by retry attempt number.",
+	}, []string{"endpoint", "attempt"})
+
+	upstreamHealthy = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "pms_upstream_healthy",
+		Help: "Whether each upstream music API instance is healthy (1) or cooling down after a failure (0).",
+	}, []string{"upstream"})
+
+	upstreamActive = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "pms_upstream_active",
+		Help: "1 for the upstream music API instance that served the most recent successful request, 0 for the others.",
+	}, []string{"upstream"})
+
+	upstreamConcurrent = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "pms_upstream_concurrent_requests",
+		Help: "Number of upstream requests currently holding a slot of UPSTREAM_MAX_CONCURRENT.",
+	})
+
+	upstreamConcurrencyWaiting = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "pms_upstream_concurrency_waiting",
+		Help: "Number of upstream requests currently waiting for a free slot of UPSTREAM_MAX_CONCURRENT.",
+	})
+
+	upstreamConcurrencyWait = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "pms_upstream_concurrency_wait_seconds",
+		Help: "Time the most recent upstream request waited for a free slot of UPSTREAM_MAX_CONCURRENT.",
+	})
+
+	cacheStaleServesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "pms_cache_stale_serves_total",
+		Help: "Total number of expired cache entries served because the upstream failed (error) or was slower than STALE_SOFT_TIMEOUT (timeout).",
+	}, []string{"reason"})
+
+	webhookDeliveriesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "pms_webhook_deliveries_total",
+		Help: "Total number of webhook deliveries, labelled by result (ok, failed, dropped).",
+	}, []string{"result"})
+
+	gzipUncompressedBytes = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "pms_gzip_uncompressed_bytes_total",
+		Help: "Total size of gzip-compressed responses before compression.",
+	})
+
+	gzipCompressedBytes = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "pms_gzip_compressed_bytes_total",
+		Help: "Total size of gzip-compressed responses after compression.",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(
+		httpRequestsTotal,
+		httpRequestDuration,
+		httpRequestsInFlight,
+		upstreamRequestsTotal,
+		upstreamRequestDuration,
+		upstreamRetriesTotal,
+		upstreamHealthy,
+		upstreamActive,
+		upstreamConcurrent,
+		upstreamConcurrencyWaiting,
+		upstreamConcurrencyWait,
+		cacheStaleServesTotal,
+		webhookDeliveriesTotal,
+		gzipUncompressedBytes,
+		gzipCompressedBytes,
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Name: "pms_cache_hits_total",
+			Help: "Total number of response cache hits.",
+		}, func() float64 { return float64(CacheHits.Load()) }),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Name: "pms_cache_misses_total",
+			Help: "Total number of response cache misses.",
+		}, func() float64 { return float64(CacheMisses.Load()) }),
+	)
+}
+
+// Middleware 记录每个请求的次数与耗时 (按路由模板与状态码区分) 及正在处理的请求数
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		httpRequestsInFlight.Inc()
+		defer httpRequestsInFlight.Dec()
+		start := time.Now()
+		c.Next()
+
+		endpoint := c.FullPath()
+		if endpoint == "" {
+			endpoint = "unmatched"
+		}
+		status := strconv.Itoa(c.Writer.Status())
+		httpRequestsTotal.WithLabelValues(endpoint, status).Inc()
+		httpRequestDuration.WithLabelValues(endpoint, status).Observe(time.Since(start).Seconds())
+	}
+}
+
+// ObserveUpstream 记录一次上游请求，status 为HTTP状态码或 error/timeout
+func ObserveUpstream(endpoint, status string, duration time.Duration) {
+	upstreamRequestsTotal.WithLabelValues(endpoint, status).Inc()
+	upstreamRequestDuration.WithLabelValues(endpoint).Observe(duration.Seconds())
+}
+
+// ObserveUpstreamRetry 记录一次上游重试，attempt 从1开始
+func ObserveUpstreamRetry(endpoint string, attempt int) {
+	upstreamRetriesTotal.WithLabelValues(endpoint, strconv.Itoa(attempt)).Inc()
+}
+
+// AddUpstreamConcurrent 在上游请求取得 (delta 为1) 或释放 (delta 为-1) 并发名额时调用
+func AddUpstreamConcurrent(delta float64) {
+	upstreamConcurrent.Add(delta)
+}
+
+// AddUpstreamConcurrencyWaiting 在上游请求开始 (delta 为1) 或结束 (delta 为-1) 等待并发名额时调用
+func AddUpstreamConcurrencyWaiting(delta float64) {
+	upstreamConcurrencyWaiting.Add(delta)
+}
+
+// ObserveUpstreamConcurrencyWait 记录上游请求等待并发名额的时间
+func ObserveUpstreamConcurrencyWait(wait time.Duration) {
+	upstreamConcurrencyWait.Set(wait.Seconds())
+}
+
+// ObserveStaleServe 记录一次返回过期缓存，reason 为 error 或 timeout
+func ObserveStaleServe(reason string) {
+	CacheStaleServes.Add(1)
+	cacheStaleServesTotal.WithLabelValues(reason).Inc()
+}
+
+// SetUpstreamHealthy 记录上游实例当前是否健康
+func SetUpstreamHealthy(upstream string, healthy bool) {
+	value := 0.0
+	if healthy {
+		value = 1
+	}
+	upstreamHealthy.WithLabelValues(upstream).Set(value)
+}
+
+// SetActiveUpstream 将 upstreams 中第 active 个实例标记为当前使用的实例
+func SetActiveUpstream(upstreams []string, active int) {
+	for i, upstream := range upstreams {
+		value := 0.0
+		if i == active {
+			value = 1
+		}
+		upstreamActive.WithLabelValues(upstream).Set(value)
+	}
+}
+
+// ObserveWebhookDelivery 记录一次webhook投递的结果，result 为 ok、failed (重试后仍失败) 或 dropped (投递队列已满)
+func ObserveWebhookDelivery(result string) {
+	webhookDeliveriesTotal.WithLabelValues(result).Inc()
+}
+
+// ObserveGzip 记录一次压缩响应压缩前后的字节数
+func ObserveGzip(uncompressed, compressed int64) {
+	gzipUncompressedBytes.Add(float64(uncompressed))
+	gzipCompressedBytes.Add(float64(compressed))
+}
+
+// Handler 提供Prometheus格式的指标，配置了 METRICS_TOKEN 时需携带
+// Authorization: Bearer <token>
+func Handler(token string) gin.HandlerFunc {
+	handler := promhttp.Handler()
+	return func(c *gin.Context) {
+		if token != "" {
+			provided := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+			if subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+				c.JSON(http.StatusUnauthorized, api.NewErrorResponse(c, 401, "Invalid or missing metrics token"))
+				return
+			}
+		}
+		handler.ServeHTTP(c.Writer, c.Request)
+	}
+}
@@ -0,0 +1,130 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"PMS/internal/api"
+	"PMS/internal/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 请求日志中记录的API Key前缀的最大长度
+const apiKeyLogPrefixLen = 6
+
+// apiKeyFromRequest 依次从 X-API-Key 头、Authorization: Bearer 头、key 或 api_key 参数中读取API Key
+func apiKeyFromRequest(c *gin.Context) string {
+	if key := c.GetHeader("X-API-Key"); key != "" {
+		return strings.TrimSpace(key)
+	}
+	if auth := c.GetHeader("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		return strings.TrimSpace(strings.TrimPrefix(auth, "Bearer "))
+	}
+	if key := c.Query("key"); key != "" {
+		return key
+	}
+	return c.Query("api_key")
+}
+
+// APIKey 校验API Key，未配置任何Key时为开放模式；public 返回 true 的路径不校验。
+// TENANTS_FILE 中的API Key同样有效，请求上下文中附带对应的租户配置；开放模式下携带租户API Key也按租户处理
+func APIKey(keys []string, public func(path string) bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if public(c.Request.URL.Path) {
+			c.Next()
+			return
+		}
+
+		provided := apiKeyFromRequest(c)
+		if tenant, ok := lookupTenant(provided); ok {
+			c.Set("api_key_prefix", keyPrefix(provided))
+			c.Set("tenant", tenant.Name)
+			c.Request = c.Request.WithContext(config.WithTenant(c.Request.Context(), tenant))
+			c.Next()
+			return
+		}
+		if len(keys) == 0 {
+			c.Next()
+			return
+		}
+
+		valid := false
+		for _, key := range keys {
+			// 遍历完所有Key，避免通过响应时间推断匹配位置
+			if subtle.ConstantTimeCompare([]byte(provided), []byte(key)) == 1 {
+				valid = true
+			}
+		}
+
+		if provided == "" || !valid {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, api.NewErrorResponse(c, 401, "Invalid or missing API key"))
+			return
+		}
+
+		c.Set("api_key_prefix", keyPrefix(provided))
+		c.Next()
+	}
+}
+
+// lookupTenant 在当前配置的租户中查找API Key，SIGHUP 重新加载后立即生效
+func lookupTenant(provided string) (config.TenantConfig, bool) {
+	var found config.TenantConfig
+	valid := false
+	if provided == "" {
+		return found, false
+	}
+	for key, tenant := range config.Current().Tenants {
+		if subtle.ConstantTimeCompare([]byte(provided), []byte(key)) == 1 {
+			found, valid = tenant, true
+		}
+	}
+	return found, valid
+}
+
+// AdminAuth 校验 Authorization: Bearer <ADMIN_TOKEN>，未配置 ADMIN_TOKEN 时管理接口不可用
+func AdminAuth(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if token == "" {
+			c.AbortWithStatusJSON(http.StatusForbidden, api.NewErrorResponse(c, 403, "Admin API is disabled, set ADMIN_TOKEN to enable it"))
+			return
+		}
+
+		provided := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, api.NewErrorResponse(c, 401, "Invalid or missing admin token"))
+			return
+		}
+		c.Next()
+	}
+}
+
+// keyPrefix 返回仅用于审计的打码Key前缀，保留前 apiKeyLogPrefixLen 个字符；
+// 不足12个字符的短Key只保留一半，避免短Key被完整或大部分记录
+func keyPrefix(key string) string {
+	n := min(apiKeyLogPrefixLen, len(key)/2)
+	return key[:n] + "***"
+}
+
+// RedactAPIKey 隐藏请求路径中 key 与 api_key 参数的值
+func RedactAPIKey(path string) string {
+	u, err := url.Parse(path)
+	if err != nil {
+		return path
+	}
+	query := u.Query()
+	redacted := false
+	for _, param := range []string{"key", "api_key"} {
+		if query.Has(param) {
+			query.Set(param, "REDACTED")
+			redacted = true
+		}
+	}
+	if !redacted {
+		return path
+	}
+	u.RawQuery = query.Encode()
+	return u.String()
+}
@@ -0,0 +1,55 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"PMS/internal/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestKeyPrefix(t *testing.T) {
+	tests := []struct {
+		key  string
+		want string
+	}{
+		{key: "abcdefghijklmnopqrstuvwxyz", want: "abcdef***"},
+		{key: "abcdefghijkl", want: "abcdef***"},
+		{key: "abcdefghij", want: "abcde***"},
+		{key: "abcd", want: "ab***"},
+		{key: "a", want: "***"},
+		{key: "", want: "***"},
+	}
+	for _, tt := range tests {
+		if got := keyPrefix(tt.key); got != tt.want {
+			t.Errorf("keyPrefix(%q) = %q, want %q", tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestAPIKeyRecordsPrefix(t *testing.T) {
+	prev := config.Current()
+	config.Store(&config.Config{})
+	t.Cleanup(func() { config.Store(prev) })
+
+	r := gin.New()
+	r.Use(APIKey([]string{"pms-0123456789abcdef"}, func(string) bool { return false }))
+	var prefix string
+	r.GET("/song", func(c *gin.Context) {
+		prefix = c.GetString("api_key_prefix")
+		c.Status(http.StatusOK)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/song", nil)
+	req.Header.Set("X-API-Key", "pms-0123456789abcdef")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusOK)
+	}
+	if prefix != "pms-01***" {
+		t.Errorf("api_key_prefix = %q, want %q", prefix, "pms-01***")
+	}
+}
@@ -0,0 +1,67 @@
+package middleware
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// originAllowed 判断请求来源是否在允许列表中，支持 https://*.example.com 形式的子域名通配
+func originAllowed(origin string, allowed []string) bool {
+	origin = strings.ToLower(origin)
+	for _, pattern := range allowed {
+		prefix, suffix, wildcard := strings.Cut(pattern, "*")
+		if !wildcard {
+			if origin == pattern {
+				return true
+			}
+			continue
+		}
+		// 通配符只匹配子域名部分，不能为空也不能跨越路径或端口
+		if len(origin) > len(prefix)+len(suffix) && strings.HasPrefix(origin, prefix) && strings.HasSuffix(origin, suffix) {
+			if sub := origin[len(prefix) : len(origin)-len(suffix)]; !strings.ContainsAny(sub, "/:") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// CORS 设置跨域响应头。allowed 为空时允许任意来源 (*)，此时不允许携带凭据；
+// 否则仅对匹配的 Origin 回显该来源，不匹配的请求照常处理但不带CORS头，浏览器会拒绝读取响应。
+// maxAge 大于0时预检响应携带 Access-Control-Max-Age
+func CORS(allowed []string, maxAge time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if len(allowed) == 0 {
+			c.Header("Access-Control-Allow-Origin", "*")
+			setCORSHeaders(c, maxAge)
+		} else if origin := c.GetHeader("Origin"); origin != "" {
+			// 响应随 Origin 变化，告知缓存按来源区分
+			c.Writer.Header().Add("Vary", "Origin")
+			if originAllowed(origin, allowed) {
+				c.Header("Access-Control-Allow-Origin", origin)
+				// 浏览器不接受 * 与凭据同时出现，仅在回显具体来源时允许凭据
+				c.Header("Access-Control-Allow-Credentials", "true")
+				setCORSHeaders(c, maxAge)
+			}
+		}
+
+		if c.Request.Method == "OPTIONS" {
+			c.AbortWithStatus(204)
+			return
+		}
+
+		c.Next()
+	}
+}
+
+func setCORSHeaders(c *gin.Context, maxAge time.Duration) {
+	c.Header("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With, X-Request-ID, X-API-Key, X-PMS-Flags")
+	c.Header("Access-Control-Expose-Headers", "X-Request-ID, X-PMS-Cache, X-PMS-Audio-Type, X-PMS-Bitrate, X-Quota-Limit, X-Quota-Remaining, X-Quota-Reset, X-RateLimit-Limit, X-RateLimit-Remaining, X-RateLimit-Reset, X-RateLimit-Policy")
+	c.Header("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, PATCH, DELETE")
+	if c.Request.Method == "OPTIONS" && maxAge > 0 {
+		c.Header("Access-Control-Max-Age", strconv.Itoa(int(maxAge.Seconds())))
+	}
+}
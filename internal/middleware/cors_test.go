@@ -0,0 +1,109 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestOriginAllowed(t *testing.T) {
+	allowed := []string{"https://app.example.com", "https://*.example.org"}
+	tests := []struct {
+		origin string
+		want   bool
+	}{
+		{"https://app.example.com", true},
+		{"HTTPS://APP.EXAMPLE.COM", true},
+		{"https://other.example.com", false},
+		{"http://app.example.com", false},
+		{"https://a.example.org", true},
+		{"https://a.b.example.org", true},
+		{"https://.example.org", false},
+		{"https://example.org", false},
+		{"https://evil.com/.example.org", false},
+		{"https://a.example.org:8443", false},
+		{"https://a.example.org.evil.com", false},
+	}
+	for _, tt := range tests {
+		if got := originAllowed(tt.origin, allowed); got != tt.want {
+			t.Errorf("originAllowed(%q) = %t, want %t", tt.origin, got, tt.want)
+		}
+	}
+}
+
+func TestCORSPreflight(t *testing.T) {
+	tests := []struct {
+		name            string
+		allowed         []string
+		origin          string
+		wantOrigin      string
+		wantCredentials string
+		wantVary        string
+	}{
+		{name: "any origin", origin: "https://app.example.com", wantOrigin: "*"},
+		{name: "allowed origin", allowed: []string{"https://app.example.com"}, origin: "https://app.example.com",
+			wantOrigin: "https://app.example.com", wantCredentials: "true", wantVary: "Origin"},
+		{name: "wildcard subdomain", allowed: []string{"https://*.example.com"}, origin: "https://app.example.com",
+			wantOrigin: "https://app.example.com", wantCredentials: "true", wantVary: "Origin"},
+		{name: "disallowed origin", allowed: []string{"https://app.example.com"}, origin: "https://evil.com", wantVary: "Origin"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodOptions, "/song", nil)
+			req.Header.Set("Origin", tt.origin)
+			req.Header.Set("Access-Control-Request-Method", http.MethodGet)
+			w := serve(CORS(tt.allowed, 10*time.Minute), req)
+
+			// 预检请求不进入后续处理函数，不论来源是否允许都返回204
+			if w.Code != http.StatusNoContent {
+				t.Errorf("status = %d, want %d", w.Code, http.StatusNoContent)
+			}
+			if got := w.Header().Get("Access-Control-Allow-Origin"); got != tt.wantOrigin {
+				t.Errorf("Access-Control-Allow-Origin = %q, want %q", got, tt.wantOrigin)
+			}
+			if got := w.Header().Get("Access-Control-Allow-Credentials"); got != tt.wantCredentials {
+				t.Errorf("Access-Control-Allow-Credentials = %q, want %q", got, tt.wantCredentials)
+			}
+			if got := w.Header().Get("Vary"); got != tt.wantVary {
+				t.Errorf("Vary = %q, want %q", got, tt.wantVary)
+			}
+			wantMaxAge := ""
+			if tt.wantOrigin != "" {
+				wantMaxAge = "600"
+			}
+			if got := w.Header().Get("Access-Control-Max-Age"); got != wantMaxAge {
+				t.Errorf("Access-Control-Max-Age = %q, want %q", got, wantMaxAge)
+			}
+		})
+	}
+}
+
+func TestCORSSimpleRequest(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/song", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	w := serve(CORS([]string{"https://app.example.com"}, 10*time.Minute), req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("status = %d, want the request to reach the handler", w.Code)
+	}
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "https://app.example.com" {
+		t.Errorf("Access-Control-Allow-Origin = %q, want the request origin", got)
+	}
+	if got := w.Header().Get("Access-Control-Expose-Headers"); got == "" {
+		t.Error("Access-Control-Expose-Headers missing")
+	}
+	if got := w.Header().Get("Access-Control-Max-Age"); got != "" {
+		t.Errorf("Access-Control-Max-Age = %q on a non-preflight request, want none", got)
+	}
+}
+
+func TestCORSMaxAgeDisabled(t *testing.T) {
+	req := httptest.NewRequest(http.MethodOptions, "/song", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	w := serve(CORS(nil, 0), req)
+
+	if got := w.Header().Get("Access-Control-Max-Age"); got != "" {
+		t.Errorf("Access-Control-Max-Age = %q with CORS_MAX_AGE=0, want none", got)
+	}
+}
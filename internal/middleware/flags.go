@@ -0,0 +1,68 @@
+package middleware
+
+import (
+	"net"
+	"sort"
+	"strings"
+
+	"PMS/internal/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 保存请求中生效的覆盖值的上下文键
+const featureFlagsKey = "feature_flags"
+
+// 各覆盖参数的取值校验
+var featureFlagValidators = map[string]func(value string) bool{
+	config.FeatureFlagLevel:  config.IsValidLevel,
+	config.FeatureFlagRealIP: func(value string) bool { return net.ParseIP(value) != nil },
+}
+
+// FeatureFlags 解析 X-PMS-Flags 请求头中以 , 或 ; 分隔的 key=value，只保留 enabled 中列出且取值有效的项，
+// 处理函数通过 FeatureFlag 读取；enabled 为空时不解析请求头
+func FeatureFlags(enabled []string) gin.HandlerFunc {
+	allowed := make(map[string]bool, len(enabled))
+	for _, key := range enabled {
+		allowed[key] = true
+	}
+
+	return func(c *gin.Context) {
+		header := c.GetHeader("X-PMS-Flags")
+		if len(allowed) == 0 || header == "" {
+			c.Next()
+			return
+		}
+
+		flags := make(map[string]string)
+		for _, part := range strings.FieldsFunc(header, func(r rune) bool { return r == ',' || r == ';' }) {
+			key, value, _ := strings.Cut(part, "=")
+			key, value = strings.ToLower(strings.TrimSpace(key)), strings.TrimSpace(value)
+			if allowed[key] && featureFlagValidators[key](value) {
+				flags[key] = value
+			}
+		}
+		if len(flags) > 0 {
+			c.Set(featureFlagsKey, flags)
+		}
+		c.Next()
+	}
+}
+
+// FeatureFlag 返回 X-PMS-Flags 中 key 的覆盖值
+func FeatureFlag(c *gin.Context, key string) (string, bool) {
+	flags, _ := c.Value(featureFlagsKey).(map[string]string)
+	value, ok := flags[key]
+	return value, ok
+}
+
+// featureFlagsLogValue 以 key=value 逗号拼接请求中生效的覆盖值，用于访问日志，没有时返回空字符串
+func featureFlagsLogValue(c *gin.Context) string {
+	flags, _ := c.Value(featureFlagsKey).(map[string]string)
+	pairs := make([]string, 0, len(flags))
+	for key, value := range flags {
+		pairs = append(pairs, key+"="+value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
@@ -0,0 +1,196 @@
+package middleware
+
+import (
+	"bytes"
+	"compress/gzip"
+	"net/http"
+	"strings"
+
+	"PMS/internal/metrics"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 不压缩的响应类型：音频、图片等本身已压缩，流式内容需要及时发送
+var gzipSkipContentTypes = []string{
+	"audio/",
+	"video/",
+	"image/",
+	"application/octet-stream",
+	"application/zip",
+	"text/event-stream",
+}
+
+// Gzip 客户端支持gzip时压缩响应；不足 minLength 字节的响应与二进制内容原样返回。
+// skip 返回 true 的路径 (音频与图片代理) 不经过压缩，响应头与 Content-Length、Range 语义保持上游原样
+func Gzip(level, minLength int, skip func(path string) bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.Method == http.MethodHead || !strings.Contains(c.GetHeader("Accept-Encoding"), "gzip") || skip(c.Request.URL.Path) {
+			c.Next()
+			return
+		}
+
+		gw := &gzipResponseWriter{
+			ResponseWriter: c.Writer,
+			level:          level,
+			minLength:      minLength,
+			status:         http.StatusOK,
+		}
+		c.Writer = gw
+		defer func() {
+			gw.finish()
+			c.Writer = gw.ResponseWriter
+		}()
+		c.Next()
+	}
+}
+
+// gzipResponseWriter 先缓存响应，达到 minLength 后才决定是否压缩，
+// 在此之前状态码也暂不写出，以便设置 Content-Encoding
+type gzipResponseWriter struct {
+	gin.ResponseWriter
+	level     int
+	minLength int
+
+	status   int
+	buf      bytes.Buffer
+	decided  bool
+	gz       *gzip.Writer
+	written  int64
+	counting *countingWriter
+}
+
+func (w *gzipResponseWriter) WriteHeader(code int) {
+	if !w.decided {
+		w.status = code
+		return
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *gzipResponseWriter) WriteHeaderNow() {
+	if w.decided {
+		w.ResponseWriter.WriteHeaderNow()
+	}
+}
+
+func (w *gzipResponseWriter) Status() int {
+	if !w.decided {
+		return w.status
+	}
+	return w.ResponseWriter.Status()
+}
+
+func (w *gzipResponseWriter) Written() bool {
+	return w.decided && w.ResponseWriter.Written()
+}
+
+func (w *gzipResponseWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *gzipResponseWriter) Write(data []byte) (int, error) {
+	if w.decided {
+		return w.writeDecided(data)
+	}
+
+	if !w.compressible() {
+		w.decide(false)
+		return w.writeDecided(data)
+	}
+
+	w.buf.Write(data)
+	if w.buf.Len() >= w.minLength {
+		w.decide(true)
+	}
+	return len(data), nil
+}
+
+// Unwrap 供 http.ResponseController 访问底层连接
+func (w *gzipResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Flush 流式写出时不再等待，已缓存的内容按未压缩发送
+func (w *gzipResponseWriter) Flush() {
+	if !w.decided {
+		w.decide(false)
+	}
+	if w.gz != nil {
+		w.gz.Flush()
+	}
+	w.ResponseWriter.Flush()
+}
+
+func (w *gzipResponseWriter) writeDecided(data []byte) (int, error) {
+	if w.gz == nil {
+		return w.ResponseWriter.Write(data)
+	}
+	w.written += int64(len(data))
+	return w.gz.Write(data)
+}
+
+// compressible 根据状态码与响应头判断是否适合压缩
+func (w *gzipResponseWriter) compressible() bool {
+	if w.status < http.StatusOK || w.status == http.StatusNoContent || w.status == http.StatusNotModified ||
+		w.status == http.StatusPartialContent {
+		return false
+	}
+	header := w.Header()
+	if header.Get("Content-Encoding") != "" {
+		return false
+	}
+	contentType := header.Get("Content-Type")
+	for _, prefix := range gzipSkipContentTypes {
+		if strings.HasPrefix(contentType, prefix) {
+			return false
+		}
+	}
+	return true
+}
+
+// decide 写出状态码与响应头，并将已缓存的内容发送出去
+func (w *gzipResponseWriter) decide(compress bool) {
+	w.decided = true
+	header := w.Header()
+	header.Add("Vary", "Accept-Encoding")
+
+	if compress {
+		header.Set("Content-Encoding", "gzip")
+		header.Del("Content-Length")
+		w.counting = &countingWriter{w: w.ResponseWriter}
+		// 级别已在启动时校验，此处不会出错
+		w.gz, _ = gzip.NewWriterLevel(w.counting, w.level)
+	}
+
+	w.ResponseWriter.WriteHeader(w.status)
+	if w.buf.Len() > 0 {
+		w.writeDecided(w.buf.Bytes())
+		w.buf.Reset()
+	}
+}
+
+// finish 处理结束时调用：未达到压缩阈值的响应原样写出，压缩流写入结尾并记录字节数
+func (w *gzipResponseWriter) finish() {
+	if !w.decided {
+		w.decide(false)
+		w.ResponseWriter.WriteHeaderNow()
+		return
+	}
+	if w.gz != nil {
+		w.gz.Close()
+		metrics.ObserveGzip(w.written, w.counting.n)
+	}
+}
+
+// countingWriter 统计压缩后实际写出的字节数
+type countingWriter struct {
+	w interface{ Write([]byte) (int, error) }
+	n int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
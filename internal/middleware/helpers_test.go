@@ -0,0 +1,24 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/gin-gonic/gin"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+// serve 以 mw 处理 req，通过 mw 的请求由返回200的处理函数应答；
+// 与未配置 TRUSTED_PROXIES 时一样不信任任何代理
+func serve(mw gin.HandlerFunc, req *http.Request) *httptest.ResponseRecorder {
+	r := gin.New()
+	r.SetTrustedProxies(nil)
+	r.Use(mw)
+	r.Any("/*path", func(c *gin.Context) { c.Status(http.StatusOK) })
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
@@ -0,0 +1,78 @@
+package middleware
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+
+	"PMS/internal/api"
+
+	"github.com/gin-gonic/gin"
+)
+
+// JSONP 回调名只允许由点分隔的JavaScript标识符 (如 fn、player.onSong)，避免通过回调名注入脚本
+var jsonpCallbackPattern = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$]*(\.[A-Za-z_$][A-Za-z0-9_$]*)*$`)
+
+// JSONP 回调名的最大长度
+const jsonpCallbackMaxLength = 64
+
+// JSONP 请求带 ?callback= 时将JSON响应 (包括错误响应) 包装为 callback(...); 供无法使用CORS的旧页面以 <script> 引用，
+// 回调名不合法时返回400；非JSON响应 (音频、图片等) 原样返回
+func JSONP() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		callback, ok := c.GetQuery("callback")
+		if !ok {
+			c.Next()
+			return
+		}
+		if len(callback) > jsonpCallbackMaxLength || !jsonpCallbackPattern.MatchString(callback) {
+			c.AbortWithStatusJSON(http.StatusBadRequest, api.NewErrorResponse(c, 400, "Invalid callback, must be a JavaScript identifier such as fn or player.onSong"))
+			return
+		}
+
+		jw := &jsonpResponseWriter{ResponseWriter: c.Writer, callback: callback}
+		c.Writer = jw
+		defer func() {
+			if jw.wrapped {
+				jw.ResponseWriter.WriteString(");")
+			}
+			c.Writer = jw.ResponseWriter
+		}()
+		c.Next()
+	}
+}
+
+// jsonpResponseWriter 在第一次写入时根据 Content-Type 决定是否包装，写出响应头之前改为 application/javascript
+type jsonpResponseWriter struct {
+	gin.ResponseWriter
+	callback string
+	decided  bool
+	wrapped  bool
+}
+
+func (w *jsonpResponseWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *jsonpResponseWriter) Write(data []byte) (int, error) {
+	if !w.decided {
+		w.decided = true
+		header := w.Header()
+		if strings.HasPrefix(header.Get("Content-Type"), "application/json") {
+			w.wrapped = true
+			header.Set("Content-Type", "application/javascript; charset=utf-8")
+			header.Set("X-Content-Type-Options", "nosniff")
+			header.Del("Content-Length")
+			// 开头的空注释避免响应被当作其他类型的内容 (如Flash) 解析
+			if _, err := w.ResponseWriter.WriteString("/**/" + w.callback + "("); err != nil {
+				return 0, err
+			}
+		}
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+// Unwrap 供 http.ResponseController 访问底层连接
+func (w *jsonpResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
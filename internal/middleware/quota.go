@@ -0,0 +1,61 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"PMS/internal/api"
+	"PMS/internal/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Quota 按租户的 daily_quota 限制每天 (UTC) 的上游请求数，usage 返回租户当天已使用的次数，
+// 计数在上游请求成功后进行，缓存命中与参数校验失败的请求不计入；未设置额度的租户与非租户请求不受限制。
+// 超出额度时返回429，X-Quota-Remaining 为 0，X-Quota-Reset 为下一个UTC零点的Unix时间戳。
+// 额度同时追加到 X-RateLimit-Policy，剩余额度少于限流的剩余次数时 X-RateLimit-* 改为反映额度
+func Quota(usage func(ctx context.Context, tenant string) int64, reset func(now time.Time) time.Time) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tenant, ok := config.TenantFrom(c.Request.Context())
+		if !ok || tenant.DailyQuota <= 0 {
+			c.Next()
+			return
+		}
+
+		now := time.Now()
+		resetAt := reset(now)
+		remaining := max(tenant.DailyQuota-usage(c.Request.Context(), tenant.Name), 0)
+		c.Header("X-Quota-Limit", strconv.FormatInt(tenant.DailyQuota, 10))
+		c.Header("X-Quota-Remaining", strconv.FormatInt(remaining, 10))
+		c.Header("X-Quota-Reset", strconv.FormatInt(resetAt.Unix(), 10))
+		addQuotaRateLimitHeaders(c, tenant.DailyQuota, remaining, resetAt)
+		if remaining == 0 {
+			c.Header("Retry-After", strconv.Itoa(int(resetAt.Sub(now).Seconds())+1))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, api.NewErrorResponse(c, 429, "Daily quota exceeded"))
+			return
+		}
+		c.Next()
+	}
+}
+
+// 每日额度在 X-RateLimit-Policy 中的时间窗口 (秒)
+const quotaPolicyWindow = 24 * 60 * 60
+
+// addQuotaRateLimitHeaders 按IETF草案的约定，X-RateLimit-Policy 列出全部策略，
+// X-RateLimit-Limit/Remaining/Reset 反映最接近耗尽的一个
+func addQuotaRateLimitHeaders(c *gin.Context, limit, remaining int64, reset time.Time) {
+	policy := fmt.Sprintf("%d;w=%d", limit, quotaPolicyWindow)
+	h := c.Writer.Header()
+	if current := h.Get("X-RateLimit-Policy"); current != "" {
+		policy = current + ", " + policy
+	}
+	c.Header("X-RateLimit-Policy", policy)
+
+	current, err := strconv.ParseInt(h.Get("X-RateLimit-Remaining"), 10, 64)
+	if err != nil || remaining < current {
+		setRateLimitHeaders(c, limit, remaining, reset)
+	}
+}
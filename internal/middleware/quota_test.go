@@ -0,0 +1,97 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"PMS/internal/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+// asTenant 在 mw 之前将请求标记为 tenant 的请求，与 APIKey 匹配到租户的API Key时相同
+func asTenant(tenant config.TenantConfig, mw gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Request = c.Request.WithContext(config.WithTenant(c.Request.Context(), tenant))
+		mw(c)
+	}
+}
+
+func fixedUsage(n int64) func(context.Context, string) int64 {
+	return func(context.Context, string) int64 { return n }
+}
+
+func TestQuota(t *testing.T) {
+	now := time.Now()
+	reset := func(time.Time) time.Time { return now.Add(time.Hour) }
+	tenant := config.TenantConfig{Name: "acme", DailyQuota: 100}
+
+	tests := []struct {
+		name          string
+		tenant        *config.TenantConfig
+		used          int64
+		wantStatus    int
+		wantRemaining string
+	}{
+		{name: "within quota", tenant: &tenant, used: 40, wantStatus: http.StatusOK, wantRemaining: "60"},
+		{name: "last request", tenant: &tenant, used: 99, wantStatus: http.StatusOK, wantRemaining: "1"},
+		{name: "quota exhausted", tenant: &tenant, used: 100, wantStatus: http.StatusTooManyRequests, wantRemaining: "0"},
+		{name: "usage over quota", tenant: &tenant, used: 150, wantStatus: http.StatusTooManyRequests, wantRemaining: "0"},
+		{name: "tenant without quota", tenant: &config.TenantConfig{Name: "free"}, used: 1000, wantStatus: http.StatusOK},
+		{name: "not a tenant", used: 1000, wantStatus: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mw := Quota(fixedUsage(tt.used), reset)
+			if tt.tenant != nil {
+				mw = asTenant(*tt.tenant, mw)
+			}
+			w := serve(mw, httptest.NewRequest(http.MethodGet, "/song?id=1", nil))
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if got := w.Header().Get("X-Quota-Remaining"); got != tt.wantRemaining {
+				t.Errorf("X-Quota-Remaining = %q, want %q", got, tt.wantRemaining)
+			}
+			if tt.wantRemaining == "" {
+				return
+			}
+			if got := w.Header().Get("X-Quota-Reset"); got != strconv.FormatInt(now.Add(time.Hour).Unix(), 10) {
+				t.Errorf("X-Quota-Reset = %q, want the reset time", got)
+			}
+			if tt.wantStatus == http.StatusTooManyRequests {
+				if retryAfter, _ := strconv.Atoi(w.Header().Get("Retry-After")); retryAfter < 3600 || retryAfter > 3601 {
+					t.Errorf("Retry-After = %q, want the seconds until the reset", w.Header().Get("Retry-After"))
+				}
+			}
+		})
+	}
+}
+
+func TestQuotaCombinesWithRateLimitHeaders(t *testing.T) {
+	reset := func(now time.Time) time.Time { return now.Add(time.Hour) }
+	quota := asTenant(config.TenantConfig{Name: "acme", DailyQuota: 1000}, Quota(fixedUsage(995), reset))
+	rateLimit := RateLimit(NewIPRateLimiter(10, 20), noSkip)
+
+	r := gin.New()
+	r.Use(rateLimit, quota)
+	r.GET("/song", func(c *gin.Context) { c.Status(http.StatusOK) })
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/song", nil))
+
+	if got := w.Header().Get("X-RateLimit-Policy"); got != "20;w=2, 1000;w=86400" {
+		t.Errorf("X-RateLimit-Policy = %q, want both policies", got)
+	}
+	// 剩余额度 (5) 少于限流的剩余次数 (19)，X-RateLimit-* 反映额度
+	if got := w.Header().Get("X-RateLimit-Remaining"); got != "5" {
+		t.Errorf("X-RateLimit-Remaining = %q, want the remaining quota", got)
+	}
+	if got := w.Header().Get("X-RateLimit-Limit"); got != "1000" {
+		t.Errorf("X-RateLimit-Limit = %q, want the daily quota", got)
+	}
+}
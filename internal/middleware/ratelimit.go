@@ -0,0 +1,122 @@
+package middleware
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"PMS/internal/api"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/time/rate"
+)
+
+// 长时间没有请求的IP会被清理，避免限流表无限增长
+const (
+	rateLimiterIdleTTL         = 3 * time.Minute
+	rateLimiterCleanupInterval = time.Minute
+)
+
+type ipLimiter struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// IPRateLimiter 按来源IP维护令牌桶
+type IPRateLimiter struct {
+	mu       sync.Mutex
+	limiters map[string]*ipLimiter
+	rps      rate.Limit
+	burst    int
+}
+
+// NewIPRateLimiter 创建按来源IP区分的限流器，每个IP每秒 rps 个请求，突发上限为 burst
+func NewIPRateLimiter(rps float64, burst int) *IPRateLimiter {
+	l := &IPRateLimiter{
+		limiters: make(map[string]*ipLimiter),
+		rps:      rate.Limit(rps),
+		burst:    burst,
+	}
+	go l.cleanupLoop()
+	return l
+}
+
+func (l *IPRateLimiter) get(ip string) *rate.Limiter {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	entry, ok := l.limiters[ip]
+	if !ok {
+		entry = &ipLimiter{limiter: rate.NewLimiter(l.rps, l.burst)}
+		l.limiters[ip] = entry
+	}
+	entry.lastSeen = time.Now()
+	return entry.limiter
+}
+
+func (l *IPRateLimiter) cleanupLoop() {
+	ticker := time.NewTicker(rateLimiterCleanupInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		l.mu.Lock()
+		for ip, entry := range l.limiters {
+			if time.Since(entry.lastSeen) > rateLimiterIdleTTL {
+				delete(l.limiters, ip)
+			}
+		}
+		l.mu.Unlock()
+	}
+}
+
+// policy 返回 X-RateLimit-Policy 中的限流策略：突发容量及令牌桶从空到满所需的秒数
+func (l *IPRateLimiter) policy() string {
+	return fmt.Sprintf("%d;w=%d", l.burst, int(math.Ceil(float64(l.burst)/float64(l.rps))))
+}
+
+// setRateLimitHeaders 写入 X-RateLimit-Limit、X-RateLimit-Remaining 与 X-RateLimit-Reset (Unix时间戳)
+func setRateLimitHeaders(c *gin.Context, limit, remaining int64, reset time.Time) {
+	c.Header("X-RateLimit-Limit", strconv.FormatInt(limit, 10))
+	c.Header("X-RateLimit-Remaining", strconv.FormatInt(remaining, 10))
+	c.Header("X-RateLimit-Reset", strconv.FormatInt(int64(math.Ceil(float64(reset.UnixMilli())/1000)), 10))
+}
+
+// RateLimit 按客户端IP限流，skip 返回 true 的路径 (健康检查与 /metrics) 不受限制。
+// 客户端IP只在连接来自 TRUSTED_PROXIES 时才取自 X-Forwarded-For，客户端无法伪造该头换取新的令牌桶。
+// 每个响应都带有 X-RateLimit-* 头，Reset 为令牌桶重新装满的时间；未开启限流时不发送这些头
+func RateLimit(l *IPRateLimiter, skip func(path string) bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if skip(c.Request.URL.Path) {
+			c.Next()
+			return
+		}
+
+		limiter := l.get(c.ClientIP())
+
+		now := time.Now()
+		reservation := limiter.ReserveN(now, 1)
+		delay := reservation.DelayFrom(now)
+		if !reservation.OK() || delay > 0 {
+			reservation.CancelAt(now)
+
+			retryAfter := int(math.Ceil(delay.Seconds()))
+			if retryAfter < 1 {
+				retryAfter = 1
+			}
+			c.Header("Retry-After", strconv.Itoa(retryAfter))
+			c.Header("X-RateLimit-Policy", l.policy())
+			setRateLimitHeaders(c, int64(l.burst), 0, now.Add(delay))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, api.NewErrorResponse(c, 429, "Too many requests"))
+			return
+		}
+
+		tokens := max(limiter.TokensAt(now), 0)
+		full := now.Add(time.Duration((float64(l.burst) - tokens) / float64(l.rps) * float64(time.Second)))
+		c.Header("X-RateLimit-Policy", l.policy())
+		setRateLimitHeaders(c, int64(l.burst), int64(tokens), full)
+		c.Next()
+	}
+}
@@ -0,0 +1,102 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"PMS/internal/api"
+)
+
+func noSkip(string) bool { return false }
+
+func requestFrom(ip, path string) *http.Request {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	req.RemoteAddr = ip + ":40000"
+	return req
+}
+
+func TestRateLimitTokenBucket(t *testing.T) {
+	mw := RateLimit(NewIPRateLimiter(1, 2), noSkip)
+
+	for i, wantRemaining := range []string{"1", "0"} {
+		w := serve(mw, requestFrom("192.0.2.1", "/song"))
+		if w.Code != http.StatusOK {
+			t.Fatalf("request %d: status = %d, want burst to be allowed", i+1, w.Code)
+		}
+		if got := w.Header().Get("X-RateLimit-Remaining"); got != wantRemaining {
+			t.Errorf("request %d: X-RateLimit-Remaining = %q, want %q", i+1, got, wantRemaining)
+		}
+		if got := w.Header().Get("X-RateLimit-Limit"); got != "2" {
+			t.Errorf("request %d: X-RateLimit-Limit = %q, want 2", i+1, got)
+		}
+		if got := w.Header().Get("X-RateLimit-Policy"); got != "2;w=2" {
+			t.Errorf("request %d: X-RateLimit-Policy = %q, want 2;w=2", i+1, got)
+		}
+	}
+
+	w := serve(mw, requestFrom("192.0.2.1", "/song"))
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("status = %d after burst, want %d", w.Code, http.StatusTooManyRequests)
+	}
+	if retryAfter, err := strconv.Atoi(w.Header().Get("Retry-After")); err != nil || retryAfter < 1 {
+		t.Errorf("Retry-After = %q, want at least 1 second", w.Header().Get("Retry-After"))
+	}
+	if got := w.Header().Get("X-RateLimit-Remaining"); got != "0" {
+		t.Errorf("X-RateLimit-Remaining = %q, want 0", got)
+	}
+	var resp api.ErrorResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || resp.Code != http.StatusTooManyRequests {
+		t.Errorf("body = %s, want a 429 error response", w.Body)
+	}
+
+	// 其他IP使用独立的令牌桶
+	if w := serve(mw, requestFrom("192.0.2.2", "/song")); w.Code != http.StatusOK {
+		t.Errorf("other IP: status = %d, want %d", w.Code, http.StatusOK)
+	}
+}
+
+func TestRateLimitRefills(t *testing.T) {
+	mw := RateLimit(NewIPRateLimiter(50, 1), noSkip)
+
+	if w := serve(mw, requestFrom("192.0.2.1", "/song")); w.Code != http.StatusOK {
+		t.Fatalf("first request: status = %d", w.Code)
+	}
+	if w := serve(mw, requestFrom("192.0.2.1", "/song")); w.Code != http.StatusTooManyRequests {
+		t.Fatalf("second request: status = %d, want %d", w.Code, http.StatusTooManyRequests)
+	}
+	// 每秒50个令牌，约20ms补充一个
+	time.Sleep(40 * time.Millisecond)
+	if w := serve(mw, requestFrom("192.0.2.1", "/song")); w.Code != http.StatusOK {
+		t.Errorf("after refill: status = %d, want %d", w.Code, http.StatusOK)
+	}
+}
+
+func TestRateLimitIgnoresUntrustedForwardedFor(t *testing.T) {
+	mw := RateLimit(NewIPRateLimiter(1, 1), noSkip)
+
+	serve(mw, requestFrom("192.0.2.1", "/song"))
+	req := requestFrom("192.0.2.1", "/song")
+	req.Header.Set("X-Forwarded-For", "198.51.100.7")
+	if w := serve(mw, req); w.Code != http.StatusTooManyRequests {
+		t.Errorf("status = %d with a spoofed X-Forwarded-For, want %d", w.Code, http.StatusTooManyRequests)
+	}
+}
+
+func TestRateLimitSkip(t *testing.T) {
+	mw := RateLimit(NewIPRateLimiter(1, 1), func(path string) bool { return strings.HasPrefix(path, "/health") })
+
+	for range 3 {
+		w := serve(mw, requestFrom("192.0.2.1", "/healthz"))
+		if w.Code != http.StatusOK {
+			t.Fatalf("skipped path: status = %d, want %d", w.Code, http.StatusOK)
+		}
+		if got := w.Header().Get("X-RateLimit-Limit"); got != "" {
+			t.Errorf("skipped path: X-RateLimit-Limit = %q, want none", got)
+		}
+	}
+}
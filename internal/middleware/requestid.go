@@ -0,0 +1,47 @@
+// Package middleware 提供PMS的Gin中间件：请求ID、访问日志、CORS、安全响应头、压缩、限流、鉴权与停机
+package middleware
+
+import (
+	"PMS/internal/logging"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// RequestIDHeader 请求ID的请求/响应头，转发给上游时沿用
+const RequestIDHeader = "X-Request-ID"
+
+// 客户端传入的请求ID最大长度
+const maxRequestIDLen = 128
+
+// RequestID 沿用客户端传入的合法 X-Request-ID，否则生成UUID v4；
+// 请求ID写入Gin上下文、请求上下文与响应头，日志通过 logging.From 自动附带
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := c.GetHeader(RequestIDHeader)
+		if !isValidRequestID(requestID) {
+			requestID = uuid.NewString()
+		}
+
+		c.Set("request_id", requestID)
+		c.Request = c.Request.WithContext(logging.WithRequestID(c.Request.Context(), requestID))
+		c.Header(RequestIDHeader, requestID)
+		c.Next()
+	}
+}
+
+// isValidRequestID 仅接受长度受限的可打印ASCII字母数字及 - _ . : ，防止日志注入
+func isValidRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLen {
+		return false
+	}
+	for _, r := range id {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case r == '-', r == '_', r == '.', r == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,50 @@
+package middleware
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"PMS/internal/logging"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequestLog 在请求结束后输出访问日志，需位于 RequestID 之后
+func RequestLog() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		attrs := []any{
+			"method", c.Request.Method,
+			"route", route,
+			"path", RedactAPIKey(c.Request.URL.RequestURI()),
+			"status_code", c.Writer.Status(),
+			"latency_ms", time.Since(start).Milliseconds(),
+			"client_ip", c.ClientIP(),
+		}
+		if prefix := c.GetString("api_key_prefix"); prefix != "" {
+			attrs = append(attrs, "api_key_prefix", prefix)
+		}
+		if tenant := c.GetString("tenant"); tenant != "" {
+			attrs = append(attrs, "tenant", tenant)
+		}
+		if flags := featureFlagsLogValue(c); flags != "" {
+			attrs = append(attrs, "feature_flags", flags)
+		}
+		if errs := c.Errors.ByType(gin.ErrorTypePrivate).String(); errs != "" {
+			attrs = append(attrs, "error", errs)
+		}
+
+		level := slog.LevelInfo
+		if c.Writer.Status() >= http.StatusInternalServerError {
+			level = slog.LevelError
+		}
+		logging.From(c.Request.Context()).Log(c.Request.Context(), level, "request", attrs...)
+	}
+}
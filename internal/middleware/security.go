@@ -0,0 +1,24 @@
+package middleware
+
+import (
+	"PMS/internal/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SecurityHeaders 为所有响应添加安全响应头；已由前面的中间件设置的响应头保持不变，
+// 处理函数仍可按需覆盖
+func SecurityHeaders(headers []config.SecurityHeader, hsts string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		h := c.Writer.Header()
+		for _, header := range headers {
+			if h.Get(header.Name) == "" {
+				h.Set(header.Name, header.Value)
+			}
+		}
+		if hsts != "" && c.Request.TLS != nil && h.Get("Strict-Transport-Security") == "" {
+			h.Set("Strict-Transport-Security", hsts)
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,58 @@
+package middleware
+
+import (
+	"net/http"
+	"sync"
+	"sync/atomic"
+
+	"PMS/internal/api"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	// 收到停机信号后置为 true
+	shuttingDown atomic.Bool
+	// 正在处理的请求数
+	inFlightRequests atomic.Int64
+	// 开始停机时关闭，供 /events 等长连接及时结束
+	shutdownStarted = make(chan struct{})
+	shutdownOnce    sync.Once
+)
+
+// Shutdown 统计进行中的请求，停机开始后拒绝新请求；
+// skip 返回 true 的路径 (健康检查) 照常处理，由其自行返回503
+func Shutdown(skip func(path string) bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if shuttingDown.Load() && !skip(c.Request.URL.Path) {
+			c.Header("Connection", "close")
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, api.NewErrorResponse(c, 503, "Server is shutting down"))
+			return
+		}
+
+		inFlightRequests.Add(1)
+		defer inFlightRequests.Add(-1)
+		c.Next()
+	}
+}
+
+// ShuttingDown 返回是否已开始停机
+func ShuttingDown() bool {
+	return shuttingDown.Load()
+}
+
+// BeginShutdown 标记开始停机，此后的新请求返回503
+func BeginShutdown() {
+	shuttingDown.Store(true)
+	shutdownOnce.Do(func() { close(shutdownStarted) })
+}
+
+// ShutdownStarted 返回开始停机时关闭的 channel
+func ShutdownStarted() <-chan struct{} {
+	return shutdownStarted
+}
+
+// InFlight 返回正在处理的请求数
+func InFlight() int64 {
+	return inFlightRequests.Load()
+}
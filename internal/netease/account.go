@@ -0,0 +1,51 @@
+package netease
+
+import (
+	"context"
+	"net/url"
+)
+
+// LoginStatusResponse 上游 /login/status 响应中需要的字段
+type LoginStatusResponse struct {
+	Data struct {
+		Code    int `json:"code"`
+		Account *struct {
+			ID      int64 `json:"id"`
+			VipType int   `json:"vipType"`
+		} `json:"account"`
+		Profile *struct {
+			UserID   int64  `json:"userId"`
+			Nickname string `json:"nickname"`
+			VipType  int    `json:"vipType"`
+		} `json:"profile"`
+	} `json:"data"`
+}
+
+// LoggedIn 判断Cookie对应的账号是否处于登录状态
+func (r *LoginStatusResponse) LoggedIn() bool {
+	return r.Data.Code == 200 && r.Data.Profile != nil
+}
+
+// LoginRefreshResponse 上游 /login/refresh 响应中需要的字段，cookie 为以 ; 拼接的 Set-Cookie
+type LoginRefreshResponse struct {
+	Code   int    `json:"code"`
+	Cookie string `json:"cookie"`
+}
+
+// LoginStatus 实现 Client
+func (c *HTTPClient) LoginStatus(ctx context.Context, cookie, realIP string) (*LoginStatusResponse, error) {
+	var resp LoginStatusResponse
+	if err := c.getJSON(ctx, URL("/login/status", url.Values{}, realIP, cookie), &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// LoginRefresh 实现 Client
+func (c *HTTPClient) LoginRefresh(ctx context.Context, cookie, realIP string) (*LoginRefreshResponse, error) {
+	var resp LoginRefreshResponse
+	if err := c.getJSON(ctx, URL("/login/refresh", url.Values{}, realIP, cookie), &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
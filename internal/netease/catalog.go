@@ -0,0 +1,241 @@
+package netease
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Artist 上游歌曲、专辑中的歌手
+type Artist struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+}
+
+// Album 上游歌曲所属的专辑
+type Album struct {
+	ID     int64  `json:"id"`
+	Name   string `json:"name"`
+	PicURL string `json:"picUrl"`
+}
+
+// Song 上游 /song/detail、歌单、专辑等接口中的歌曲
+type Song struct {
+	ID          int64    `json:"id"`
+	Name        string   `json:"name"`
+	Ar          []Artist `json:"ar"`
+	Al          Album    `json:"al"`
+	Dt          int      `json:"dt"`
+	PublishTime int64    `json:"publishTime"`
+	No          int      `json:"no"`
+	Cd          string   `json:"cd"`
+}
+
+// SongDetailResponse 上游 /song/detail 接口的响应
+type SongDetailResponse struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Songs   []Song `json:"songs"`
+}
+
+// LyricText 一种歌词的LRC文本
+type LyricText struct {
+	Lyric string `json:"lyric"`
+}
+
+// LyricResponse 上游 /lyric 接口的响应
+type LyricResponse struct {
+	Code    int       `json:"code"`
+	Message string    `json:"message"`
+	NoLyric bool      `json:"nolyric"`
+	Lrc     LyricText `json:"lrc"`
+	Tlyric  LyricText `json:"tlyric"`
+	Romalrc LyricText `json:"romalrc"`
+}
+
+// PlaylistCreator 歌单的创建者
+type PlaylistCreator struct {
+	UserID    int64  `json:"userId"`
+	Nickname  string `json:"nickname"`
+	AvatarURL string `json:"avatarUrl"`
+}
+
+// TrackID 歌单中一首曲目的ID
+type TrackID struct {
+	ID int64 `json:"id"`
+}
+
+// PlaylistResponse 上游 /playlist/detail 接口的响应；大歌单的 tracks 会被上游截断，
+// trackIds 始终包含全部曲目
+type PlaylistResponse struct {
+	Code     int    `json:"code"`
+	Message  string `json:"message"`
+	Playlist struct {
+		ID          int64           `json:"id"`
+		Name        string          `json:"name"`
+		Description string          `json:"description"`
+		CoverImgURL string          `json:"coverImgUrl"`
+		TrackCount  int             `json:"trackCount"`
+		Creator     PlaylistCreator `json:"creator"`
+		Tracks      []Song          `json:"tracks"`
+		TrackIDs    []TrackID       `json:"trackIds"`
+	} `json:"playlist"`
+}
+
+// AlbumResponse 上游 /album 接口的响应
+type AlbumResponse struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Album   struct {
+		ID          int64    `json:"id"`
+		Name        string   `json:"name"`
+		PicURL      string   `json:"picUrl"`
+		PublishTime int64    `json:"publishTime"`
+		Description string   `json:"description"`
+		Size        int      `json:"size"`
+		Artists     []Artist `json:"artists"`
+	} `json:"album"`
+	Songs []Song `json:"songs"`
+}
+
+// ArtistResponse 上游 /artists 接口的响应
+type ArtistResponse struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Artist  struct {
+		ID        int64  `json:"id"`
+		Name      string `json:"name"`
+		PicURL    string `json:"picUrl"`
+		BriefDesc string `json:"briefDesc"`
+		AlbumSize int    `json:"albumSize"`
+	} `json:"artist"`
+	HotSongs []Song `json:"hotSongs"`
+}
+
+// AlbumSummary 歌手专辑列表与搜索结果中的专辑
+type AlbumSummary struct {
+	ID          int64  `json:"id"`
+	Name        string `json:"name"`
+	PicURL      string `json:"picUrl"`
+	PublishTime int64  `json:"publishTime"`
+	Size        int    `json:"size"`
+}
+
+// ArtistAlbumsResponse 上游 /artist/album 接口的响应
+type ArtistAlbumsResponse struct {
+	Code      int            `json:"code"`
+	HotAlbums []AlbumSummary `json:"hotAlbums"`
+}
+
+// SearchResponse 上游 /cloudsearch 接口的响应，只有与搜索类型对应的列表有内容
+type SearchResponse struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Result  struct {
+		SongCount int    `json:"songCount"`
+		Songs     []Song `json:"songs"`
+
+		AlbumCount int            `json:"albumCount"`
+		Albums     []AlbumSummary `json:"albums"`
+
+		ArtistCount int `json:"artistCount"`
+		Artists     []struct {
+			ID     int64  `json:"id"`
+			Name   string `json:"name"`
+			PicURL string `json:"picUrl"`
+		} `json:"artists"`
+
+		PlaylistCount int `json:"playlistCount"`
+		Playlists     []struct {
+			ID          int64           `json:"id"`
+			Name        string          `json:"name"`
+			CoverImgURL string          `json:"coverImgUrl"`
+			TrackCount  int             `json:"trackCount"`
+			Creator     PlaylistCreator `json:"creator"`
+		} `json:"playlists"`
+	} `json:"result"`
+}
+
+// SongDetail 实现 Client
+func (c *HTTPClient) SongDetail(ctx context.Context, ids []int64, realIP string) (*SongDetailResponse, error) {
+	values := make([]string, len(ids))
+	for i, id := range ids {
+		values[i] = strconv.FormatInt(id, 10)
+	}
+	params := url.Values{}
+	params.Add("ids", strings.Join(values, ","))
+
+	var resp SongDetailResponse
+	if err := c.getJSON(ctx, URL("/song/detail", params, realIP, ""), &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// Lyric 实现 Client
+func (c *HTTPClient) Lyric(ctx context.Context, id int64, realIP string) (*LyricResponse, error) {
+	var resp LyricResponse
+	if err := c.getJSON(ctx, URL("/lyric", idParams(id), realIP, ""), &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// Playlist 实现 Client
+func (c *HTTPClient) Playlist(ctx context.Context, id int64, realIP string) (*PlaylistResponse, error) {
+	var resp PlaylistResponse
+	if err := c.getJSON(ctx, URL("/playlist/detail", idParams(id), realIP, ""), &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// Album 实现 Client
+func (c *HTTPClient) Album(ctx context.Context, id int64, realIP string) (*AlbumResponse, error) {
+	var resp AlbumResponse
+	if err := c.getJSON(ctx, URL("/album", idParams(id), realIP, ""), &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// Artist 实现 Client
+func (c *HTTPClient) Artist(ctx context.Context, id int64, realIP string) (*ArtistResponse, error) {
+	var resp ArtistResponse
+	if err := c.getJSON(ctx, URL("/artists", idParams(id), realIP, ""), &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// ArtistAlbums 实现 Client
+func (c *HTTPClient) ArtistAlbums(ctx context.Context, id int64, limit int, realIP string) (*ArtistAlbumsResponse, error) {
+	params := idParams(id)
+	params.Add("limit", strconv.Itoa(limit))
+
+	var resp ArtistAlbumsResponse
+	if err := c.getJSON(ctx, URL("/artist/album", params, realIP, ""), &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// Search 实现 Client
+func (c *HTTPClient) Search(ctx context.Context, keywords string, searchType, limit, offset int, realIP string) (*SearchResponse, error) {
+	params := url.Values{}
+	params.Add("keywords", keywords)
+	params.Add("type", strconv.Itoa(searchType))
+	params.Add("limit", strconv.Itoa(limit))
+	params.Add("offset", strconv.Itoa(offset))
+
+	var resp SearchResponse
+	if err := c.getJSON(ctx, URL("/cloudsearch", params, realIP, ""), &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+func idParams(id int64) url.Values {
+	return url.Values{"id": {strconv.FormatInt(id, 10)}}
+}
@@ -0,0 +1,95 @@
+package netease
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+)
+
+// NonJSONError 上游返回的不是JSON，如上游前的代理返回的HTML错误页；属于 ErrParse 的一种
+type NonJSONError struct {
+	Status int
+}
+
+func (e *NonJSONError) Error() string {
+	return fmt.Sprintf("upstream returned non-JSON (status %d)", e.Status)
+}
+
+func (e *NonJSONError) Unwrap() error {
+	return ErrParse
+}
+
+// LooksLikeJSON 判断响应体是否为JSON对象或数组，不依赖 Content-Type：
+// 部分部署以 text/plain 或 text/html 返回JSON，而代理的错误页以 < 开头
+func LooksLikeJSON(body []byte) bool {
+	body = bytes.TrimLeft(bytes.TrimPrefix(body, []byte("\xef\xbb\xbf")), " \t\r\n")
+	return len(body) > 0 && (body[0] == '{' || body[0] == '[')
+}
+
+// flexInt 兼容不同版本上游的数字字段：数字、带引号的数字字符串、小数 (取整)、空字符串与 null 均可解析
+type flexInt int64
+
+func (n *flexInt) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(data, []byte("null")) || bytes.Equal(data, []byte(`""`)) {
+		*n = 0
+		return nil
+	}
+	var num json.Number
+	if err := json.Unmarshal(data, &num); err != nil {
+		return fmt.Errorf("invalid number %s", data)
+	}
+	if i, err := num.Int64(); err == nil {
+		*n = flexInt(i)
+		return nil
+	}
+	f, err := num.Float64()
+	if err != nil {
+		return fmt.Errorf("invalid number %s", data)
+	}
+	*n = flexInt(math.Round(f))
+	return nil
+}
+
+// UnmarshalJSON 数字字段兼容字符串形式，如 "br": "320000"
+func (d *SongURLData) UnmarshalJSON(data []byte) error {
+	type plain SongURLData
+	aux := struct {
+		*plain
+		ID    flexInt `json:"id"`
+		Br    flexInt `json:"br"`
+		Size  flexInt `json:"size"`
+		Code  flexInt `json:"code"`
+		Expi  flexInt `json:"expi"`
+		Fee   flexInt `json:"fee"`
+		Payed flexInt `json:"payed"`
+		Flag  flexInt `json:"flag"`
+	}{plain: (*plain)(d)}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	d.ID, d.Br, d.Size, d.Code = int64(aux.ID), int(aux.Br), int(aux.Size), int(aux.Code)
+	d.Expi, d.Fee, d.Payed, d.Flag = int(aux.Expi), int(aux.Fee), int(aux.Payed), int(aux.Flag)
+	return nil
+}
+
+// SongURLList 上游 data 字段，部分上游版本只请求一首歌时返回单个对象而不是数组
+type SongURLList []SongURLData
+
+func (l *SongURLList) UnmarshalJSON(data []byte) error {
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) > 0 && trimmed[0] == '{' {
+		var single SongURLData
+		if err := json.Unmarshal(trimmed, &single); err != nil {
+			return err
+		}
+		*l = SongURLList{single}
+		return nil
+	}
+	var list []SongURLData
+	if err := json.Unmarshal(trimmed, &list); err != nil {
+		return err
+	}
+	*l = list
+	return nil
+}
@@ -0,0 +1,133 @@
+package netease
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestSongURLResponseTolerantDecoding(t *testing.T) {
+	tests := []struct {
+		name    string
+		fixture string
+		want    SongURLData
+	}{
+		{
+			name:    "numbers",
+			fixture: `{"code":200,"data":[{"id":1,"url":"http://a.mp3","br":320000,"size":100,"code":200,"expi":1200,"fee":8}]}`,
+			want:    SongURLData{ID: 1, URL: "http://a.mp3", Br: 320000, Size: 100, Code: 200, Expi: 1200, Fee: 8},
+		},
+		{
+			name:    "quoted numbers",
+			fixture: `{"code":200,"data":[{"id":"1","url":"http://a.mp3","br":"320000","size":"100","code":"200","expi":"1200"}]}`,
+			want:    SongURLData{ID: 1, URL: "http://a.mp3", Br: 320000, Size: 100, Code: 200, Expi: 1200},
+		},
+		{
+			name:    "fractional, empty and null numbers",
+			fixture: `{"code":200,"data":[{"id":1,"br":319999.6,"size":"","expi":null,"fee":"1.2"}]}`,
+			want:    SongURLData{ID: 1, Br: 320000, Fee: 1},
+		},
+		{
+			name:    "single object instead of array",
+			fixture: `{"code":200,"data":{"id":1,"url":"http://a.mp3","code":200}}`,
+			want:    SongURLData{ID: 1, URL: "http://a.mp3", Code: 200},
+		},
+		{
+			name:    "id beyond int32",
+			fixture: `{"code":200,"data":[{"id":"2147483648123","code":404}]}`,
+			want:    SongURLData{ID: 2147483648123, Code: 404},
+		},
+		{
+			name:    "trial clip with fractional bounds",
+			fixture: `{"code":200,"data":[{"id":1,"code":200,"freeTrialInfo":{"start":0.4,"end":29.6}}]}`,
+			want:    SongURLData{ID: 1, Code: 200, FreeTrialInfo: &FreeTrialInfo{Start: 0, End: 30, Duration: 30}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var resp SongURLResponse
+			if err := json.Unmarshal([]byte(tt.fixture), &resp); err != nil {
+				t.Fatalf("Unmarshal: %v", err)
+			}
+			if len(resp.Data) != 1 {
+				t.Fatalf("data = %+v, want one entry", resp.Data)
+			}
+			if !reflect.DeepEqual(resp.Data[0], tt.want) {
+				t.Errorf("data[0] = %+v, want %+v", resp.Data[0], tt.want)
+			}
+		})
+	}
+}
+
+func TestSongURLResponseRejectsInvalidNumbers(t *testing.T) {
+	for _, fixture := range []string{
+		`{"code":200,"data":[{"id":"abc"}]}`,
+		`{"code":200,"data":[{"br":true}]}`,
+		`{"code":200,"data":"none"}`,
+	} {
+		var resp SongURLResponse
+		if err := json.Unmarshal([]byte(fixture), &resp); err == nil {
+			t.Errorf("Unmarshal(%s) succeeded, want an error", fixture)
+		}
+	}
+}
+
+func TestLooksLikeJSON(t *testing.T) {
+	tests := []struct {
+		body string
+		want bool
+	}{
+		{`{"code":200}`, true},
+		{`[1,2]`, true},
+		{"\xef\xbb\xbf{\"code\":200}", true},
+		{"\r\n  {\"code\":200}", true},
+		{"<html><body>502 Bad Gateway</body></html>", false},
+		{"", false},
+		{"   ", false},
+		{"null", false},
+	}
+	for _, tt := range tests {
+		if got := LooksLikeJSON([]byte(tt.body)); got != tt.want {
+			t.Errorf("LooksLikeJSON(%q) = %t, want %t", tt.body, got, tt.want)
+		}
+	}
+}
+
+func TestNonJSONErrorIsParseError(t *testing.T) {
+	var err error = &NonJSONError{Status: 502}
+	if !errors.Is(err, ErrParse) {
+		t.Errorf("errors.Is(%v, ErrParse) = false", err)
+	}
+}
+
+// FuzzSongURLResponse 任意输入都不能导致解析 panic，成功解析的结果重新编码后应解析为相同的值
+func FuzzSongURLResponse(f *testing.F) {
+	for _, seed := range []string{
+		`{"code":200,"data":[{"id":1,"url":"http://a.mp3","br":320000,"code":200,"expi":1200}]}`,
+		`{"code":200,"data":{"id":"1","br":"128000.5","size":""}}`,
+		`{"code":200,"data":[{"id":1,"freeTrialInfo":{"start":1.5,"end":30}}]}`,
+		`{"code":-460,"message":"cheating"}`,
+		`{"code":200,"data":[{"id":null,"uf":{"a":[1,2]}}]}`,
+		`<html></html>`,
+	} {
+		f.Add([]byte(seed))
+	}
+	f.Fuzz(func(t *testing.T, body []byte) {
+		var resp SongURLResponse
+		if err := json.Unmarshal(body, &resp); err != nil {
+			return
+		}
+		encoded, err := json.Marshal(resp)
+		if err != nil {
+			t.Fatalf("Marshal(%+v): %v", resp, err)
+		}
+		var again SongURLResponse
+		if err := json.Unmarshal(encoded, &again); err != nil {
+			t.Fatalf("decoding re-encoded response %s: %v", encoded, err)
+		}
+		if !reflect.DeepEqual(resp, again) {
+			t.Fatalf("round trip changed the response:\n%+v\n%+v", resp, again)
+		}
+	})
+}
@@ -0,0 +1,229 @@
+package netease
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+)
+
+// Fake 内存中的 Client 实现，按 "id:level" 返回预设的响应或错误，并记录调用次数；
+// 其他接口的响应按方法名与参数预设
+type Fake struct {
+	mu        sync.Mutex
+	responses map[string]*SongURLResponse
+	errs      map[string]error
+	calls     map[string]int
+	songs     map[int64]Song
+	// 其他接口的预设响应与错误，键为 "方法名:参数"；错误只按方法名预设
+	presets     map[string]any
+	methodErrs  map[string]error
+	methodCalls map[string]int
+}
+
+// NewFake 创建空的 Fake，未预设的歌曲、歌单等返回 code 404，未预设的Cookie为未登录
+func NewFake() *Fake {
+	return &Fake{
+		responses:   make(map[string]*SongURLResponse),
+		errs:        make(map[string]error),
+		calls:       make(map[string]int),
+		songs:       make(map[int64]Song),
+		presets:     make(map[string]any),
+		methodErrs:  make(map[string]error),
+		methodCalls: make(map[string]int),
+	}
+}
+
+func fakeKey(id int64, level string) string {
+	return fmt.Sprintf("%d:%s", id, level)
+}
+
+// SetSongURL 预设歌曲在指定音质下的响应
+func (f *Fake) SetSongURL(id int64, level string, resp *SongURLResponse) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.responses[fakeKey(id, level)] = resp
+}
+
+// SetError 预设歌曲在指定音质下返回的错误，如 ErrTimeout
+func (f *Fake) SetError(id int64, level string, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.errs[fakeKey(id, level)] = err
+}
+
+// Calls 返回 SongURL 以指定参数被调用的次数
+func (f *Fake) Calls(id int64, level string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.calls[fakeKey(id, level)]
+}
+
+// SongURL 实现 Client，返回预设响应的副本
+func (f *Fake) SongURL(ctx context.Context, id int64, level, realIP string) (*SongURLResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	key := fakeKey(id, level)
+	f.calls[key]++
+	if err := f.errs[key]; err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, ErrTimeout
+	}
+	resp, ok := f.responses[key]
+	if !ok {
+		return &SongURLResponse{Code: 404}, nil
+	}
+	copied := *resp
+	return &copied, nil
+}
+
+// SetSong 预设 SongDetail 可查到的歌曲
+func (f *Fake) SetSong(song Song) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.songs[song.ID] = song
+}
+
+// SetLyric 预设歌曲的歌词
+func (f *Fake) SetLyric(id int64, resp *LyricResponse) {
+	f.preset("Lyric", strconv.FormatInt(id, 10), resp)
+}
+
+// SetPlaylist 预设歌单详情
+func (f *Fake) SetPlaylist(id int64, resp *PlaylistResponse) {
+	f.preset("Playlist", strconv.FormatInt(id, 10), resp)
+}
+
+// SetAlbum 预设专辑详情
+func (f *Fake) SetAlbum(id int64, resp *AlbumResponse) {
+	f.preset("Album", strconv.FormatInt(id, 10), resp)
+}
+
+// SetArtist 预设歌手信息
+func (f *Fake) SetArtist(id int64, resp *ArtistResponse) {
+	f.preset("Artist", strconv.FormatInt(id, 10), resp)
+}
+
+// SetArtistAlbums 预设歌手的专辑列表
+func (f *Fake) SetArtistAlbums(id int64, resp *ArtistAlbumsResponse) {
+	f.preset("ArtistAlbums", strconv.FormatInt(id, 10), resp)
+}
+
+// SetSearch 预设关键词的搜索结果，不区分搜索类型与分页
+func (f *Fake) SetSearch(keywords string, resp *SearchResponse) {
+	f.preset("Search", keywords, resp)
+}
+
+// SetLoginStatus 预设Cookie的登录状态
+func (f *Fake) SetLoginStatus(cookie string, resp *LoginStatusResponse) {
+	f.preset("LoginStatus", cookie, resp)
+}
+
+// SetLoginRefresh 预设Cookie的续期结果
+func (f *Fake) SetLoginRefresh(cookie string, resp *LoginRefreshResponse) {
+	f.preset("LoginRefresh", cookie, resp)
+}
+
+// SetMethodError 预设除 SongURL 外的方法 (如 "Lyric") 返回的错误，err 为 nil 时清除
+func (f *Fake) SetMethodError(method string, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err == nil {
+		delete(f.methodErrs, method)
+		return
+	}
+	f.methodErrs[method] = err
+}
+
+// MethodCalls 返回除 SongURL 外的方法被调用的次数
+func (f *Fake) MethodCalls(method string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.methodCalls[method]
+}
+
+func (f *Fake) preset(method, key string, resp any) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.presets[method+":"+key] = resp
+}
+
+// fakeCall 记录调用并返回预设的错误或响应的副本，未预设时返回 missing
+func fakeCall[T any](f *Fake, ctx context.Context, method, key string, missing T) (*T, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.methodCalls[method]++
+	if err := f.methodErrs[method]; err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, ErrTimeout
+	}
+	resp, ok := f.presets[method+":"+key].(*T)
+	if !ok {
+		return &missing, nil
+	}
+	copied := *resp
+	return &copied, nil
+}
+
+// SongDetail 实现 Client，按请求顺序返回已预设的歌曲
+func (f *Fake) SongDetail(ctx context.Context, ids []int64, realIP string) (*SongDetailResponse, error) {
+	resp, err := fakeCall(f, ctx, "SongDetail", "", SongDetailResponse{Code: 200})
+	if err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	resp.Songs = []Song{}
+	for _, id := range ids {
+		if song, ok := f.songs[id]; ok {
+			resp.Songs = append(resp.Songs, song)
+		}
+	}
+	return resp, nil
+}
+
+// Lyric 实现 Client
+func (f *Fake) Lyric(ctx context.Context, id int64, realIP string) (*LyricResponse, error) {
+	return fakeCall(f, ctx, "Lyric", strconv.FormatInt(id, 10), LyricResponse{Code: 404})
+}
+
+// Playlist 实现 Client
+func (f *Fake) Playlist(ctx context.Context, id int64, realIP string) (*PlaylistResponse, error) {
+	return fakeCall(f, ctx, "Playlist", strconv.FormatInt(id, 10), PlaylistResponse{Code: 404})
+}
+
+// Album 实现 Client
+func (f *Fake) Album(ctx context.Context, id int64, realIP string) (*AlbumResponse, error) {
+	return fakeCall(f, ctx, "Album", strconv.FormatInt(id, 10), AlbumResponse{Code: 404})
+}
+
+// Artist 实现 Client
+func (f *Fake) Artist(ctx context.Context, id int64, realIP string) (*ArtistResponse, error) {
+	return fakeCall(f, ctx, "Artist", strconv.FormatInt(id, 10), ArtistResponse{Code: 404})
+}
+
+// ArtistAlbums 实现 Client
+func (f *Fake) ArtistAlbums(ctx context.Context, id int64, limit int, realIP string) (*ArtistAlbumsResponse, error) {
+	return fakeCall(f, ctx, "ArtistAlbums", strconv.FormatInt(id, 10), ArtistAlbumsResponse{Code: 404})
+}
+
+// Search 实现 Client，未预设的关键词返回空结果
+func (f *Fake) Search(ctx context.Context, keywords string, searchType, limit, offset int, realIP string) (*SearchResponse, error) {
+	return fakeCall(f, ctx, "Search", keywords, SearchResponse{Code: 200})
+}
+
+// LoginStatus 实现 Client
+func (f *Fake) LoginStatus(ctx context.Context, cookie, realIP string) (*LoginStatusResponse, error) {
+	return fakeCall(f, ctx, "LoginStatus", cookie, LoginStatusResponse{})
+}
+
+// LoginRefresh 实现 Client
+func (f *Fake) LoginRefresh(ctx context.Context, cookie, realIP string) (*LoginRefreshResponse, error) {
+	return fakeCall(f, ctx, "LoginRefresh", cookie, LoginRefreshResponse{Code: 301})
+}
@@ -0,0 +1,151 @@
+// Package netease 封装对网易云音乐API (NeteaseCloudMusicApi) 的访问，
+// 处理函数通过 Client 接口获取数据，便于替换为其他实现
+package netease
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// 上游请求失败的原因，调用方据此决定返回给客户端的状态码与是否重试
+var (
+	ErrTimeout   = errors.New("music service request timed out")
+	ErrRequest   = errors.New("failed to request music service")
+	ErrBadStatus = errors.New("music service returned bad status")
+	ErrRead      = errors.New("failed to read response from music service")
+	ErrParse     = errors.New("failed to parse response from music service")
+	// ErrCanceled 客户端断开导致请求被取消，不代表上游异常
+	ErrCanceled = errors.New("music service request canceled")
+	// ErrProxy 无法通过代理连接上游，属于 ErrRequest 的一种，同样可重试
+	ErrProxy = fmt.Errorf("%w: proxy connection failed", ErrRequest)
+)
+
+// SongURLData 单首歌曲的播放地址信息
+type SongURLData struct {
+	ID            int64          `json:"id"`
+	URL           string         `json:"url"`
+	Br            int            `json:"br"`
+	Size          int            `json:"size"`
+	MD5           string         `json:"md5"`
+	Code          int            `json:"code"`
+	Expi          int            `json:"expi"`
+	Type          string         `json:"type"`
+	Gain          float64        `json:"gain"`
+	Peak          float64        `json:"peak"`
+	Fee           int            `json:"fee"`
+	Uf            interface{}    `json:"uf"`
+	Payed         int            `json:"payed"`
+	Flag          int            `json:"flag"`
+	CanExtend     bool           `json:"canExtend"`
+	FreeTrialInfo *FreeTrialInfo `json:"freeTrialInfo"`
+	Level         string         `json:"level"`
+}
+
+// FreeTrialInfo 试听片段在歌曲中的起止位置 (秒)，上游只返回试听片段时非空
+type FreeTrialInfo struct {
+	Start    int `json:"start"`
+	End      int `json:"end"`
+	Duration int `json:"duration"`
+}
+
+// UnmarshalJSON 读取上游的 start、end (可能为小数)，并计算片段时长
+func (f *FreeTrialInfo) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Start float64 `json:"start"`
+		End   float64 `json:"end"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	f.Start = int(math.Round(raw.Start))
+	f.End = int(math.Round(raw.End))
+	f.Duration = max(f.End-f.Start, 0)
+	return nil
+}
+
+// SongURLResponse 上游 /song/url/v1 接口的响应
+type SongURLResponse struct {
+	Code int `json:"code"`
+	// 上游返回非200 code 时的说明
+	Message string      `json:"message,omitempty"`
+	Data    SongURLList `json:"data"`
+}
+
+// Client 网易云音乐API客户端；上游返回的非200 code 均不视为错误，由调用方判断
+type Client interface {
+	// SongURL 获取歌曲在指定音质下的播放地址
+	SongURL(ctx context.Context, id int64, level, realIP string) (*SongURLResponse, error)
+	// SongDetail 一次查询多首歌曲的元数据，上游不存在的歌曲不出现在结果中
+	SongDetail(ctx context.Context, ids []int64, realIP string) (*SongDetailResponse, error)
+	Lyric(ctx context.Context, id int64, realIP string) (*LyricResponse, error)
+	Playlist(ctx context.Context, id int64, realIP string) (*PlaylistResponse, error)
+	Album(ctx context.Context, id int64, realIP string) (*AlbumResponse, error)
+	// Artist 获取歌手信息与热门歌曲
+	Artist(ctx context.Context, id int64, realIP string) (*ArtistResponse, error)
+	// ArtistAlbums 获取歌手最近的 limit 张专辑
+	ArtistAlbums(ctx context.Context, id int64, limit int, realIP string) (*ArtistAlbumsResponse, error)
+	// Search 按 cloudsearch 的 type (1 歌曲、10 专辑、100 歌手、1000 歌单) 搜索
+	Search(ctx context.Context, keywords string, searchType, limit, offset int, realIP string) (*SearchResponse, error)
+	// LoginStatus 使用指定Cookie查询登录状态，不经过Cookie池
+	LoginStatus(ctx context.Context, cookie, realIP string) (*LoginStatusResponse, error)
+	// LoginRefresh 使用指定Cookie续期，返回新的 Set-Cookie
+	LoginRefresh(ctx context.Context, cookie, realIP string) (*LoginRefreshResponse, error)
+}
+
+// Transport 发送上游请求并返回响应体，apiURL 为 URL 生成的路径与查询参数；
+// 实例地址、Cookie、重试与熔断均由实现负责，失败时返回本包定义的错误
+type Transport interface {
+	Get(ctx context.Context, apiURL string) ([]byte, error)
+}
+
+// HTTPClient 通过 Transport 访问上游的 Client 实现
+type HTTPClient struct {
+	transport Transport
+}
+
+// NewHTTPClient 创建使用 transport 发送请求的客户端
+func NewHTTPClient(transport Transport) *HTTPClient {
+	return &HTTPClient{transport: transport}
+}
+
+// SongURL 实现 Client
+func (c *HTTPClient) SongURL(ctx context.Context, id int64, level, realIP string) (*SongURLResponse, error) {
+	params := url.Values{}
+	params.Add("id", strconv.FormatInt(id, 10))
+	params.Add("level", level)
+
+	var resp SongURLResponse
+	if err := c.getJSON(ctx, URL("/song/url/v1", params, realIP, ""), &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+func (c *HTTPClient) getJSON(ctx context.Context, apiURL string, dst any) error {
+	body, err := c.transport.Get(ctx, apiURL)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(body, dst); err != nil {
+		return fmt.Errorf("%w: %v", ErrParse, err)
+	}
+	return nil
+}
+
+// URL 构建上游接口的路径与查询参数，统一附加毫秒时间戳与 realIP 参数；
+// cookie 不为空时通过 cookie 参数传给 Transport，实际发送方式由 Transport 决定
+func URL(path string, params url.Values, realIP, cookie string) string {
+	params.Add("timestamp", strconv.FormatInt(time.Now().UnixMilli(), 10))
+	if cookie != "" {
+		params.Add("cookie", cookie)
+	}
+	params.Add("realIP", realIP)
+
+	return path + "?" + params.Encode()
+}
@@ -0,0 +1,31 @@
+package server
+
+import (
+	"net/http/pprof"
+	"runtime"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 开启 pprof 时的互斥锁与阻塞采样率：平均每100次锁竞争采样一次，阻塞超过1ms的事件采样
+const (
+	pprofMutexProfileFraction = 100
+	pprofBlockProfileRate     = 1_000_000
+)
+
+// registerPprof 在 /debug/pprof/ 下注册 net/http/pprof 的处理函数，group 需已挂载鉴权中间件；
+// 同时开启互斥锁与阻塞采样，否则 mutex、block 采样为空
+func registerPprof(group *gin.RouterGroup) {
+	runtime.SetMutexProfileFraction(pprofMutexProfileFraction)
+	runtime.SetBlockProfileRate(pprofBlockProfileRate)
+
+	group.GET("/", gin.WrapF(pprof.Index))
+	group.GET("/cmdline", gin.WrapF(pprof.Cmdline))
+	group.GET("/profile", gin.WrapF(pprof.Profile))
+	group.GET("/symbol", gin.WrapF(pprof.Symbol))
+	group.POST("/symbol", gin.WrapF(pprof.Symbol))
+	group.GET("/trace", gin.WrapF(pprof.Trace))
+	for _, name := range []string{"goroutine", "heap", "allocs", "mutex", "block", "threadcreate"} {
+		group.GET("/"+name, gin.WrapH(pprof.Handler(name)))
+	}
+}
@@ -0,0 +1,52 @@
+package server
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"PMS/internal/config"
+	"PMS/internal/handlers"
+	"PMS/internal/logging"
+)
+
+// WatchConfigReload 收到 SIGHUP 时重新读取 .env、配置文件、环境变量与Cookie文件并替换当前配置，
+// 配置无效或Cookie读取失败时拒绝本次重新加载，保留旧配置与旧Cookie
+func WatchConfigReload() {
+	sighup := make(chan os.Signal, 1)
+	signal.Notify(sighup, syscall.SIGHUP)
+
+	for range sighup {
+		reloadConfig()
+	}
+}
+
+func reloadConfig() {
+	next, cookie, err := config.Reload(handlers.LoadCookie, handlers.AnonymousMode())
+	if err != nil {
+		logging.Logger.Error("config reload rejected, keeping current config", "error", err)
+		return
+	}
+
+	changed, restartRequired := config.Diff(config.Current(), next)
+	config.Store(next)
+	if cookie != "" {
+		handlers.SetCookie(cookie)
+		changed = append(changed, slog.String("cookie", handlers.RedactCookie(cookie)))
+	}
+	if len(restartRequired) > 0 {
+		logging.Logger.Warn("config changes require a restart to take effect", "fields", restartRequired)
+	}
+	logging.Logger.LogAttrs(context.Background(), slog.LevelInfo, "config reloaded", slog.Group("changed", attrsToAny(changed)...))
+}
+
+// attrsToAny 将 slog.Attr 列表转换为 slog.Group 接受的参数
+func attrsToAny(attrs []slog.Attr) []any {
+	args := make([]any, len(attrs))
+	for i, attr := range attrs {
+		args[i] = attr
+	}
+	return args
+}
@@ -0,0 +1,188 @@
+// Package server 组装路由与中间件，并负责HTTP/HTTPS监听、证书重新加载、配置重新加载与优雅停机
+package server
+
+import (
+	"slices"
+	"strings"
+
+	"PMS/internal/config"
+	"PMS/internal/handlers"
+	"PMS/internal/logging"
+	"PMS/internal/metrics"
+	"PMS/internal/middleware"
+	"PMS/internal/netease"
+	"PMS/internal/tracing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 健康检查路径，停机期间与开启API Key时均不拦截
+var healthCheckPaths = map[string]bool{
+	"/health":  true,
+	"/live":    true,
+	"/ready":   true,
+	"/healthz": true,
+	"/readyz":  true,
+}
+
+// 文档相关路径，开启API Key时不拦截
+var docsPaths = map[string]bool{
+	"/openapi.json": true,
+	"/docs":         true,
+	"/docs/init.js": true,
+	// 嵌入的 Swagger UI 文件
+	"/docs/swagger-ui.css":       true,
+	"/docs/swagger-ui-bundle.js": true,
+}
+
+// NewRouter 按配置创建包含全部中间件与路由的 Gin 引擎，歌曲播放地址、歌词等数据通过 client 获取，
+// 健康检查通过 transport 探测各上游实例；需先调用 handlers.Setup 初始化缓存
+func NewRouter(cfg *config.Config, client netease.Client, transport *handlers.UpstreamTransport) *gin.Engine {
+	r := gin.New()
+	setTrustedProxies(r, cfg, cfg.Port)
+
+	// 中间件
+	r.Use(middleware.RequestID())
+	r.Use(middleware.RequestLog())
+	r.Use(middleware.Shutdown(isHealthCheckPath))
+	r.Use(gin.Recovery())
+	r.Use(metrics.Middleware())
+	r.Use(tracing.Middleware())
+	if cfg.GzipEnabled {
+		r.Use(middleware.Gzip(cfg.GzipLevel, cfg.GzipMinLength, isGzipExempt))
+	}
+	r.Use(middleware.JSONP())
+	r.Use(middleware.CORS(cfg.AllowedOrigins, cfg.CORSMaxAge))
+	r.Use(middleware.SecurityHeaders(cfg.SecurityHeaders, cfg.HSTS))
+	// RATE_LIMIT=0 时关闭限流，适用于私有部署
+	if cfg.RateLimitRPS > 0 {
+		r.Use(middleware.RateLimit(middleware.NewIPRateLimiter(cfg.RateLimitRPS, cfg.RateLimitBurst), isRateLimitExempt))
+	}
+	r.Use(middleware.APIKey(cfg.APIKeys, isPublicPath))
+	r.Use(middleware.Quota(handlers.QuotaUsage, handlers.QuotaReset))
+	r.Use(middleware.FeatureFlags(cfg.FeatureFlagsEnabled))
+
+	// 健康检查：/health 探测上游，不是 /healthz 的别名；各接口的区别参见 README
+	health := handlers.NewHealthService(transport)
+	r.GET("/health", health.GetHealth)
+	// 供Kubernetes使用的存活与就绪探针；/live 与 /healthz 相同，/ready 持续检查上游与Cookie，/readyz 只反映启动检查
+	r.GET("/live", handlers.GetHealthz)
+	r.GET("/ready", health.GetReady)
+	r.GET("/healthz", handlers.GetHealthz)
+	r.GET("/readyz", handlers.GetReadyz)
+
+	// API文档
+	r.GET("/openapi.json", handlers.GetOpenAPISpec)
+	r.GET("/docs", handlers.GetDocs)
+	r.GET("/docs/init.js", handlers.GetDocsInit)
+	r.GET("/docs/swagger-ui.css", handlers.GetDocsAsset)
+	r.GET("/docs/swagger-ui-bundle.js", handlers.GetDocsAsset)
+
+	// Prometheus指标，设置了 METRICS_ADDR 时改由单独的端口提供
+	if cfg.MetricsEnabled && cfg.MetricsAddr == "" {
+		r.GET("/metrics", metrics.Handler(cfg.MetricsToken))
+	}
+
+	// API路由 - 简化路径
+	songs := handlers.NewSongURLService(client)
+	catalog := handlers.NewCatalogService(client)
+	accounts := handlers.NewAccountService(client)
+	r.GET("/song", songs.GetSongURL)
+	r.GET("/songs", songs.BatchGetSongURLsByQuery)
+	r.POST("/songs", songs.BatchGetSongURLs)
+	r.POST("/check", songs.CheckSongs)
+	r.GET("/lyric", catalog.GetLyric)
+	r.GET("/stream", songs.StreamSong)
+	r.GET("/stream/:id", songs.StreamSong)
+	r.GET("/download", songs.DownloadSong)
+	r.GET("/search", catalog.SearchSongs)
+	r.GET("/detail", catalog.GetSongDetail)
+	r.GET("/playlist", songs.GetPlaylist)
+	r.GET("/album", catalog.GetAlbum)
+	r.GET("/artist", catalog.GetArtist)
+	r.GET("/cover", catalog.GetCover)
+	r.GET("/cookie/status", handlers.GetCookieStatus)
+	r.GET("/events", handlers.GetEvents)
+
+	// 模拟模式下 /song 返回的音频由PMS自身提供
+	if cfg.MockUpstream {
+		r.GET(handlers.MockAudioPath, handlers.GetMockAudio)
+	}
+
+	// 管理接口，由 ADMIN_TOKEN 单独保护
+	admin := r.Group("/admin", middleware.AdminAuth(cfg.AdminToken))
+	admin.GET("/cookie", handlers.GetAdminCookie)
+	admin.POST("/cookie", accounts.UpdateAdminCookie)
+	admin.POST("/check-cookie", accounts.CheckAdminCookie)
+	admin.GET("/cache", handlers.GetAdminCache)
+	admin.DELETE("/cache", handlers.DeleteAdminCache)
+	admin.GET("/cache/keys", handlers.GetAdminCacheKeys)
+	admin.POST("/cache/purge", handlers.PurgeAdminCache)
+	admin.GET("/config", handlers.GetAdminConfig)
+	admin.PATCH("/config", handlers.PatchAdminConfig)
+	admin.GET("/health", health.GetAdminHealth)
+
+	// webhook：注册与普通接口相同，查看与删除由 ADMIN_TOKEN 保护
+	if cfg.WebhooksEnabled {
+		r.POST("/webhooks/register", handlers.RegisterWebhook)
+		hooks := r.Group("/webhooks", middleware.AdminAuth(cfg.AdminToken))
+		hooks.GET("", handlers.ListWebhooks)
+		hooks.DELETE("/:id", handlers.DeleteWebhook)
+	}
+
+	// 性能分析接口，默认关闭，与管理接口共用 ADMIN_TOKEN
+	if cfg.PprofEnabled {
+		registerPprof(r.Group("/debug/pprof", middleware.AdminAuth(cfg.AdminToken)))
+	}
+
+	return r
+}
+
+// newMetricsRouter 创建只提供 /metrics 的 Gin 引擎，供 METRICS_ADDR 上的单独服务使用
+func newMetricsRouter(cfg *config.Config) *gin.Engine {
+	r := gin.New()
+	setTrustedProxies(r, cfg, cfg.MetricsAddr)
+	r.Use(gin.Recovery())
+	r.GET("/metrics", metrics.Handler(cfg.MetricsToken))
+	return r
+}
+
+// setTrustedProxies 只信任 TRUSTED_PROXIES 中的代理传入的 X-Forwarded-For 与 X-Real-IP，
+// 默认不信任任何代理，ClientIP 即连接的对端地址，客户端无法伪造IP绕过限流；
+// addr 为Unix域套接字时额外信任本机，与 unixSocketHandler 改写的对端地址对应
+func setTrustedProxies(r *gin.Engine, cfg *config.Config, addr string) {
+	proxies := cfg.TrustedProxies
+	if strings.HasPrefix(addr, unixSocketPrefix) {
+		proxies = append(slices.Clone(proxies), unixSocketPeer)
+	}
+	// 条目已在 config.Load 中校验
+	if err := r.SetTrustedProxies(proxies); err != nil {
+		logging.Logger.Error("invalid trusted proxies", "error", err)
+	}
+}
+
+func isHealthCheckPath(path string) bool {
+	return healthCheckPaths[path]
+}
+
+// isGzipExempt 音频与封面代理返回的内容本身已压缩，且需要保持 Content-Length 与 Range 语义，不经过gzip
+func isGzipExempt(path string) bool {
+	return path == "/stream" || strings.HasPrefix(path, "/stream/") || path == "/download" || path == "/cover"
+}
+
+// isRateLimitExempt 健康检查与 /metrics 不受限流
+func isRateLimitExempt(path string) bool {
+	return healthCheckPaths[path] || path == "/metrics"
+}
+
+// isPublicPath 健康检查与文档始终开放，/metrics 由 METRICS_TOKEN 单独保护，
+// /admin、/debug/pprof 与除注册外的 /webhooks 由 ADMIN_TOKEN 单独保护
+func isPublicPath(path string) bool {
+	return healthCheckPaths[path] || docsPaths[path] || path == "/metrics" ||
+		strings.HasPrefix(path, "/admin/") || strings.HasPrefix(path, "/debug/pprof/") ||
+		isWebhookAdminPath(path)
+}
+
+func isWebhookAdminPath(path string) bool {
+	return path == "/webhooks" || strings.HasPrefix(path, "/webhooks/") && path != "/webhooks/register"
+}
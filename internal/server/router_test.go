@@ -0,0 +1,72 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"PMS/internal/handlers"
+	"PMS/internal/netease"
+)
+
+func TestRouter(t *testing.T) {
+	tests := []struct {
+		name       string
+		env        map[string]string
+		method     string
+		target     string
+		header     map[string]string
+		wantStatus int
+	}{
+		{name: "liveness", method: http.MethodGet, target: "/healthz", wantStatus: http.StatusOK},
+		{name: "song", method: http.MethodGet, target: "/song?id=1", wantStatus: http.StatusOK},
+		{name: "invalid song id", method: http.MethodGet, target: "/song?id=abc", wantStatus: http.StatusBadRequest},
+		{name: "unknown route", method: http.MethodGet, target: "/nope", wantStatus: http.StatusNotFound},
+		{name: "openapi", method: http.MethodGet, target: "/openapi.json", wantStatus: http.StatusOK},
+		{name: "admin disabled", method: http.MethodGet, target: "/admin/config", wantStatus: http.StatusForbidden},
+		{name: "admin token required", env: map[string]string{"ADMIN_TOKEN": "admin"},
+			method: http.MethodGet, target: "/admin/config", wantStatus: http.StatusUnauthorized},
+		{name: "admin token", env: map[string]string{"ADMIN_TOKEN": "admin"},
+			method: http.MethodGet, target: "/admin/config", header: map[string]string{"Authorization": "Bearer admin"}, wantStatus: http.StatusOK},
+		{name: "admin health token required", env: map[string]string{"ADMIN_TOKEN": "admin"},
+			method: http.MethodGet, target: "/admin/health", wantStatus: http.StatusUnauthorized},
+		{name: "api key required", env: map[string]string{"API_KEYS": "secret"},
+			method: http.MethodGet, target: "/song?id=1", wantStatus: http.StatusUnauthorized},
+		{name: "api key", env: map[string]string{"API_KEYS": "secret"},
+			method: http.MethodGet, target: "/song?id=1", header: map[string]string{"X-API-Key": "secret"}, wantStatus: http.StatusOK},
+		{name: "health checks skip api key", env: map[string]string{"API_KEYS": "secret"},
+			method: http.MethodGet, target: "/healthz", wantStatus: http.StatusOK},
+		{name: "cors preflight", method: http.MethodOptions, target: "/song",
+			header: map[string]string{"Origin": "https://app.example.com"}, wantStatus: http.StatusNoContent},
+		{name: "webhooks disabled", method: http.MethodPost, target: "/webhooks/register", wantStatus: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := map[string]string{"RATE_LIMIT": "0"}
+			for key, value := range tt.env {
+				env[key] = value
+			}
+			cfg := loadTestConfig(t, env)
+			fake := netease.NewFake()
+			fake.SetSongURL(1, "standard", &netease.SongURLResponse{
+				Code: 200,
+				Data: netease.SongURLList{{ID: 1, URL: "http://m701.music.126.net/test.mp3", Br: 128000, Code: 200, Expi: 1200, Type: "mp3"}},
+			})
+			r := NewRouter(cfg, fake, handlers.NewUpstreamTransport(cfg))
+
+			req := httptest.NewRequest(tt.method, tt.target, nil)
+			for key, value := range tt.header {
+				req.Header.Set(key, value)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("%s %s: status = %d, want %d; body %s", tt.method, tt.target, w.Code, tt.wantStatus, w.Body)
+			}
+			if w.Header().Get("X-Request-ID") == "" {
+				t.Errorf("%s %s: X-Request-ID missing", tt.method, tt.target)
+			}
+		})
+	}
+}
@@ -0,0 +1,133 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"PMS/internal/config"
+	"PMS/internal/logging"
+	"PMS/internal/middleware"
+)
+
+// 等待进行中请求完成时的检查间隔
+const drainPollInterval = 100 * time.Millisecond
+
+// Run 在 PORT 上提供 handler，配置了证书时同时在 TLS_PORT 上提供HTTPS，
+// HTTP端口继续提供服务或仅做重定向，设置了 METRICS_ADDR 时在该地址单独提供 /metrics；
+// 阻塞直到收到停机信号并完成停机
+func Run(cfg *config.Config, handler http.Handler) error {
+	servers := []*http.Server{newHTTPServer(listenAddr(cfg.Port), handler, cfg)}
+	if cfg.TLSCertFile != "" {
+		reloader, err := newCertReloader(cfg.TLSCertFile, cfg.TLSKeyFile)
+		if err != nil {
+			return fmt.Errorf("load TLS certificate %s: %w", cfg.TLSCertFile, err)
+		}
+		go reloader.watch(cfg.TLSReloadInterval)
+
+		if cfg.HTTPRedirectToHTTPS {
+			servers[0].Handler = httpsRedirectHandler(cfg.TLSPort)
+		}
+		tlsServer := newHTTPServer(listenAddr(cfg.TLSPort), handler, cfg)
+		tlsServer.TLSConfig = newTLSConfig(reloader)
+		servers = append(servers, tlsServer)
+		logging.Logger.Info("TLS enabled",
+			"tls_port", cfg.TLSPort,
+			"http_redirect_to_https", cfg.HTTPRedirectToHTTPS,
+		)
+	}
+	if cfg.MetricsEnabled && cfg.MetricsAddr != "" {
+		servers = append(servers, newHTTPServer(cfg.MetricsAddr, newMetricsRouter(cfg), cfg))
+		logging.Logger.Info("metrics served on separate address", "metrics_addr", cfg.MetricsAddr)
+	}
+	return serve(cfg.ShutdownTimeout, cfg.SocketMode, servers...)
+}
+
+// serve 启动所有服务（配置了 TLSConfig 的以HTTPS监听，地址以 unix: 开头的监听Unix域套接字）。
+// 收到 SIGINT/SIGTERM 后新请求返回503，在 drainTimeout 内等待进行中的请求完成后关闭服务，
+// 超时则强制关闭剩余连接
+func serve(drainTimeout time.Duration, socketMode os.FileMode, servers ...*http.Server) error {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	listeners := make([]net.Listener, 0, len(servers))
+	for _, srv := range servers {
+		ln, err := listen(srv.Addr, socketMode)
+		if err != nil {
+			for _, ln := range listeners {
+				ln.Close()
+			}
+			return fmt.Errorf("listen on %s: %w", srv.Addr, err)
+		}
+		listeners = append(listeners, ln)
+	}
+
+	errCh := make(chan error, len(servers))
+	for i, srv := range servers {
+		go func() {
+			if srv.TLSConfig != nil {
+				errCh <- srv.ServeTLS(listeners[i], "", "")
+				return
+			}
+			errCh <- srv.Serve(listeners[i])
+		}()
+	}
+
+	select {
+	case err := <-errCh:
+		for _, srv := range servers {
+			srv.Close()
+		}
+		return err
+	case <-ctx.Done():
+	}
+	stop()
+
+	middleware.BeginShutdown()
+	logging.Logger.Info("shutting down, draining in-flight requests",
+		"drain_timeout", drainTimeout.String(),
+		"in_flight", middleware.InFlight(),
+	)
+
+	drainCtx, cancel := context.WithTimeout(context.Background(), drainTimeout)
+	defer cancel()
+	waitForDrain(drainCtx)
+
+	var wg sync.WaitGroup
+	for _, srv := range servers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := srv.Shutdown(drainCtx); err != nil {
+				logging.Logger.Warn("drain timeout exceeded, closing remaining connections",
+					"addr", srv.Addr,
+					"in_flight", middleware.InFlight(),
+					"error", err,
+				)
+				srv.Close()
+			}
+		}()
+	}
+	wg.Wait()
+	logging.Logger.Info("server stopped")
+	return nil
+}
+
+// waitForDrain 等待进行中的请求全部完成或 ctx 到期
+func waitForDrain(ctx context.Context) {
+	ticker := time.NewTicker(drainPollInterval)
+	defer ticker.Stop()
+	for middleware.InFlight() > 0 {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
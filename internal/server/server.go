@@ -0,0 +1,95 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+
+	"PMS/internal/config"
+)
+
+// PORT 以该前缀开头时监听Unix域套接字，如 unix:/run/pms/pms.sock
+const unixSocketPrefix = "unix:"
+
+// listenAddr 将 PORT 配置转换为 http.Server 的地址，Unix域套接字原样保留
+func listenAddr(port string) string {
+	if strings.HasPrefix(port, unixSocketPrefix) {
+		return port
+	}
+	return ":" + port
+}
+
+// listen 按服务地址监听TCP端口或Unix域套接字；
+// 套接字文件在关闭监听时由标准库删除，启动时清理上次异常退出残留的文件
+func listen(addr string, socketMode os.FileMode) (net.Listener, error) {
+	path, ok := strings.CutPrefix(addr, unixSocketPrefix)
+	if !ok {
+		return net.Listen("tcp", addr)
+	}
+
+	if err := removeStaleSocket(path); err != nil {
+		return nil, err
+	}
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, socketMode); err != nil {
+		ln.Close()
+		return nil, err
+	}
+	return ln, nil
+}
+
+// removeStaleSocket 删除无进程监听的残留套接字文件；仍有进程在监听或路径不是套接字时返回错误
+func removeStaleSocket(path string) error {
+	info, err := os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if info.Mode()&os.ModeSocket == 0 {
+		return fmt.Errorf("%s exists and is not a socket", path)
+	}
+	if conn, err := net.Dial("unix", path); err == nil {
+		conn.Close()
+		return fmt.Errorf("%s is in use by another process", path)
+	}
+	return os.Remove(path)
+}
+
+// Unix域套接字连接的对端地址改写为该IP，路由器会信任来自该地址的转发头
+const unixSocketPeer = "127.0.0.1"
+
+// unixSocketHandler 将Unix域套接字连接的对端地址视为本机；监听Unix域套接字的路由器信任本机 (及 TRUSTED_PROXIES)
+// 传入的 X-Forwarded-For，使同机反向代理转发的真实客户端IP用于限流与日志，其他来源的转发头仍被忽略
+func unixSocketHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if _, _, err := net.SplitHostPort(req.RemoteAddr); err != nil {
+			req.RemoteAddr = net.JoinHostPort(unixSocketPeer, "0")
+		}
+		next.ServeHTTP(w, req)
+	})
+}
+
+// newHTTPServer 按 SERVER_* 配置创建带超时的 http.Server，防止慢速连接与空闲长连接耗尽资源；
+// WriteTimeout 对整个响应生效，/stream 与 /download 通过 extendWriteDeadline 单独放宽
+func newHTTPServer(addr string, handler http.Handler, cfg *config.Config) *http.Server {
+	if strings.HasPrefix(addr, unixSocketPrefix) {
+		handler = unixSocketHandler(handler)
+	}
+	return &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: cfg.ServerReadHeaderTimeout,
+		ReadTimeout:       cfg.ServerReadTimeout,
+		WriteTimeout:      cfg.ServerWriteTimeout,
+		IdleTimeout:       cfg.ServerIdleTimeout,
+		MaxHeaderBytes:    cfg.ServerMaxHeaderBytes,
+	}
+}
@@ -0,0 +1,173 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"PMS/internal/config"
+	"PMS/internal/handlers"
+	"PMS/internal/netease"
+
+	"github.com/gin-gonic/gin"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+// socketPath 返回临时目录中的套接字路径；t.TempDir 的路径可能超出 sun_path 的长度限制
+func socketPath(t *testing.T) string {
+	t.Helper()
+	dir, err := os.MkdirTemp("", "pms")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	return filepath.Join(dir, "pms.sock")
+}
+
+// loadTestConfig 以默认值加上 env 加载配置并设为当前配置，测试结束后恢复原配置
+func loadTestConfig(t *testing.T, env map[string]string) *config.Config {
+	t.Helper()
+	for key, value := range env {
+		t.Setenv(key, value)
+	}
+	cfg, err := config.Load(true)
+	if err != nil {
+		t.Fatalf("config.Load: %v", err)
+	}
+	prev := config.Current()
+	config.Store(cfg)
+	t.Cleanup(func() { config.Store(prev) })
+	return cfg
+}
+
+// serveUnix 在 addr 指定的Unix域套接字上提供 handler，返回经该套接字连接的客户端
+func serveUnix(t *testing.T, addr string, handler http.Handler, cfg *config.Config) *http.Client {
+	t.Helper()
+	srv := newHTTPServer(addr, handler, cfg)
+	ln, err := listen(addr, cfg.SocketMode)
+	if err != nil {
+		t.Fatalf("listen %s: %v", addr, err)
+	}
+	go srv.Serve(ln)
+	t.Cleanup(func() { srv.Close() })
+
+	path := addr[len(unixSocketPrefix):]
+	return &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", path)
+		},
+	}}
+}
+
+func TestHealthOverUnixSocket(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"code":200}`)
+	}))
+	defer upstream.Close()
+
+	path := socketPath(t)
+	cfg := loadTestConfig(t, map[string]string{
+		"PORT":              unixSocketPrefix + path,
+		"SOCKET_MODE":       "0600",
+		"NETEASE_MUSIC_API": upstream.URL,
+		"RATE_LIMIT":        "0",
+	})
+	transport := handlers.NewUpstreamTransport(cfg)
+	router := NewRouter(cfg, netease.NewHTTPClient(transport), transport)
+	client := serveUnix(t, listenAddr(cfg.Port), router, cfg)
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("stat socket: %v", err)
+	}
+	if info.Mode()&os.ModeSocket == 0 || info.Mode().Perm() != 0o600 {
+		t.Errorf("socket mode = %s, want a socket with permissions 0600", info.Mode())
+	}
+
+	resp, err := client.Get("http://pms/health")
+	if err != nil {
+		t.Fatalf("GET /health over unix socket: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		t.Fatalf("status = %d, want %d; body %s", resp.StatusCode, http.StatusOK, body)
+	}
+	var health struct {
+		Status            string `json:"status"`
+		UpstreamReachable bool   `json:"upstream_reachable"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&health); err != nil {
+		t.Fatalf("decoding /health: %v", err)
+	}
+	if health.Status != "ok" || !health.UpstreamReachable {
+		t.Errorf("/health = %+v, want ok with upstream reachable", health)
+	}
+}
+
+func TestUnixSocketTrustsLocalForwardedFor(t *testing.T) {
+	addr := unixSocketPrefix + socketPath(t)
+	cfg := loadTestConfig(t, nil)
+	r := gin.New()
+	setTrustedProxies(r, cfg, addr)
+	r.GET("/ip", func(c *gin.Context) { c.String(http.StatusOK, c.ClientIP()) })
+	client := serveUnix(t, addr, r, cfg)
+
+	req, _ := http.NewRequest(http.MethodGet, "http://pms/ip", nil)
+	req.Header.Set("X-Forwarded-For", "198.51.100.7")
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("GET /ip: %v", err)
+	}
+	defer resp.Body.Close()
+	if body, _ := io.ReadAll(resp.Body); string(body) != "198.51.100.7" {
+		t.Errorf("ClientIP = %q, want the address forwarded by the local proxy", body)
+	}
+}
+
+func TestListenRemovesStaleSocket(t *testing.T) {
+	path := socketPath(t)
+	// 关闭时不删除套接字文件，模拟异常退出后的残留
+	stale, err := net.ListenUnix("unix", &net.UnixAddr{Name: path, Net: "unix"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale.SetUnlinkOnClose(false)
+	stale.Close()
+
+	ln, err := listen(unixSocketPrefix+path, 0o660)
+	if err != nil {
+		t.Fatalf("listen over stale socket: %v", err)
+	}
+	defer ln.Close()
+
+	// 仍在监听的套接字不能被替换
+	if ln2, err := listen(unixSocketPrefix+path, 0o660); err == nil {
+		ln2.Close()
+		t.Error("listen succeeded on a socket that is in use")
+	}
+}
+
+func TestListenRejectsNonSocketPath(t *testing.T) {
+	path := socketPath(t)
+	if err := os.WriteFile(path, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if ln, err := listen(unixSocketPrefix+path, 0o660); err == nil {
+		ln.Close()
+		t.Error("listen succeeded on a regular file")
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("regular file was removed: %v", err)
+	}
+}
@@ -0,0 +1,123 @@
+package server
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"PMS/internal/logging"
+)
+
+// TLS 1.2 使用的加密套件，仅保留支持前向保密的AEAD套件；TLS 1.3 的套件由标准库固定
+var tlsCipherSuites = []uint16{
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256,
+}
+
+// certReloader 持有当前证书，证书文件变化或 SIGHUP 时从磁盘重新加载，新连接使用新证书
+type certReloader struct {
+	certFile string
+	keyFile  string
+	cert     atomic.Pointer[tls.Certificate]
+	// 上次加载时证书与私钥文件的修改时间
+	modTime time.Time
+}
+
+func newCertReloader(certFile, keyFile string) (*certReloader, error) {
+	r := &certReloader{certFile: certFile, keyFile: keyFile}
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *certReloader) reload() error {
+	modTime := r.filesModTime()
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return err
+	}
+	r.cert.Store(&cert)
+	r.modTime = modTime
+	return nil
+}
+
+// filesModTime 返回证书与私钥中较新的修改时间，文件暂时不可读时返回零值
+func (r *certReloader) filesModTime() time.Time {
+	var latest time.Time
+	for _, name := range []string{r.certFile, r.keyFile} {
+		info, err := os.Stat(name)
+		if err != nil {
+			return time.Time{}
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest
+}
+
+func (r *certReloader) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return r.cert.Load(), nil
+}
+
+// watch 每隔 interval 检查证书文件是否更新 (如 certbot 续期)，收到 SIGHUP 时立即重新加载；
+// 加载失败时继续使用旧证书，interval 为0时只响应 SIGHUP
+func (r *certReloader) watch(interval time.Duration) {
+	sighup := make(chan os.Signal, 1)
+	signal.Notify(sighup, syscall.SIGHUP)
+
+	var tick <-chan time.Time
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case <-sighup:
+		case <-tick:
+			// 续期时证书与私钥可能先后写入，修改时间不变或文件暂时缺失时跳过
+			if modTime := r.filesModTime(); modTime.IsZero() || modTime.Equal(r.modTime) {
+				continue
+			}
+		}
+		if err := r.reload(); err != nil {
+			logging.Logger.Error("certificate reload failed, keeping current certificate", "cert_file", r.certFile, "error", err)
+			continue
+		}
+		logging.Logger.Info("certificate reloaded", "cert_file", r.certFile)
+	}
+}
+
+func newTLSConfig(r *certReloader) *tls.Config {
+	return &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		CipherSuites:   tlsCipherSuites,
+		GetCertificate: r.getCertificate,
+	}
+}
+
+// httpsRedirectHandler 将HTTP请求以301重定向到 tlsPort 上的HTTPS地址
+func httpsRedirectHandler(tlsPort string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		host := req.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if tlsPort != "443" {
+			host = net.JoinHostPort(host, tlsPort)
+		}
+		http.Redirect(w, req, "https://"+host+req.URL.RequestURI(), http.StatusMovedPermanently)
+	})
+}